@@ -5,9 +5,10 @@
 //   - Every client has a graceful Close.
 //   - GCP authentication uses Application Default Credentials
 //     (local: gcloud auth; Cloud Run: built-in service account; CI: WIF).
-//   - Gemini authentication depends on AI_BACKEND:
-//     "gemini" (default) -> Google AI Studio API key (free tier)
-//     "vertex"           -> Vertex AI, sharing the same ADC above
+//   - Gemini clients are built for the genai OCR providers named in
+//     OCR_PROVIDERS (default: AI_BACKEND):
+//     "gemini" -> Google AI Studio API key (free tier)
+//     "vertex" -> Vertex AI, sharing the same ADC above
 package clients
 
 import (
@@ -29,7 +30,10 @@ type Clients struct {
 	Firestore *firestore.Client
 	Storage   *storage.Client
 	Auth      *firebaseauth.Client
-	Gemini    *genai.Client
+	// Gemini talks to the Gemini Developer API (AI Studio); Vertex to Vertex
+	// AI. Either is nil when its provider is not configured.
+	Gemini *genai.Client
+	Vertex *genai.Client
 }
 
 // New builds and initialises every client.
@@ -62,36 +66,47 @@ func New(ctx context.Context, cfg *config.Config) (*Clients, error) {
 		return nil, fmt.Errorf("init storage: %w", err)
 	}
 
-	// ----- Gemini (AI Studio or Vertex AI) -----
+	// ----- Gemini (AI Studio and/or Vertex AI) -----
 	// Strategy: fail-late. A missing API key only causes the OCR endpoint to
 	// return 503; the whole backend still starts so health checks pass and
 	// Sentry can keep alerting on real failures. For production+gemini,
 	// config.Load() already fail-fasts higher up.
-	geminiCfg, skip, err := buildGeminiConfig(cfg)
-	if err != nil {
-		return nil, err
-	}
-	if skip {
-		slog.Warn("gemini client not initialized: missing API key (OCR endpoints will fail until GEMINI_API_KEY is set)",
-			"aiBackend", cfg.AIBackend, "env", cfg.Env)
-	} else {
-		c.Gemini, err = genai.NewClient(ctx, geminiCfg)
+	for _, backend := range []string{"gemini", "vertex"} {
+		if !contains(cfg.OCRProviders, backend) {
+			continue
+		}
+		geminiCfg, skip, err := buildGeminiConfig(cfg, backend)
+		if err != nil {
+			return nil, err
+		}
+		if skip {
+			slog.Warn("gemini client not initialized: missing API key (OCR endpoints will fail until GEMINI_API_KEY is set)",
+				"aiBackend", backend, "env", cfg.Env)
+			continue
+		}
+		client, err := genai.NewClient(ctx, geminiCfg)
 		if err != nil {
-			return nil, fmt.Errorf("init gemini (%s backend): %w", cfg.AIBackend, err)
+			return nil, fmt.Errorf("init gemini (%s backend): %w", backend, err)
+		}
+		if backend == "gemini" {
+			c.Gemini = client
+		} else {
+			c.Vertex = client
 		}
 	}
 
-	slog.Info("all clients initialized", "aiBackend", cfg.AIBackend, "geminiReady", c.Gemini != nil)
+	slog.Info("all clients initialized", "ocrProviders", cfg.OCRProviders,
+		"geminiReady", c.Gemini != nil, "vertexReady", c.Vertex != nil)
 	return c, nil
 }
 
-// buildGeminiConfig picks between the Gemini Developer API (free tier) and
-// Vertex AI (paid) based on configuration.
+// buildGeminiConfig builds the client config for the Gemini Developer API
+// (free tier, backend "gemini") or Vertex AI (paid, backend "vertex").
 //
 // Returned skip=true means a required setting is missing -> skip initialisation
 // so the backend can still come up.
-func buildGeminiConfig(cfg *config.Config) (cc *genai.ClientConfig, skip bool, err error) {
-	switch cfg.AIBackend {
+func buildGeminiConfig(cfg *config.Config, backend string) (cc *genai.ClientConfig, skip bool, err error) {
+	switch backend {
 	case "gemini":
 		if cfg.GeminiAPIKey == "" {
 			// Production fail-fast is already handled in config.Load.
@@ -110,8 +125,17 @@ func buildGeminiConfig(cfg *config.Config) (cc *genai.ClientConfig, skip bool, e
 			Backend:  genai.BackendVertexAI,
 		}, false, nil
 	default:
-		return nil, false, fmt.Errorf("unknown AI_BACKEND: %s", cfg.AIBackend)
+		return nil, false, fmt.Errorf("unknown AI backend: %s", backend)
+	}
+}
+
+func contains(haystack []string, needle string) bool {
+	for _, s := range haystack {
+		if s == needle {
+			return true
+		}
 	}
+	return false
 }
 
 // Close shuts every client down.
@@ -7,8 +7,12 @@ package config
 import (
 	"fmt"
 	"log/slog"
+	"math"
 	"os"
+	"os/exec"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // Config holds application settings. Every field must have a sensible default
@@ -49,6 +53,63 @@ type Config struct {
 	// GeminiModel: Gemini model name to use, e.g. gemini-2.5-flash-lite
 	GeminiModel string
 
+	// OCRProviders: the OCR providers to try, in order; each one is only asked
+	// when the ones before it fail. Values: gemini / vertex / openai / lcd /
+	// fake.
+	// Comma-separated in OCR_PROVIDERS; defaults to AIBackend alone.
+	OCRProviders []string
+
+	// OCREscalationModel: stronger Gemini model a doubtful reading (low
+	// confidence, or at odds with the previous reading) is retried with, on
+	// the gemini / vertex providers. OCR_ESCALATION_MODEL=off disables it.
+	OCREscalationModel string
+
+	// OCRCacheTTL: how long an OCR answer is reused for a re-submitted photo
+	// (same image bytes, models, prompt and previous reading). Go duration in
+	// OCR_CACHE_TTL; 0 disables the cache.
+	OCRCacheTTL time.Duration
+
+	// OCRMaxImageDimension: photos are scaled down so their longer side is at
+	// most this many pixels before OCR (fewer tokens, less latency).
+	OCRMaxImageDimension int
+
+	// OCRNormalizeContrast: also stretch photo contrast before OCR; helps dim
+	// or hazy shots, off by default.
+	OCRNormalizeContrast bool
+
+	// OCRMinSharpness, OCRMaxClipped and OCRMinBrightness: photos scoring
+	// outside them (blurred, glare / overexposed, too dark) are sent back for
+	// a retake before any model call. 0 turns a check off.
+	OCRMinSharpness  float64
+	OCRMaxClipped    float64
+	OCRMinBrightness float64
+
+	// OCRFewShotExamples: how many of a meter's corrected readings (from users
+	// who opted in) are sent with each read of it as labelled example photos;
+	// 0 disables them. At most 5: each is another image to pay for.
+	OCRFewShotExamples int
+
+	// OCRReportUIDs: comma-separated uids allowed to see the cross-user OCR
+	// prompt variant report (GET /ocr/report). Empty: no one.
+	OCRReportUIDs []string
+
+	// HEICConvertCommand: program (with arguments) that reads a HEIC photo on
+	// stdin and writes JPEG to stdout, e.g. "magick heic:- -quality 90 jpg:-".
+	// Empty refuses HEIC photos (errors.ocr.heic_not_supported): the distroless
+	// image ships no converter, so this needs a custom image.
+	HEICConvertCommand string
+
+	// OpenAIBaseURL: base URL of an OpenAI-compatible API (the "openai"
+	// provider); anything serving /chat/completions with image input works.
+	OpenAIBaseURL string
+
+	// OpenAIAPIKey: bearer token for OpenAIBaseURL; the "openai" provider is
+	// skipped while it is empty.
+	OpenAIAPIKey string
+
+	// OpenAIModel: vision model name sent to OpenAIBaseURL
+	OpenAIModel string
+
 	// SentryDSN: optional; empty string means Sentry is disabled
 	SentryDSN string
 
@@ -65,20 +126,26 @@ type Config struct {
 // Returns an error so main can fatal out when a required field is missing.
 func Load() (*Config, error) {
 	cfg := &Config{
-		Env:               envOr("APP_ENV", "dev"),
-		GCPProjectID:      os.Getenv("GCP_PROJECT_ID"),
-		GCPRegion:         envOr("GCP_REGION", "asia-east1"),
-		MetersBucket:      os.Getenv("METERS_BUCKET"),
-		Port:              envOr("PORT", "8080"),
-		AllowedOrigins:    splitAndTrim(envOr("ALLOWED_ORIGINS", "*")),
-		AuthBypass:        envOr("AUTH_BYPASS", "false") == "true",
-		AuthBypassUID:     envOr("AUTH_BYPASS_UID", "dev-user"),
-		AIBackend:         strings.ToLower(envOr("AI_BACKEND", "gemini")),
-		GeminiAPIKey:      firstNonEmpty(os.Getenv("GEMINI_API_KEY"), os.Getenv("GOOGLE_API_KEY")),
-		GeminiModel:       firstNonEmpty(os.Getenv("GEMINI_MODEL"), os.Getenv("VERTEX_MODEL"), "gemini-2.5-flash-lite"),
-		SentryDSN:         os.Getenv("SENTRY_DSN"),
-		LINEChannelID:     os.Getenv("LINE_CHANNEL_ID"),
-		LINEChannelSecret: os.Getenv("LINE_CHANNEL_SECRET"),
+		Env:                  envOr("APP_ENV", "dev"),
+		GCPProjectID:         os.Getenv("GCP_PROJECT_ID"),
+		GCPRegion:            envOr("GCP_REGION", "asia-east1"),
+		MetersBucket:         os.Getenv("METERS_BUCKET"),
+		Port:                 envOr("PORT", "8080"),
+		AllowedOrigins:       splitAndTrim(envOr("ALLOWED_ORIGINS", "*")),
+		AuthBypass:           envOr("AUTH_BYPASS", "false") == "true",
+		AuthBypassUID:        envOr("AUTH_BYPASS_UID", "dev-user"),
+		AIBackend:            strings.ToLower(envOr("AI_BACKEND", "gemini")),
+		GeminiAPIKey:         firstNonEmpty(os.Getenv("GEMINI_API_KEY"), os.Getenv("GOOGLE_API_KEY")),
+		GeminiModel:          firstNonEmpty(os.Getenv("GEMINI_MODEL"), os.Getenv("VERTEX_MODEL"), "gemini-2.5-flash-lite"),
+		OCRNormalizeContrast: envOr("OCR_NORMALIZE_CONTRAST", "false") == "true",
+		OCREscalationModel:   envOr("OCR_ESCALATION_MODEL", "gemini-2.5-flash"),
+		HEICConvertCommand:   strings.TrimSpace(os.Getenv("HEIC_CONVERT_COMMAND")),
+		OpenAIBaseURL:        strings.TrimRight(envOr("OPENAI_BASE_URL", "https://api.openai.com/v1"), "/"),
+		OpenAIAPIKey:         os.Getenv("OPENAI_API_KEY"),
+		OpenAIModel:          envOr("OPENAI_MODEL", "gpt-4o-mini"),
+		SentryDSN:            os.Getenv("SENTRY_DSN"),
+		LINEChannelID:        os.Getenv("LINE_CHANNEL_ID"),
+		LINEChannelSecret:    os.Getenv("LINE_CHANNEL_SECRET"),
 	}
 
 	// AI backend must be either gemini or vertex
@@ -86,6 +153,62 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("AI_BACKEND must be either gemini or vertex, got: %s", cfg.AIBackend)
 	}
 
+	if cfg.OCREscalationModel == "off" || cfg.OCREscalationModel == cfg.GeminiModel {
+		cfg.OCREscalationModel = ""
+	}
+
+	ttl, err := time.ParseDuration(envOr("OCR_CACHE_TTL", "24h"))
+	if err != nil || ttl < 0 {
+		return nil, fmt.Errorf("OCR_CACHE_TTL must be a non-negative duration such as 24h, got: %s", os.Getenv("OCR_CACHE_TTL"))
+	}
+	cfg.OCRCacheTTL = ttl
+
+	dim, err := strconv.Atoi(envOr("OCR_MAX_IMAGE_DIMENSION", "1600"))
+	if err != nil || dim < 256 {
+		return nil, fmt.Errorf("OCR_MAX_IMAGE_DIMENSION must be a pixel count of at least 256, got: %s", os.Getenv("OCR_MAX_IMAGE_DIMENSION"))
+	}
+	cfg.OCRMaxImageDimension = dim
+
+	examples, err := strconv.Atoi(envOr("OCR_FEW_SHOT_EXAMPLES", "3"))
+	if err != nil || examples < 0 || examples > 5 {
+		return nil, fmt.Errorf("OCR_FEW_SHOT_EXAMPLES must be a number from 0 to 5, got: %s", os.Getenv("OCR_FEW_SHOT_EXAMPLES"))
+	}
+	cfg.OCRFewShotExamples = examples
+	cfg.OCRReportUIDs = splitAndTrim(os.Getenv("OCR_REPORT_UIDS"))
+
+	for _, q := range []struct {
+		env, def string
+		dst      *float64
+		max      float64
+		limit    string
+	}{
+		{"OCR_MIN_SHARPNESS", "20", &cfg.OCRMinSharpness, math.MaxFloat64, ""},
+		{"OCR_MAX_CLIPPED", "0.2", &cfg.OCRMaxClipped, 1, " no greater than 1"},
+		{"OCR_MIN_BRIGHTNESS", "0.12", &cfg.OCRMinBrightness, 1, " no greater than 1"},
+	} {
+		v, err := strconv.ParseFloat(envOr(q.env, q.def), 64)
+		if err != nil || v < 0 || v > q.max {
+			return nil, fmt.Errorf("%s must be a non-negative number%s, got: %s", q.env, q.limit, os.Getenv(q.env))
+		}
+		*q.dst = v
+	}
+
+	if cfg.HEICConvertCommand != "" {
+		if _, err := exec.LookPath(strings.Fields(cfg.HEICConvertCommand)[0]); err != nil {
+			return nil, fmt.Errorf("HEIC_CONVERT_COMMAND: %w", err)
+		}
+	}
+
+	cfg.OCRProviders = splitAndTrim(strings.ToLower(envOr("OCR_PROVIDERS", cfg.AIBackend)))
+	if len(cfg.OCRProviders) == 0 {
+		return nil, fmt.Errorf("OCR_PROVIDERS must name at least one provider")
+	}
+	for _, p := range cfg.OCRProviders {
+		if !contains(ocrProviderNames, p) {
+			return nil, fmt.Errorf("OCR_PROVIDERS: unknown provider %q (want one of %s)", p, strings.Join(ocrProviderNames, ", "))
+		}
+	}
+
 	// Hard checks for production
 	if cfg.Env == "production" {
 		if cfg.AuthBypass {
@@ -94,8 +217,14 @@ func Load() (*Config, error) {
 		if contains(cfg.AllowedOrigins, "*") {
 			return nil, fmt.Errorf("ALLOWED_ORIGINS=* is not allowed in production")
 		}
-		if cfg.AIBackend == "gemini" && cfg.GeminiAPIKey == "" {
-			return nil, fmt.Errorf("AI_BACKEND=gemini requires GEMINI_API_KEY (apply via Google AI Studio)")
+		if contains(cfg.OCRProviders, "gemini") && cfg.GeminiAPIKey == "" {
+			return nil, fmt.Errorf("OCR provider gemini requires GEMINI_API_KEY (apply via Google AI Studio)")
+		}
+		if contains(cfg.OCRProviders, "openai") && cfg.OpenAIAPIKey == "" {
+			return nil, fmt.Errorf("OCR provider openai requires OPENAI_API_KEY")
+		}
+		if contains(cfg.OCRProviders, "fake") {
+			return nil, fmt.Errorf("OCR provider fake is not allowed in production")
 		}
 	}
 
@@ -112,10 +241,16 @@ func Load() (*Config, error) {
 		"authBypass", cfg.AuthBypass,
 		"aiBackend", cfg.AIBackend,
 		"geminiModel", cfg.GeminiModel,
+		"ocrProviders", cfg.OCRProviders,
+		"ocrEscalationModel", cfg.OCREscalationModel,
+		"ocrCacheTTL", cfg.OCRCacheTTL.String(),
 	)
 	return cfg, nil
 }
 
+// ocrProviderNames lists the values OCR_PROVIDERS accepts.
+var ocrProviderNames = []string{"gemini", "vertex", "openai", "lcd", "fake"}
+
 func firstNonEmpty(values ...string) string {
 	for _, v := range values {
 		if v != "" {
@@ -2,6 +2,7 @@ package config
 
 import (
 	"testing"
+	"time"
 )
 
 func TestSplitAndTrim(t *testing.T) {
@@ -150,6 +151,215 @@ func TestLoadRejectsUnknownAIBackend(t *testing.T) {
 	}
 }
 
+func TestLoadOCRProviders(t *testing.T) {
+	resetEnv(t)
+	t.Setenv("AUTH_BYPASS", "true")
+	t.Setenv("AI_BACKEND", "vertex")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(cfg.OCRProviders) != 1 || cfg.OCRProviders[0] != "vertex" {
+		t.Errorf("OCRProviders = %v, want [vertex] from AI_BACKEND", cfg.OCRProviders)
+	}
+
+	t.Setenv("OCR_PROVIDERS", " Gemini, openai ,fake")
+	t.Setenv("OPENAI_BASE_URL", "http://localhost:11434/v1/")
+	if cfg, err = Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	want := []string{"gemini", "openai", "fake"}
+	if len(cfg.OCRProviders) != len(want) {
+		t.Fatalf("OCRProviders = %v, want %v", cfg.OCRProviders, want)
+	}
+	for i := range want {
+		if cfg.OCRProviders[i] != want[i] {
+			t.Errorf("OCRProviders[%d] = %q, want %q", i, cfg.OCRProviders[i], want[i])
+		}
+	}
+	if cfg.OpenAIBaseURL != "http://localhost:11434/v1" {
+		t.Errorf("OpenAIBaseURL = %q, want trailing slash trimmed", cfg.OpenAIBaseURL)
+	}
+
+	t.Setenv("OCR_PROVIDERS", "lcd,gemini")
+	if cfg, err = Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.OCRProviders[0] != "lcd" {
+		t.Errorf("OCRProviders = %v, want the local LCD reader first", cfg.OCRProviders)
+	}
+
+	t.Setenv("OCR_PROVIDERS", "gemini,tesseract")
+	if _, err := Load(); err == nil {
+		t.Fatal("expected Load to reject an unknown OCR provider")
+	}
+}
+
+func TestLoadOCREscalationModel(t *testing.T) {
+	resetEnv(t)
+	t.Setenv("AUTH_BYPASS", "true")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.OCREscalationModel != "gemini-2.5-flash" {
+		t.Errorf("OCREscalationModel = %q, want default", cfg.OCREscalationModel)
+	}
+
+	for _, v := range []string{"off", "gemini-2.5-flash-lite"} {
+		t.Setenv("OCR_ESCALATION_MODEL", v)
+		if cfg, err = Load(); err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+		if cfg.OCREscalationModel != "" {
+			t.Errorf("OCR_ESCALATION_MODEL=%s: OCREscalationModel = %q, want disabled", v, cfg.OCREscalationModel)
+		}
+	}
+}
+
+func TestLoadOCRCacheTTL(t *testing.T) {
+	resetEnv(t)
+	t.Setenv("AUTH_BYPASS", "true")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.OCRCacheTTL != 24*time.Hour {
+		t.Errorf("OCRCacheTTL = %v, want 24h", cfg.OCRCacheTTL)
+	}
+
+	t.Setenv("OCR_CACHE_TTL", "0")
+	if cfg, err = Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.OCRCacheTTL != 0 {
+		t.Errorf("OCR_CACHE_TTL=0: OCRCacheTTL = %v, want disabled", cfg.OCRCacheTTL)
+	}
+
+	t.Setenv("OCR_CACHE_TTL", "a day")
+	if _, err := Load(); err == nil {
+		t.Fatal("expected Load to reject an unparsable OCR_CACHE_TTL")
+	}
+}
+
+func TestLoadOCRImageSettings(t *testing.T) {
+	resetEnv(t)
+	t.Setenv("AUTH_BYPASS", "true")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.OCRMaxImageDimension != 1600 || cfg.OCRNormalizeContrast {
+		t.Errorf("defaults = %d / %v, want 1600 / false", cfg.OCRMaxImageDimension, cfg.OCRNormalizeContrast)
+	}
+
+	t.Setenv("OCR_MAX_IMAGE_DIMENSION", "100")
+	if _, err := Load(); err == nil {
+		t.Fatal("expected Load to reject a tiny OCR_MAX_IMAGE_DIMENSION")
+	}
+}
+
+func TestLoadOCRQualityThresholds(t *testing.T) {
+	resetEnv(t)
+	t.Setenv("AUTH_BYPASS", "true")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.OCRMinSharpness != 20 || cfg.OCRMaxClipped != 0.2 || cfg.OCRMinBrightness != 0.12 {
+		t.Errorf("defaults = %v / %v / %v, want 20 / 0.2 / 0.12", cfg.OCRMinSharpness, cfg.OCRMaxClipped, cfg.OCRMinBrightness)
+	}
+
+	t.Setenv("OCR_MIN_SHARPNESS", "0")
+	if cfg, err = Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.OCRMinSharpness != 0 {
+		t.Errorf("OCRMinSharpness = %v, want 0 (check off)", cfg.OCRMinSharpness)
+	}
+
+	t.Setenv("OCR_MAX_CLIPPED", "25%")
+	if _, err := Load(); err == nil {
+		t.Fatal("expected Load to reject a non-numeric OCR_MAX_CLIPPED")
+	}
+	t.Setenv("OCR_MAX_CLIPPED", "")
+	t.Setenv("OCR_MIN_BRIGHTNESS", "1.5")
+	if _, err := Load(); err == nil {
+		t.Fatal("expected Load to reject OCR_MIN_BRIGHTNESS above 1")
+	}
+}
+
+func TestLoadOCRFewShotExamples(t *testing.T) {
+	resetEnv(t)
+	t.Setenv("AUTH_BYPASS", "true")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.OCRFewShotExamples != 3 {
+		t.Errorf("default = %d, want 3", cfg.OCRFewShotExamples)
+	}
+
+	t.Setenv("OCR_FEW_SHOT_EXAMPLES", "0")
+	if cfg, err = Load(); err != nil || cfg.OCRFewShotExamples != 0 {
+		t.Fatalf("OCR_FEW_SHOT_EXAMPLES=0: %v, %v; want examples off", cfg, err)
+	}
+	t.Setenv("OCR_FEW_SHOT_EXAMPLES", "12")
+	if _, err := Load(); err == nil {
+		t.Fatal("expected Load to reject OCR_FEW_SHOT_EXAMPLES above 5")
+	}
+}
+
+func TestLoadOCRReportUIDs(t *testing.T) {
+	resetEnv(t)
+	t.Setenv("AUTH_BYPASS", "true")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(cfg.OCRReportUIDs) != 0 {
+		t.Errorf("default = %v, want no one", cfg.OCRReportUIDs)
+	}
+
+	t.Setenv("OCR_REPORT_UIDS", " alice, ,bob ")
+	if cfg, err = Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(cfg.OCRReportUIDs) != 2 || cfg.OCRReportUIDs[0] != "alice" || cfg.OCRReportUIDs[1] != "bob" {
+		t.Errorf("OCRReportUIDs = %q, want [alice bob]", cfg.OCRReportUIDs)
+	}
+}
+
+func TestLoadHEICConvertCommand(t *testing.T) {
+	resetEnv(t)
+	t.Setenv("AUTH_BYPASS", "true")
+	t.Setenv("HEIC_CONVERT_COMMAND", "no-such-heic-converter heic:- jpg:-")
+
+	if _, err := Load(); err == nil {
+		t.Fatal("expected Load to reject a HEIC_CONVERT_COMMAND that is not installed")
+	}
+}
+
+func TestLoadProductionRejectsFakeOCR(t *testing.T) {
+	resetEnv(t)
+	t.Setenv("APP_ENV", "production")
+	t.Setenv("GCP_PROJECT_ID", "wattrent-prod")
+	t.Setenv("ALLOWED_ORIGINS", "https://example.com")
+	t.Setenv("GEMINI_API_KEY", "x")
+	t.Setenv("OCR_PROVIDERS", "gemini,fake")
+
+	if _, err := Load(); err == nil {
+		t.Fatal("expected Load to reject the fake OCR provider in production")
+	}
+}
+
 // TestLoadLINEEnvVars confirms the new LINE Login configuration fields are
 // surfaced verbatim from the environment (no validation, no munging) so the
 // services layer can decide what to do with the values.
@@ -197,6 +407,11 @@ func resetEnv(t *testing.T) {
 		"APP_ENV", "GCP_PROJECT_ID", "GCP_REGION", "METERS_BUCKET", "PORT",
 		"ALLOWED_ORIGINS", "AUTH_BYPASS", "AUTH_BYPASS_UID", "AI_BACKEND",
 		"GEMINI_API_KEY", "GOOGLE_API_KEY", "GEMINI_MODEL", "VERTEX_MODEL",
+		"OCR_PROVIDERS", "OCR_ESCALATION_MODEL", "OCR_CACHE_TTL",
+		"OCR_MAX_IMAGE_DIMENSION", "OCR_NORMALIZE_CONTRAST", "HEIC_CONVERT_COMMAND",
+		"OCR_MIN_SHARPNESS", "OCR_MAX_CLIPPED", "OCR_MIN_BRIGHTNESS", "OCR_FEW_SHOT_EXAMPLES",
+		"OCR_REPORT_UIDS",
+		"OPENAI_BASE_URL", "OPENAI_API_KEY", "OPENAI_MODEL",
 		"SENTRY_DSN",
 		"LINE_CHANNEL_ID", "LINE_CHANNEL_SECRET",
 	}
@@ -9,6 +9,8 @@
 package handlers
 
 import (
+	"errors"
+	"io"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -61,6 +63,55 @@ func (h *BillHandler) Create(c *gin.Context) {
 	})
 }
 
+// POST /api/v1/bills/from-photo
+//
+// Body: { "gcsPath": "gs://.../users/{uid}/bills/{billId}.jpg", "period": "2026-05" }
+//
+// A draft (status "draft") needs POST /bills/:id/confirm; the message tells
+// the client which one it got.
+func (h *BillHandler) CreateFromPhoto(c *gin.Context) {
+	var req models.CreateBillFromPhotoRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(&middleware.AppError{HTTPStatus: http.StatusBadRequest, Key: "errors.bad_request", Cause: err})
+		return
+	}
+
+	bill, err := h.bills.CreateFromPhoto(c.Request.Context(), middleware.GetUID(c), &req)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	msg := "bills.created"
+	if bill.Status == models.BillStatusDraft {
+		msg = "bills.draft_created"
+	}
+	h.resolveViewURL(c, bill)
+	c.JSON(http.StatusCreated, models.ApiResponse{Success: true, Data: bill, Message: msg})
+}
+
+// POST /api/v1/bills/:id/confirm
+//
+// Body: { "meterReading": 36034 }  // optional correction of the OCR value
+func (h *BillHandler) Confirm(c *gin.Context) {
+	var req models.ConfirmBillRequest
+	// An empty body accepts the reading as read.
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		_ = c.Error(&middleware.AppError{HTTPStatus: http.StatusBadRequest, Key: "errors.bad_request", Cause: err})
+		return
+	}
+
+	bill, err := h.bills.Confirm(c.Request.Context(), middleware.GetUID(c), c.Param("id"), &req)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Success: true,
+		Data:    bill,
+		Message: "bills.confirmed",
+	})
+}
+
 // GET /api/v1/bills
 func (h *BillHandler) List(c *gin.Context) {
 	bills, err := h.bills.List(c.Request.Context(), middleware.GetUID(c), 50)
@@ -113,6 +164,8 @@ func (h *BillHandler) UpdatePayment(c *gin.Context) {
 }
 
 // DELETE /api/v1/bills/:id
+//
+// Moves the bill to the trash; see Restore.
 func (h *BillHandler) Delete(c *gin.Context) {
 	if err := h.bills.Delete(c.Request.Context(), middleware.GetUID(c), c.Param("id")); err != nil {
 		_ = c.Error(err)
@@ -120,3 +173,38 @@ func (h *BillHandler) Delete(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, models.ApiResponse{Success: true, Message: "bills.deleted"})
 }
+
+// GET /api/v1/bills/trash
+func (h *BillHandler) ListTrash(c *gin.Context) {
+	bills, err := h.bills.ListTrash(c.Request.Context(), middleware.GetUID(c))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, models.ApiResponse{Success: true, Data: bills})
+}
+
+// POST /api/v1/bills/:id/restore
+func (h *BillHandler) Restore(c *gin.Context) {
+	bill, err := h.bills.Restore(c.Request.Context(), middleware.GetUID(c), c.Param("id"))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Success: true,
+		Data:    bill,
+		Message: "bills.restored",
+	})
+}
+
+// DELETE /api/v1/bills/trash/:id
+//
+// Deletes a trashed bill and its photo for good.
+func (h *BillHandler) DeleteFromTrash(c *gin.Context) {
+	if err := h.bills.DeleteFromTrash(c.Request.Context(), middleware.GetUID(c), c.Param("id")); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, models.ApiResponse{Success: true, Message: "bills.purged"})
+}
@@ -168,3 +168,209 @@ func TestBillHandler_Delete(t *testing.T) {
 		t.Errorf("Message = %q", got)
 	}
 }
+
+func TestBillHandler_Create_RejectsMalformedPeriodDates(t *testing.T) {
+	env := newTestEnv(t)
+	rec := env.do(t, "POST", "/api/v1/bills", map[string]any{
+		"meterReading":    100,
+		"electricityRate": 4.5,
+		"rent":            8000,
+		"period":          "2026-05",
+		"periodStart":     "2026/05/15",
+		"periodEnd":       "2026-06-15",
+	})
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400; body=%s", rec.Code, rec.Body.String())
+	}
+	if env.bills.createCalls != 0 {
+		t.Errorf("createCalls = %d, want 0", env.bills.createCalls)
+	}
+}
+
+func TestBillHandler_Create_RejectsUnknownUtilityMeter(t *testing.T) {
+	env := newTestEnv(t)
+	rec := env.do(t, "POST", "/api/v1/bills", map[string]any{
+		"meterReading":    100,
+		"electricityRate": 4.5,
+		"rent":            8000,
+		"period":          "2026-05",
+		"utilities": []map[string]any{
+			{"meterType": "steam", "meterReading": 12},
+		},
+	})
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400; body=%s", rec.Code, rec.Body.String())
+	}
+	if env.bills.createCalls != 0 {
+		t.Errorf("createCalls = %d, want 0", env.bills.createCalls)
+	}
+}
+
+func TestBillHandler_Create_FromEndReading(t *testing.T) {
+	env := newTestEnv(t)
+	env.bills.createFn = func(ctx context.Context, uid string, req *models.CreateBillRequest) (*models.Bill, error) {
+		if req.EndReadingID != "r-end" || req.MeterReading != 0 {
+			t.Errorf("req = %+v", req)
+		}
+		return &models.Bill{ID: "bill-1", EndReadingID: req.EndReadingID}, nil
+	}
+	// meterReading may be omitted when the end reading comes from the log.
+	rec := env.do(t, "POST", "/api/v1/bills", map[string]any{
+		"endReadingId":    "r-end",
+		"electricityRate": 4.5,
+		"rent":            8000,
+		"period":          "2026-05",
+	})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d, body=%s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestBillHandler_ListTrash(t *testing.T) {
+	env := newTestEnv(t)
+	deletedAt := time.Date(2026, 5, 2, 10, 0, 0, 0, time.UTC)
+	purgeAt := deletedAt.Add(30 * 24 * time.Hour)
+	env.bills.trashFn = func(ctx context.Context, uid string) ([]*models.Bill, error) {
+		return []*models.Bill{{ID: "bill-1", DeletedAt: &deletedAt, PurgeAt: &purgeAt}}, nil
+	}
+	rec := env.do(t, "GET", "/api/v1/bills/trash", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body=%s", rec.Code, rec.Body.String())
+	}
+	var bills []models.Bill
+	dataAs(t, decode(t, rec), &bills)
+	if len(bills) != 1 || bills[0].PurgeAt == nil || !bills[0].PurgeAt.Equal(purgeAt) {
+		t.Errorf("bills = %+v", bills)
+	}
+}
+
+func TestBillHandler_Restore(t *testing.T) {
+	tests := []struct {
+		name       string
+		restoreErr error
+		wantStatus int
+		wantKey    string
+	}{
+		{name: "restored", wantStatus: http.StatusOK, wantKey: "bills.restored"},
+		{
+			name:       "not in trash",
+			restoreErr: &middleware.AppError{HTTPStatus: http.StatusNotFound, Key: "errors.bill.not_in_trash"},
+			wantStatus: http.StatusNotFound,
+			wantKey:    "errors.bill.not_in_trash",
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			env.bills.restoreFn = func(ctx context.Context, uid, billID string) (*models.Bill, error) {
+				if tc.restoreErr != nil {
+					return nil, tc.restoreErr
+				}
+				return &models.Bill{ID: billID}, nil
+			}
+			rec := env.do(t, "POST", "/api/v1/bills/bill-9/restore", nil)
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tc.wantStatus)
+			}
+			if env.bills.lastBillID != "bill-9" {
+				t.Errorf("lastBillID = %q", env.bills.lastBillID)
+			}
+			resp := decode(t, rec)
+			if got := resp.Message + resp.Error; got != tc.wantKey {
+				t.Errorf("key = %q, want %q", got, tc.wantKey)
+			}
+		})
+	}
+}
+
+func TestBillHandler_DeleteFromTrash(t *testing.T) {
+	env := newTestEnv(t)
+	rec := env.do(t, "DELETE", "/api/v1/bills/trash/bill-3", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body=%s", rec.Code, rec.Body.String())
+	}
+	if env.bills.lastBillID != "bill-3" {
+		t.Errorf("lastBillID = %q, want bill-3", env.bills.lastBillID)
+	}
+	if env.bills.deleteCalls != 0 {
+		t.Errorf("Delete called %d times; the trash route must not soft-delete", env.bills.deleteCalls)
+	}
+	if got := decode(t, rec).Message; got != "bills.purged" {
+		t.Errorf("Message = %q", got)
+	}
+}
+
+func TestBillHandler_CreateFromPhoto(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      models.BillStatus
+		wantMessage string
+	}{
+		{name: "confident", wantMessage: "bills.created"},
+		{name: "needs confirmation", status: models.BillStatusDraft, wantMessage: "bills.draft_created"},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			env.bills.fromPhotoFn = func(ctx context.Context, uid string, req *models.CreateBillFromPhotoRequest) (*models.Bill, error) {
+				if req.GCSPath != "gs://bucket/users/test-uid/bills/b1.jpg" {
+					t.Errorf("GCSPath = %q", req.GCSPath)
+				}
+				return &models.Bill{ID: "b1", Status: tc.status}, nil
+			}
+			rec := env.do(t, "POST", "/api/v1/bills/from-photo", map[string]any{
+				"gcsPath": "gs://bucket/users/test-uid/bills/b1.jpg",
+			})
+			if rec.Code != http.StatusCreated {
+				t.Fatalf("status = %d, body=%s", rec.Code, rec.Body.String())
+			}
+			if got := decode(t, rec).Message; got != tc.wantMessage {
+				t.Errorf("Message = %q, want %q", got, tc.wantMessage)
+			}
+		})
+	}
+}
+
+func TestBillHandler_CreateFromPhoto_RequiresPath(t *testing.T) {
+	env := newTestEnv(t)
+	rec := env.do(t, "POST", "/api/v1/bills/from-photo", map[string]any{"period": "2026-05"})
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", rec.Code)
+	}
+}
+
+func TestBillHandler_Confirm(t *testing.T) {
+	env := newTestEnv(t)
+	env.bills.confirmFn = func(ctx context.Context, uid, billID string, req *models.ConfirmBillRequest) (*models.Bill, error) {
+		if req.MeterReading == nil || *req.MeterReading != 36034 {
+			t.Errorf("MeterReading = %v", req.MeterReading)
+		}
+		return &models.Bill{ID: billID, MeterReading: *req.MeterReading}, nil
+	}
+	rec := env.do(t, "POST", "/api/v1/bills/b1/confirm", map[string]any{"meterReading": 36034})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body=%s", rec.Code, rec.Body.String())
+	}
+	if env.bills.lastBillID != "b1" {
+		t.Errorf("lastBillID = %q", env.bills.lastBillID)
+	}
+	if got := decode(t, rec).Message; got != "bills.confirmed" {
+		t.Errorf("Message = %q", got)
+	}
+}
+
+func TestBillHandler_Confirm_EmptyBody(t *testing.T) {
+	env := newTestEnv(t)
+	env.bills.confirmFn = func(ctx context.Context, uid, billID string, req *models.ConfirmBillRequest) (*models.Bill, error) {
+		if req.MeterReading != nil {
+			t.Errorf("MeterReading = %v, want nil", *req.MeterReading)
+		}
+		return &models.Bill{ID: billID}, nil
+	}
+	rec := env.do(t, "POST", "/api/v1/bills/b1/confirm", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body=%s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"wattrent/internal/middleware"
+	"wattrent/internal/models"
+)
+
+type ForecastHandler struct {
+	forecasts forecaster
+}
+
+func NewForecastHandler(forecasts forecaster) *ForecastHandler {
+	return &ForecastHandler{forecasts: forecasts}
+}
+
+// GET /api/v1/forecast
+func (h *ForecastHandler) Get(c *gin.Context) {
+	f, err := h.forecasts.Forecast(c.Request.Context(), middleware.GetUID(c))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, models.ApiResponse{Success: true, Data: f})
+}
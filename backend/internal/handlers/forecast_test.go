@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"wattrent/internal/middleware"
+	"wattrent/internal/models"
+)
+
+func TestForecastHandler_Get(t *testing.T) {
+	env := newTestEnv(t)
+	env.forecast.forecastFn = func(ctx context.Context, uid string) (*models.Forecast, error) {
+		if uid != "test-uid" {
+			t.Errorf("uid = %q", uid)
+		}
+		return &models.Forecast{Basis: models.ForecastBasisReadings, Usage: 210.5}, nil
+	}
+	rec := env.do(t, "GET", "/api/v1/forecast", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body=%s", rec.Code, rec.Body.String())
+	}
+	var f models.Forecast
+	dataAs(t, decode(t, rec), &f)
+	if f.Basis != models.ForecastBasisReadings || f.Usage != 210.5 {
+		t.Errorf("forecast = %+v", f)
+	}
+}
+
+func TestForecastHandler_Get_NoBills(t *testing.T) {
+	env := newTestEnv(t)
+	env.forecast.forecastFn = func(ctx context.Context, uid string) (*models.Forecast, error) {
+		return nil, &middleware.AppError{HTTPStatus: http.StatusNotFound, Key: "errors.forecast.no_bills"}
+	}
+	rec := env.do(t, "GET", "/api/v1/forecast", nil)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404", rec.Code)
+	}
+	if got := decode(t, rec).Error; got != "errors.forecast.no_bills" {
+		t.Errorf("Error = %q", got)
+	}
+}
@@ -32,6 +32,11 @@ type fakeBillStore struct {
 	latestFn    func(ctx context.Context, uid string) (*models.Bill, error)
 	setPaidFn   func(ctx context.Context, uid, billID string, paid bool) (*models.Bill, error)
 	deleteFn    func(ctx context.Context, uid, billID string) error
+	trashFn     func(ctx context.Context, uid string) ([]*models.Bill, error)
+	restoreFn   func(ctx context.Context, uid, billID string) (*models.Bill, error)
+	fromPhotoFn func(ctx context.Context, uid string, req *models.CreateBillFromPhotoRequest) (*models.Bill, error)
+	confirmFn   func(ctx context.Context, uid, billID string, req *models.ConfirmBillRequest) (*models.Bill, error)
+	purgeFn     func(ctx context.Context, uid, billID string) error
 	lastUID     string
 	lastBillID  string
 	createCalls int
@@ -46,6 +51,20 @@ func (f *fakeBillStore) Create(ctx context.Context, uid string, req *models.Crea
 	}
 	return nil, errors.New("not implemented")
 }
+func (f *fakeBillStore) CreateFromPhoto(ctx context.Context, uid string, req *models.CreateBillFromPhotoRequest) (*models.Bill, error) {
+	f.lastUID = uid
+	if f.fromPhotoFn != nil {
+		return f.fromPhotoFn(ctx, uid, req)
+	}
+	return nil, errors.New("not implemented")
+}
+func (f *fakeBillStore) Confirm(ctx context.Context, uid, billID string, req *models.ConfirmBillRequest) (*models.Bill, error) {
+	f.lastUID, f.lastBillID = uid, billID
+	if f.confirmFn != nil {
+		return f.confirmFn(ctx, uid, billID, req)
+	}
+	return nil, errors.New("not implemented")
+}
 func (f *fakeBillStore) Get(ctx context.Context, uid, billID string) (*models.Bill, error) {
 	f.lastUID, f.lastBillID = uid, billID
 	if f.getFn != nil {
@@ -82,6 +101,156 @@ func (f *fakeBillStore) Delete(ctx context.Context, uid, billID string) error {
 	}
 	return nil
 }
+func (f *fakeBillStore) ListTrash(ctx context.Context, uid string) ([]*models.Bill, error) {
+	f.lastUID = uid
+	if f.trashFn != nil {
+		return f.trashFn(ctx, uid)
+	}
+	return []*models.Bill{}, nil
+}
+func (f *fakeBillStore) Restore(ctx context.Context, uid, billID string) (*models.Bill, error) {
+	f.lastUID, f.lastBillID = uid, billID
+	if f.restoreFn != nil {
+		return f.restoreFn(ctx, uid, billID)
+	}
+	return nil, errors.New("not implemented")
+}
+func (f *fakeBillStore) DeleteFromTrash(ctx context.Context, uid, billID string) error {
+	f.lastUID, f.lastBillID = uid, billID
+	if f.purgeFn != nil {
+		return f.purgeFn(ctx, uid, billID)
+	}
+	return nil
+}
+
+type fakeReadingStore struct {
+	createFn    func(ctx context.Context, uid string, req *models.CreateReadingRequest) (*models.Reading, error)
+	listFn      func(ctx context.Context, uid string, meterType models.MeterType, limit int) ([]*models.Reading, error)
+	deleteFn    func(ctx context.Context, uid, readingID string) error
+	lastID      string
+	deleteCalls int
+}
+
+func (f *fakeReadingStore) Create(ctx context.Context, uid string, req *models.CreateReadingRequest) (*models.Reading, error) {
+	if f.createFn != nil {
+		return f.createFn(ctx, uid, req)
+	}
+	return &models.Reading{ID: "reading-1", MeterType: req.MeterType, Value: req.Value}, nil
+}
+func (f *fakeReadingStore) List(ctx context.Context, uid string, meterType models.MeterType, limit int) ([]*models.Reading, error) {
+	if f.listFn != nil {
+		return f.listFn(ctx, uid, meterType, limit)
+	}
+	return []*models.Reading{}, nil
+}
+func (f *fakeReadingStore) Delete(ctx context.Context, uid, readingID string) error {
+	f.lastID = readingID
+	f.deleteCalls++
+	if f.deleteFn != nil {
+		return f.deleteFn(ctx, uid, readingID)
+	}
+	return nil
+}
+
+type fakeMeterProfileStore struct {
+	listFn    func(ctx context.Context, uid string) ([]*models.MeterProfile, error)
+	saveFn    func(ctx context.Context, uid string, t models.MeterType, req *models.SaveMeterProfileRequest) (*models.MeterProfile, error)
+	deleteFn  func(ctx context.Context, uid string, t models.MeterType) error
+	lastMeter models.MeterType
+}
+
+func (f *fakeMeterProfileStore) List(ctx context.Context, uid string) ([]*models.MeterProfile, error) {
+	if f.listFn != nil {
+		return f.listFn(ctx, uid)
+	}
+	return []*models.MeterProfile{}, nil
+}
+func (f *fakeMeterProfileStore) Save(ctx context.Context, uid string, t models.MeterType, req *models.SaveMeterProfileRequest) (*models.MeterProfile, error) {
+	f.lastMeter = t
+	if f.saveFn != nil {
+		return f.saveFn(ctx, uid, t, req)
+	}
+	return &models.MeterProfile{MeterType: t, DigitCount: req.DigitCount, Source: models.MeterProfileSourceUser}, nil
+}
+func (f *fakeMeterProfileStore) Delete(ctx context.Context, uid string, t models.MeterType) error {
+	f.lastMeter = t
+	if f.deleteFn != nil {
+		return f.deleteFn(ctx, uid, t)
+	}
+	return nil
+}
+
+type fakeRoomStore struct {
+	createFn func(ctx context.Context, uid string, req *models.SaveRoomRequest) (*models.Room, error)
+	updateFn func(ctx context.Context, uid, roomID string, req *models.SaveRoomRequest) (*models.Room, error)
+	lastRoom string
+}
+
+func (f *fakeRoomStore) List(ctx context.Context, uid string) ([]*models.Room, error) {
+	return []*models.Room{}, nil
+}
+func (f *fakeRoomStore) Create(ctx context.Context, uid string, req *models.SaveRoomRequest) (*models.Room, error) {
+	if f.createFn != nil {
+		return f.createFn(ctx, uid, req)
+	}
+	return &models.Room{ID: "room-1", Label: req.Label, Serial: req.Serial, Position: req.Position}, nil
+}
+func (f *fakeRoomStore) Update(ctx context.Context, uid, roomID string, req *models.SaveRoomRequest) (*models.Room, error) {
+	f.lastRoom = roomID
+	if f.updateFn != nil {
+		return f.updateFn(ctx, uid, roomID, req)
+	}
+	return &models.Room{ID: roomID, Label: req.Label, Serial: req.Serial, Position: req.Position}, nil
+}
+func (f *fakeRoomStore) Delete(ctx context.Context, uid, roomID string) error {
+	f.lastRoom = roomID
+	return nil
+}
+
+type fakeOCRCorrectionStore struct {
+	listFn      func(ctx context.Context, uid string) ([]*models.OCRCorrection, error)
+	lastDeleted string
+	deletedAll  bool
+}
+
+func (f *fakeOCRCorrectionStore) List(ctx context.Context, uid string) ([]*models.OCRCorrection, error) {
+	if f.listFn != nil {
+		return f.listFn(ctx, uid)
+	}
+	return []*models.OCRCorrection{}, nil
+}
+func (f *fakeOCRCorrectionStore) Delete(ctx context.Context, uid, attemptID string) error {
+	f.lastDeleted = attemptID
+	return nil
+}
+func (f *fakeOCRCorrectionStore) DeleteAll(ctx context.Context, uid string) error {
+	f.deletedAll = true
+	return nil
+}
+
+type fakeForecaster struct {
+	forecastFn func(ctx context.Context, uid string) (*models.Forecast, error)
+}
+
+func (f *fakeForecaster) Forecast(ctx context.Context, uid string) (*models.Forecast, error) {
+	if f.forecastFn != nil {
+		return f.forecastFn(ctx, uid)
+	}
+	return &models.Forecast{}, nil
+}
+
+type fakeEmissionStatter struct {
+	statsFn   func(ctx context.Context, uid string, years int) (*models.EmissionStats, error)
+	lastYears int
+}
+
+func (f *fakeEmissionStatter) EmissionStats(ctx context.Context, uid string, years int) (*models.EmissionStats, error) {
+	f.lastYears = years
+	if f.statsFn != nil {
+		return f.statsFn(ctx, uid, years)
+	}
+	return &models.EmissionStats{Region: "TW", Years: []models.YearlyEmissions{}}, nil
+}
 
 type fakeDownloadSigner struct {
 	signFn func(ctx context.Context, gcsPath string) (string, time.Time, error)
@@ -145,15 +314,26 @@ func (f *fakeSettingsStore) Delete(ctx context.Context, uid string) error {
 
 type fakeOCRRunner struct {
 	processFn func(ctx context.Context, req *models.OCRRequest) (*models.OCRResponse, error)
+	reportFn  func(ctx context.Context, t models.MeterType, days int) (*models.OCRReport, error)
+	lastUID   string
 }
 
-func (f *fakeOCRRunner) Process(ctx context.Context, req *models.OCRRequest) (*models.OCRResponse, error) {
+func (f *fakeOCRRunner) Process(ctx context.Context, uid string, req *models.OCRRequest) (*models.OCRResponse, error) {
+	f.lastUID = uid
 	if f.processFn != nil {
 		return f.processFn(ctx, req)
 	}
 	return &models.OCRResponse{Reading: 1234.5, Confidence: 0.9, Model: "fake"}, nil
 }
 
+func (f *fakeOCRRunner) Report(ctx context.Context, uid string, t models.MeterType, days int) (*models.OCRReport, error) {
+	f.lastUID = uid
+	if f.reportFn != nil {
+		return f.reportFn(ctx, t, days)
+	}
+	return &models.OCRReport{MeterType: t}, nil
+}
+
 type fakeUserStore struct {
 	upsertFn func(ctx context.Context, uid, email, displayName, photoURL string) (*models.User, error)
 	getFn    func(ctx context.Context, uid string) (*models.User, error)
@@ -203,15 +383,21 @@ func (f *fakeAccountDeleter) ClearData(ctx context.Context, uid string) error {
 
 // testEnv bundles the fakes used by a single test plus the router.
 type testEnv struct {
-	router   *gin.Engine
-	bills    *fakeBillStore
-	settings *fakeSettingsStore
-	ocr      *fakeOCRRunner
-	uploads  *fakeUploadSigner
-	users    *fakeUserStore
-	account  *fakeAccountDeleter
-	download *fakeDownloadSigner
-	line     *fakeLineExchanger
+	router      *gin.Engine
+	bills       *fakeBillStore
+	readings    *fakeReadingStore
+	forecast    *fakeForecaster
+	stats       *fakeEmissionStatter
+	settings    *fakeSettingsStore
+	meters      *fakeMeterProfileStore
+	rooms       *fakeRoomStore
+	ocr         *fakeOCRRunner
+	corrections *fakeOCRCorrectionStore
+	uploads     *fakeUploadSigner
+	users       *fakeUserStore
+	account     *fakeAccountDeleter
+	download    *fakeDownloadSigner
+	line        *fakeLineExchanger
 }
 
 // newTestEnv wires a router with the same middleware chain as main.go but
@@ -221,14 +407,20 @@ func newTestEnv(t *testing.T) *testEnv {
 	gin.SetMode(gin.TestMode)
 
 	env := &testEnv{
-		bills:    &fakeBillStore{},
-		settings: &fakeSettingsStore{},
-		ocr:      &fakeOCRRunner{},
-		uploads:  &fakeUploadSigner{},
-		users:    &fakeUserStore{},
-		account:  &fakeAccountDeleter{},
-		download: &fakeDownloadSigner{},
-		line:     &fakeLineExchanger{},
+		bills:       &fakeBillStore{},
+		readings:    &fakeReadingStore{},
+		forecast:    &fakeForecaster{},
+		stats:       &fakeEmissionStatter{},
+		settings:    &fakeSettingsStore{},
+		meters:      &fakeMeterProfileStore{},
+		rooms:       &fakeRoomStore{},
+		ocr:         &fakeOCRRunner{},
+		corrections: &fakeOCRCorrectionStore{},
+		uploads:     &fakeUploadSigner{},
+		users:       &fakeUserStore{},
+		account:     &fakeAccountDeleter{},
+		download:    &fakeDownloadSigner{},
+		line:        &fakeLineExchanger{},
 	}
 
 	cfg := &config.Config{
@@ -248,8 +440,14 @@ func newTestEnv(t *testing.T) *testEnv {
 	r.GET("/health", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"status": "ok"}) })
 
 	billH := NewBillHandler(env.bills, env.download)
+	readingH := NewReadingHandler(env.readings)
+	forecastH := NewForecastHandler(env.forecast)
+	statsH := NewStatsHandler(env.stats)
 	settingsH := NewSettingsHandler(env.settings)
 	ocrH := NewOCRHandler(env.ocr)
+	correctionH := NewOCRCorrectionHandler(env.corrections)
+	meterH := NewMeterHandler(env.meters)
+	roomH := NewRoomHandler(env.rooms)
 	uploadH := NewUploadHandler(env.uploads)
 	userH := NewUserHandler(env.users)
 	accountH := NewAccountHandler(env.account)
@@ -267,19 +465,43 @@ func newTestEnv(t *testing.T) *testEnv {
 		authed.DELETE("/users/me", accountH.DeleteMe)
 		authed.DELETE("/users/me/data", accountH.ClearData)
 		authed.POST("/ocr/process", ocrH.Process)
+		authed.GET("/ocr/report", ocrH.Report)
+		authed.GET("/ocr/corrections", correctionH.List)
+		authed.DELETE("/ocr/corrections", correctionH.DeleteAll)
+		authed.DELETE("/ocr/corrections/:attemptId", correctionH.Delete)
 		authed.POST("/uploads/sign", uploadH.Sign)
 		bills := authed.Group("/bills")
 		bills.POST("", billH.Create)
+		bills.POST("/from-photo", billH.CreateFromPhoto)
 		bills.GET("", billH.List)
 		bills.GET("/latest", billH.Latest)
+		bills.GET("/trash", billH.ListTrash)
+		bills.DELETE("/trash/:id", billH.DeleteFromTrash)
 		bills.GET("/:id", billH.Get)
 		bills.PUT("/:id/payment", billH.UpdatePayment)
 		bills.DELETE("/:id", billH.Delete)
+		bills.POST("/:id/restore", billH.Restore)
+		bills.POST("/:id/confirm", billH.Confirm)
+		readings := authed.Group("/readings")
+		readings.POST("", readingH.Create)
+		readings.GET("", readingH.List)
+		readings.DELETE("/:id", readingH.Delete)
+		authed.GET("/forecast", forecastH.Get)
+		authed.GET("/stats/emissions", statsH.Emissions)
 		settings := authed.Group("/settings")
 		settings.GET("", settingsH.Get)
 		settings.PUT("", settingsH.Save)
 		settings.PATCH("", settingsH.Patch)
 		settings.DELETE("", settingsH.Delete)
+		meters := authed.Group("/meters")
+		meters.GET("", meterH.List)
+		meters.PUT("/:meterType", meterH.Save)
+		meters.DELETE("/:meterType", meterH.Delete)
+		rooms := authed.Group("/rooms")
+		rooms.GET("", roomH.List)
+		rooms.POST("", roomH.Create)
+		rooms.PUT("/:id", roomH.Update)
+		rooms.DELETE("/:id", roomH.Delete)
 	}
 
 	env.router = r
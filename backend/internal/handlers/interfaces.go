@@ -14,11 +14,34 @@ import (
 
 type billStore interface {
 	Create(ctx context.Context, uid string, req *models.CreateBillRequest) (*models.Bill, error)
+	CreateFromPhoto(ctx context.Context, uid string, req *models.CreateBillFromPhotoRequest) (*models.Bill, error)
+	Confirm(ctx context.Context, uid, billID string, req *models.ConfirmBillRequest) (*models.Bill, error)
 	Get(ctx context.Context, uid, billID string) (*models.Bill, error)
 	List(ctx context.Context, uid string, limit int) ([]*models.Bill, error)
 	Latest(ctx context.Context, uid string) (*models.Bill, error)
 	SetPaid(ctx context.Context, uid, billID string, paid bool) (*models.Bill, error)
 	Delete(ctx context.Context, uid, billID string) error
+	ListTrash(ctx context.Context, uid string) ([]*models.Bill, error)
+	Restore(ctx context.Context, uid, billID string) (*models.Bill, error)
+	DeleteFromTrash(ctx context.Context, uid, billID string) error
+}
+
+type readingStore interface {
+	Create(ctx context.Context, uid string, req *models.CreateReadingRequest) (*models.Reading, error)
+	List(ctx context.Context, uid string, meterType models.MeterType, limit int) ([]*models.Reading, error)
+	Delete(ctx context.Context, uid, readingID string) error
+}
+
+// forecaster projects the open billing period. Implemented by
+// *services.ForecastService.
+type forecaster interface {
+	Forecast(ctx context.Context, uid string) (*models.Forecast, error)
+}
+
+// emissionStatter totals bill emissions per year. Implemented by
+// *services.BillService.
+type emissionStatter interface {
+	EmissionStats(ctx context.Context, uid string, years int) (*models.EmissionStats, error)
 }
 
 type settingsStore interface {
@@ -28,8 +51,31 @@ type settingsStore interface {
 	Delete(ctx context.Context, uid string) error
 }
 
+// meterProfileStore is implemented by *services.MeterProfileService.
+type meterProfileStore interface {
+	List(ctx context.Context, uid string) ([]*models.MeterProfile, error)
+	Save(ctx context.Context, uid string, t models.MeterType, req *models.SaveMeterProfileRequest) (*models.MeterProfile, error)
+	Delete(ctx context.Context, uid string, t models.MeterType) error
+}
+
+// roomStore is implemented by *services.RoomService.
+type roomStore interface {
+	List(ctx context.Context, uid string) ([]*models.Room, error)
+	Create(ctx context.Context, uid string, req *models.SaveRoomRequest) (*models.Room, error)
+	Update(ctx context.Context, uid, roomID string, req *models.SaveRoomRequest) (*models.Room, error)
+	Delete(ctx context.Context, uid, roomID string) error
+}
+
+// ocrCorrectionStore is implemented by *services.OCRCorrectionService.
+type ocrCorrectionStore interface {
+	List(ctx context.Context, uid string) ([]*models.OCRCorrection, error)
+	Delete(ctx context.Context, uid, attemptID string) error
+	DeleteAll(ctx context.Context, uid string) error
+}
+
 type ocrRunner interface {
-	Process(ctx context.Context, req *models.OCRRequest) (*models.OCRResponse, error)
+	Process(ctx context.Context, uid string, req *models.OCRRequest) (*models.OCRResponse, error)
+	Report(ctx context.Context, uid string, t models.MeterType, days int) (*models.OCRReport, error)
 }
 
 type uploadSigner interface {
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"wattrent/internal/middleware"
+	"wattrent/internal/models"
+)
+
+type MeterHandler struct {
+	meters meterProfileStore
+}
+
+func NewMeterHandler(meters meterProfileStore) *MeterHandler {
+	return &MeterHandler{meters: meters}
+}
+
+// GET /api/v1/meters
+func (h *MeterHandler) List(c *gin.Context) {
+	profiles, err := h.meters.List(c.Request.Context(), middleware.GetUID(c))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, models.ApiResponse{Success: true, Data: profiles})
+}
+
+// PUT /api/v1/meters/:meterType  (full overwrite)
+//
+// Body:
+//
+//	{ "digitCount": 5, "display": "mechanical", "decimalDrum": true,
+//	  "model": "KEC-91E", "serial": "3146159" }  // every field is optional
+func (h *MeterHandler) Save(c *gin.Context) {
+	var req models.SaveMeterProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(&middleware.AppError{HTTPStatus: http.StatusBadRequest, Key: "errors.bad_request", Cause: err})
+		return
+	}
+	profile, err := h.meters.Save(c.Request.Context(), middleware.GetUID(c), models.MeterType(c.Param("meterType")), &req)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Success: true,
+		Data:    profile,
+		Message: "meters.saved",
+	})
+}
+
+// DELETE /api/v1/meters/:meterType
+func (h *MeterHandler) Delete(c *gin.Context) {
+	if err := h.meters.Delete(c.Request.Context(), middleware.GetUID(c), models.MeterType(c.Param("meterType"))); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, models.ApiResponse{Success: true, Message: "meters.deleted"})
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"wattrent/internal/middleware"
+	"wattrent/internal/models"
+)
+
+func TestMeterHandler_List(t *testing.T) {
+	env := newTestEnv(t)
+	env.meters.listFn = func(ctx context.Context, uid string) ([]*models.MeterProfile, error) {
+		return []*models.MeterProfile{{MeterType: models.MeterTypeElectricity, DigitCount: 5}}, nil
+	}
+	rec := env.do(t, "GET", "/api/v1/meters", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body=%s", rec.Code, rec.Body.String())
+	}
+	var profiles []models.MeterProfile
+	dataAs(t, decode(t, rec), &profiles)
+	if len(profiles) != 1 || profiles[0].DigitCount != 5 {
+		t.Errorf("profiles = %+v", profiles)
+	}
+}
+
+func TestMeterHandler_Save(t *testing.T) {
+	env := newTestEnv(t)
+	rec := env.do(t, "PUT", "/api/v1/meters/water", map[string]any{"digitCount": 6, "display": "mechanical", "serial": "3146159"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body=%s", rec.Code, rec.Body.String())
+	}
+	if env.meters.lastMeter != models.MeterTypeWater {
+		t.Errorf("meter type = %q, want water from the path", env.meters.lastMeter)
+	}
+	if got := decode(t, rec).Message; got != "meters.saved" {
+		t.Errorf("Message = %q", got)
+	}
+
+	env.meters.saveFn = func(ctx context.Context, uid string, t models.MeterType, req *models.SaveMeterProfileRequest) (*models.MeterProfile, error) {
+		return nil, &middleware.AppError{HTTPStatus: 400, Key: "errors.meter.invalid_digit_count"}
+	}
+	rec = env.do(t, "PUT", "/api/v1/meters/water", map[string]any{"digitCount": 40})
+	if rec.Code != http.StatusBadRequest || decode(t, rec).Error != "errors.meter.invalid_digit_count" {
+		t.Errorf("status = %d, body=%s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestMeterHandler_Delete(t *testing.T) {
+	env := newTestEnv(t)
+	rec := env.do(t, "DELETE", "/api/v1/meters/gas", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	if env.meters.lastMeter != models.MeterTypeGas {
+		t.Errorf("meter type = %q", env.meters.lastMeter)
+	}
+	if got := decode(t, rec).Message; got != "meters.deleted" {
+		t.Errorf("Message = %q", got)
+	}
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 
@@ -29,7 +30,7 @@ func (h *OCRHandler) Process(c *gin.Context) {
 		return
 	}
 
-	resp, err := h.ocr.Process(c.Request.Context(), &req)
+	resp, err := h.ocr.Process(c.Request.Context(), middleware.GetUID(c), &req)
 	if err != nil {
 		_ = c.Error(err)
 		return
@@ -40,3 +41,17 @@ func (h *OCRHandler) Process(c *gin.Context) {
 		Message: "ocr.processed",
 	})
 }
+
+// GET /api/v1/ocr/report?meterType=water&days=30
+//
+// Compares the prompt variants of a meter type across every user; only the
+// uids in OCR_REPORT_UIDS may see it.
+func (h *OCRHandler) Report(c *gin.Context) {
+	days, _ := strconv.Atoi(c.Query("days"))
+	report, err := h.ocr.Report(c.Request.Context(), middleware.GetUID(c), models.MeterType(c.Query("meterType")), days)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, models.ApiResponse{Success: true, Data: report})
+}
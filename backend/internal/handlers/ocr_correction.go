@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"wattrent/internal/middleware"
+	"wattrent/internal/models"
+)
+
+type OCRCorrectionHandler struct {
+	corrections ocrCorrectionStore
+}
+
+func NewOCRCorrectionHandler(corrections ocrCorrectionStore) *OCRCorrectionHandler {
+	return &OCRCorrectionHandler{corrections: corrections}
+}
+
+// GET /api/v1/ocr/corrections
+//
+// The corrected readings kept as examples for the model, newest first.
+func (h *OCRCorrectionHandler) List(c *gin.Context) {
+	corrections, err := h.corrections.List(c.Request.Context(), middleware.GetUID(c))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, models.ApiResponse{Success: true, Data: corrections})
+}
+
+// DELETE /api/v1/ocr/corrections/:attemptId
+func (h *OCRCorrectionHandler) Delete(c *gin.Context) {
+	if err := h.corrections.Delete(c.Request.Context(), middleware.GetUID(c), c.Param("attemptId")); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, models.ApiResponse{Success: true, Message: "ocr.correction_deleted"})
+}
+
+// DELETE /api/v1/ocr/corrections
+func (h *OCRCorrectionHandler) DeleteAll(c *gin.Context) {
+	if err := h.corrections.DeleteAll(c.Request.Context(), middleware.GetUID(c)); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, models.ApiResponse{Success: true, Message: "ocr.corrections_deleted"})
+}
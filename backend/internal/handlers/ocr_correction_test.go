@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"wattrent/internal/models"
+)
+
+func TestOCRCorrectionHandler_List(t *testing.T) {
+	env := newTestEnv(t)
+	env.corrections.listFn = func(ctx context.Context, uid string) ([]*models.OCRCorrection, error) {
+		return []*models.OCRCorrection{{AttemptID: "a1", ModelReading: 36084, CorrectedReading: 36034}}, nil
+	}
+	rec := env.do(t, "GET", "/api/v1/ocr/corrections", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body=%s", rec.Code, rec.Body.String())
+	}
+	var corrections []models.OCRCorrection
+	dataAs(t, decode(t, rec), &corrections)
+	if len(corrections) != 1 || corrections[0].AttemptID != "a1" || corrections[0].CorrectedReading != 36034 {
+		t.Errorf("corrections = %+v", corrections)
+	}
+}
+
+func TestOCRCorrectionHandler_Delete(t *testing.T) {
+	env := newTestEnv(t)
+	rec := env.do(t, "DELETE", "/api/v1/ocr/corrections/a1", nil)
+	if rec.Code != http.StatusOK || env.corrections.lastDeleted != "a1" {
+		t.Fatalf("status = %d, deleted %q", rec.Code, env.corrections.lastDeleted)
+	}
+	if got := decode(t, rec).Message; got != "ocr.correction_deleted" {
+		t.Errorf("Message = %q", got)
+	}
+
+	rec = env.do(t, "DELETE", "/api/v1/ocr/corrections", nil)
+	if rec.Code != http.StatusOK || !env.corrections.deletedAll {
+		t.Fatalf("status = %d, deleted all = %v", rec.Code, env.corrections.deletedAll)
+	}
+	if got := decode(t, rec).Message; got != "ocr.corrections_deleted" {
+		t.Errorf("Message = %q", got)
+	}
+}
@@ -33,6 +33,9 @@ func TestOCRHandler_Process_Success(t *testing.T) {
 	if env2.Message != "ocr.processed" {
 		t.Errorf("Message = %q", env2.Message)
 	}
+	if env.ocr.lastUID != "test-uid" {
+		t.Errorf("uid = %q, want test-uid", env.ocr.lastUID)
+	}
 }
 
 func TestOCRHandler_Process_LowConfidencePropagates(t *testing.T) {
@@ -67,3 +70,42 @@ func TestOCRHandler_Process_UpstreamFailureMapsTo502(t *testing.T) {
 		t.Errorf("Error = %q", got)
 	}
 }
+
+func TestOCRHandler_Report(t *testing.T) {
+	env := newTestEnv(t)
+	env.ocr.reportFn = func(ctx context.Context, mt models.MeterType, days int) (*models.OCRReport, error) {
+		if mt != models.MeterTypeWater || days != 14 {
+			t.Errorf("meterType = %q, days = %d; want water, 14", mt, days)
+		}
+		return &models.OCRReport{MeterType: mt, Variants: []models.OCRVariantStats{
+			{PromptVersion: "water-2", Attempts: 10, Resolved: 8, Accepted: 6, Corrected: 2, AcceptanceRate: 0.75},
+			{PromptVersion: "water-3a", Attempts: 4, Resolved: 4, Accepted: 4, AcceptanceRate: 1},
+		}}, nil
+	}
+	rec := env.do(t, "GET", "/api/v1/ocr/report?meterType=water&days=14", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body=%s", rec.Code, rec.Body.String())
+	}
+	var out models.OCRReport
+	dataAs(t, decode(t, rec), &out)
+	if len(out.Variants) != 2 || out.Variants[1].PromptVersion != "water-3a" || out.Variants[0].AcceptanceRate != 0.75 {
+		t.Errorf("out = %+v", out)
+	}
+	if env.ocr.lastUID != "test-uid" {
+		t.Errorf("uid = %q, want test-uid", env.ocr.lastUID)
+	}
+}
+
+func TestOCRHandler_Report_ForbiddenPropagates(t *testing.T) {
+	env := newTestEnv(t)
+	env.ocr.reportFn = func(ctx context.Context, mt models.MeterType, days int) (*models.OCRReport, error) {
+		return nil, &middleware.AppError{HTTPStatus: http.StatusForbidden, Key: "errors.ocr.report_forbidden"}
+	}
+	rec := env.do(t, "GET", "/api/v1/ocr/report", nil)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want 403", rec.Code)
+	}
+	if got := decode(t, rec).Error; got != "errors.ocr.report_forbidden" {
+		t.Errorf("Error = %q", got)
+	}
+}
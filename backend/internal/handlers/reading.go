@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"wattrent/internal/middleware"
+	"wattrent/internal/models"
+)
+
+type ReadingHandler struct {
+	readings readingStore
+}
+
+func NewReadingHandler(readings readingStore) *ReadingHandler {
+	return &ReadingHandler{readings: readings}
+}
+
+// POST /api/v1/readings
+//
+// Body:
+//
+//	{ "meterType": "electricity", "value": 36034, "takenAt": "2026-05-14T08:30:00+08:00",
+//	  "imageUrl": "gs://...", "ocr": { ... } }  // everything but value is optional
+func (h *ReadingHandler) Create(c *gin.Context) {
+	var req models.CreateReadingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(&middleware.AppError{HTTPStatus: http.StatusBadRequest, Key: "errors.bad_request", Cause: err})
+		return
+	}
+
+	reading, err := h.readings.Create(c.Request.Context(), middleware.GetUID(c), &req)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, models.ApiResponse{
+		Success: true,
+		Data:    reading,
+		Message: "readings.created",
+	})
+}
+
+// GET /api/v1/readings?meterType=water&limit=50
+func (h *ReadingHandler) List(c *gin.Context) {
+	meterType := models.MeterType(c.Query("meterType"))
+	switch meterType {
+	case "", models.MeterTypeElectricity, models.MeterTypeWater, models.MeterTypeGas:
+	default:
+		_ = c.Error(&middleware.AppError{HTTPStatus: http.StatusBadRequest, Key: "errors.bad_request"})
+		return
+	}
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	readings, err := h.readings.List(c.Request.Context(), middleware.GetUID(c), meterType, limit)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, models.ApiResponse{Success: true, Data: readings})
+}
+
+// DELETE /api/v1/readings/:id
+func (h *ReadingHandler) Delete(c *gin.Context) {
+	if err := h.readings.Delete(c.Request.Context(), middleware.GetUID(c), c.Param("id")); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, models.ApiResponse{Success: true, Message: "readings.deleted"})
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"wattrent/internal/middleware"
+	"wattrent/internal/models"
+)
+
+func TestReadingHandler_Create(t *testing.T) {
+	env := newTestEnv(t)
+	env.readings.createFn = func(ctx context.Context, uid string, req *models.CreateReadingRequest) (*models.Reading, error) {
+		if uid != "test-uid" {
+			t.Errorf("uid = %q", uid)
+		}
+		if req.MeterType != models.MeterTypeWater || req.Value != 842 || req.TakenAt == nil {
+			t.Errorf("req = %+v", req)
+		}
+		return &models.Reading{ID: "r1", MeterType: req.MeterType, Value: req.Value}, nil
+	}
+	rec := env.do(t, "POST", "/api/v1/readings", map[string]any{
+		"meterType": "water",
+		"value":     842,
+		"takenAt":   "2026-05-14T08:30:00+08:00",
+	})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d, body=%s", rec.Code, rec.Body.String())
+	}
+	env2 := decode(t, rec)
+	var r models.Reading
+	dataAs(t, env2, &r)
+	if r.ID != "r1" || env2.Message != "readings.created" {
+		t.Errorf("reading = %+v, message = %q", r, env2.Message)
+	}
+}
+
+func TestReadingHandler_Create_RejectsBadInput(t *testing.T) {
+	env := newTestEnv(t)
+	for name, body := range map[string]map[string]any{
+		"negative value":     {"value": -1},
+		"unknown meter type": {"meterType": "steam", "value": 1},
+	} {
+		rec := env.do(t, "POST", "/api/v1/readings", body)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", name, rec.Code)
+		}
+	}
+}
+
+func TestReadingHandler_List_PassesFilter(t *testing.T) {
+	env := newTestEnv(t)
+	env.readings.listFn = func(ctx context.Context, uid string, meterType models.MeterType, limit int) ([]*models.Reading, error) {
+		if meterType != models.MeterTypeGas || limit != 20 {
+			t.Errorf("meterType = %q, limit = %d", meterType, limit)
+		}
+		return []*models.Reading{{ID: "r1"}, {ID: "r2"}}, nil
+	}
+	rec := env.do(t, "GET", "/api/v1/readings?meterType=gas&limit=20", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body=%s", rec.Code, rec.Body.String())
+	}
+	var readings []models.Reading
+	dataAs(t, decode(t, rec), &readings)
+	if len(readings) != 2 {
+		t.Errorf("readings = %+v", readings)
+	}
+
+	if rec := env.do(t, "GET", "/api/v1/readings?meterType=steam", nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown meterType status = %d, want 400", rec.Code)
+	}
+}
+
+func TestReadingHandler_Delete_InUse(t *testing.T) {
+	env := newTestEnv(t)
+	env.readings.deleteFn = func(ctx context.Context, uid, readingID string) error {
+		return &middleware.AppError{HTTPStatus: http.StatusConflict, Key: "errors.reading.used_by_bill"}
+	}
+	rec := env.do(t, "DELETE", "/api/v1/readings/r9", nil)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("status = %d, want 409", rec.Code)
+	}
+	if env.readings.lastID != "r9" {
+		t.Errorf("lastID = %q", env.readings.lastID)
+	}
+	if got := decode(t, rec).Error; got != "errors.reading.used_by_bill" {
+		t.Errorf("Error = %q", got)
+	}
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"wattrent/internal/middleware"
+	"wattrent/internal/models"
+)
+
+type RoomHandler struct {
+	rooms roomStore
+}
+
+func NewRoomHandler(rooms roomStore) *RoomHandler {
+	return &RoomHandler{rooms: rooms}
+}
+
+// GET /api/v1/rooms
+func (h *RoomHandler) List(c *gin.Context) {
+	rooms, err := h.rooms.List(c.Request.Context(), middleware.GetUID(c))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, models.ApiResponse{Success: true, Data: rooms})
+}
+
+// POST /api/v1/rooms
+//
+// Body:
+//
+//	{ "label": "3F", "serial": "7730042", "position": 2 }  // serial or position
+func (h *RoomHandler) Create(c *gin.Context) {
+	var req models.SaveRoomRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(&middleware.AppError{HTTPStatus: http.StatusBadRequest, Key: "errors.bad_request", Cause: err})
+		return
+	}
+	room, err := h.rooms.Create(c.Request.Context(), middleware.GetUID(c), &req)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, models.ApiResponse{
+		Success: true,
+		Data:    room,
+		Message: "rooms.saved",
+	})
+}
+
+// PUT /api/v1/rooms/:id  (full overwrite, same body as POST)
+func (h *RoomHandler) Update(c *gin.Context) {
+	var req models.SaveRoomRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(&middleware.AppError{HTTPStatus: http.StatusBadRequest, Key: "errors.bad_request", Cause: err})
+		return
+	}
+	room, err := h.rooms.Update(c.Request.Context(), middleware.GetUID(c), c.Param("id"), &req)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Success: true,
+		Data:    room,
+		Message: "rooms.saved",
+	})
+}
+
+// DELETE /api/v1/rooms/:id
+func (h *RoomHandler) Delete(c *gin.Context) {
+	if err := h.rooms.Delete(c.Request.Context(), middleware.GetUID(c), c.Param("id")); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, models.ApiResponse{Success: true, Message: "rooms.deleted"})
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"wattrent/internal/middleware"
+	"wattrent/internal/models"
+)
+
+func TestRoomHandler_Create(t *testing.T) {
+	env := newTestEnv(t)
+	rec := env.do(t, "POST", "/api/v1/rooms", map[string]any{"label": "3F", "serial": "7730042"})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d, body=%s", rec.Code, rec.Body.String())
+	}
+	var room models.Room
+	dataAs(t, decode(t, rec), &room)
+	if room.ID == "" || room.Label != "3F" || room.Serial != "7730042" {
+		t.Errorf("room = %+v", room)
+	}
+
+	env.rooms.createFn = func(ctx context.Context, uid string, req *models.SaveRoomRequest) (*models.Room, error) {
+		return nil, &middleware.AppError{HTTPStatus: 409, Key: "errors.room.label_taken"}
+	}
+	rec = env.do(t, "POST", "/api/v1/rooms", map[string]any{"label": "3F", "position": 2})
+	if rec.Code != http.StatusConflict || decode(t, rec).Error != "errors.room.label_taken" {
+		t.Errorf("status = %d, body=%s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestRoomHandler_Update(t *testing.T) {
+	env := newTestEnv(t)
+	rec := env.do(t, "PUT", "/api/v1/rooms/room-7", map[string]any{"label": "4F", "position": 3})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body=%s", rec.Code, rec.Body.String())
+	}
+	if env.rooms.lastRoom != "room-7" {
+		t.Errorf("room = %q, want room-7 from the path", env.rooms.lastRoom)
+	}
+
+	env.rooms.updateFn = func(ctx context.Context, uid, roomID string, req *models.SaveRoomRequest) (*models.Room, error) {
+		return nil, &middleware.AppError{HTTPStatus: 404, Key: "errors.room.not_found"}
+	}
+	rec = env.do(t, "PUT", "/api/v1/rooms/gone", map[string]any{"label": "4F", "position": 3})
+	if rec.Code != http.StatusNotFound || decode(t, rec).Error != "errors.room.not_found" {
+		t.Errorf("status = %d, body=%s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestRoomHandler_Delete(t *testing.T) {
+	env := newTestEnv(t)
+	rec := env.do(t, "DELETE", "/api/v1/rooms/room-7", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	if env.rooms.lastRoom != "room-7" {
+		t.Errorf("room = %q", env.rooms.lastRoom)
+	}
+	if got := decode(t, rec).Message; got != "rooms.deleted" {
+		t.Errorf("Message = %q", got)
+	}
+}
@@ -6,12 +6,13 @@ import (
 	"testing"
 
 	"wattrent/internal/models"
+	"wattrent/internal/money"
 )
 
 func TestSettingsHandler_Get(t *testing.T) {
 	env := newTestEnv(t)
 	env.settings.getFn = func(ctx context.Context, uid string) (*models.UserSettings, error) {
-		return &models.UserSettings{DefaultElectricityRate: 5.5, DefaultRent: 9000}, nil
+		return &models.UserSettings{DefaultElectricityRate: 5.5, DefaultRent: money.FromMajor(9000)}, nil
 	}
 	rec := env.do(t, "GET", "/api/v1/settings", nil)
 	if rec.Code != http.StatusOK {
@@ -19,7 +20,7 @@ func TestSettingsHandler_Get(t *testing.T) {
 	}
 	var s models.UserSettings
 	dataAs(t, decode(t, rec), &s)
-	if s.DefaultElectricityRate != 5.5 || s.DefaultRent != 9000 {
+	if s.DefaultElectricityRate != 5.5 || s.DefaultRent != money.FromMajor(9000) {
 		t.Errorf("settings = %+v", s)
 	}
 }
@@ -54,10 +55,10 @@ func TestSettingsHandler_Save(t *testing.T) {
 func TestSettingsHandler_Patch(t *testing.T) {
 	env := newTestEnv(t)
 	env.settings.patchFn = func(ctx context.Context, uid string, req *models.UpdateSettingsRequest) (*models.UserSettings, error) {
-		if req.DefaultRent == nil || *req.DefaultRent != 12000 {
+		if req.DefaultRent == nil || *req.DefaultRent != money.FromMajor(12000) {
 			t.Errorf("req.DefaultRent = %v", req.DefaultRent)
 		}
-		return &models.UserSettings{DefaultRent: 12000}, nil
+		return &models.UserSettings{DefaultRent: money.FromMajor(12000)}, nil
 	}
 	rec := env.do(t, "PATCH", "/api/v1/settings", map[string]any{"defaultRent": 12000})
 	if rec.Code != http.StatusOK {
@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"wattrent/internal/middleware"
+	"wattrent/internal/models"
+)
+
+type StatsHandler struct {
+	emissions emissionStatter
+}
+
+func NewStatsHandler(emissions emissionStatter) *StatsHandler {
+	return &StatsHandler{emissions: emissions}
+}
+
+// GET /api/v1/stats/emissions?years=5
+func (h *StatsHandler) Emissions(c *gin.Context) {
+	years, _ := strconv.Atoi(c.Query("years"))
+	stats, err := h.emissions.EmissionStats(c.Request.Context(), middleware.GetUID(c), years)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, models.ApiResponse{Success: true, Data: stats})
+}
@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"wattrent/internal/models"
+)
+
+func TestStatsHandler_Emissions(t *testing.T) {
+	env := newTestEnv(t)
+	pct := -4.2
+	env.stats.statsFn = func(ctx context.Context, uid string, years int) (*models.EmissionStats, error) {
+		if uid != "test-uid" {
+			t.Errorf("uid = %q", uid)
+		}
+		return &models.EmissionStats{Region: "TW", Years: []models.YearlyEmissions{
+			{Year: 2025, Bills: 12, KgCO2e: 1100},
+			{Year: 2026, Bills: 9, KgCO2e: 1053.8, ChangePct: &pct},
+		}}, nil
+	}
+	rec := env.do(t, "GET", "/api/v1/stats/emissions?years=3", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body=%s", rec.Code, rec.Body.String())
+	}
+	if env.stats.lastYears != 3 {
+		t.Errorf("years = %d, want 3", env.stats.lastYears)
+	}
+	var stats models.EmissionStats
+	dataAs(t, decode(t, rec), &stats)
+	if len(stats.Years) != 2 || stats.Years[1].ChangePct == nil || *stats.Years[1].ChangePct != pct {
+		t.Errorf("stats = %+v", stats)
+	}
+}
@@ -7,6 +7,7 @@ import (
 
 	"wattrent/internal/middleware"
 	"wattrent/internal/models"
+	"wattrent/internal/services"
 )
 
 type UploadHandler struct {
@@ -23,7 +24,8 @@ func NewUploadHandler(storage uploadSigner) *UploadHandler {
 // to GCS.
 //
 // Flow:
-//  1. Frontend POSTs {billId, contentType} here.
+//  1. Frontend POSTs {billId, contentType} here. billId is 1-64 letters,
+//     digits or dashes (see services.ValidBillID).
 //  2. Backend returns {uploadUrl, gcsPath, expiresAt}.
 //  3. Frontend PUTs the image to uploadUrl (HTTP body = binary).
 //  4. Frontend POSTs /bills with imageUrl=gcsPath.
@@ -33,6 +35,10 @@ func (h *UploadHandler) Sign(c *gin.Context) {
 		_ = c.Error(&middleware.AppError{HTTPStatus: http.StatusBadRequest, Key: "errors.bad_request", Cause: err})
 		return
 	}
+	if !services.ValidBillID(req.BillID) {
+		_ = c.Error(&middleware.AppError{HTTPStatus: http.StatusBadRequest, Key: "errors.upload.invalid_bill_id"})
+		return
+	}
 
 	uid := middleware.GetUID(c)
 	uploadURL, gcsPath, expiresAt, err := h.storage.SignedUploadURL(c.Request.Context(), uid, req.BillID, req.ContentType)
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"net/http"
+	"strings"
 	"testing"
 	"time"
 
@@ -47,6 +48,26 @@ func TestUploadHandler_Sign_BadRequest(t *testing.T) {
 	}
 }
 
+func TestUploadHandler_Sign_InvalidBillID(t *testing.T) {
+	env := newTestEnv(t)
+	env.uploads.signFn = func(ctx context.Context, uid, billID, contentType string) (string, string, time.Time, error) {
+		t.Errorf("signed an upload for %q", billID)
+		return "", "", time.Time{}, nil
+	}
+	for _, id := range []string{"b_thumb", "a/b", "b.jpg", strings.Repeat("a", 65)} {
+		rec := env.do(t, "POST", "/api/v1/uploads/sign", map[string]any{
+			"billId":      id,
+			"contentType": "image/jpeg",
+		})
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("billId %q: status = %d, want 400", id, rec.Code)
+		}
+		if got := decode(t, rec).Error; got != "errors.upload.invalid_bill_id" {
+			t.Errorf("billId %q: Error = %q", id, got)
+		}
+	}
+}
+
 func TestUploadHandler_Sign_StorageError(t *testing.T) {
 	env := newTestEnv(t)
 	env.uploads.signFn = func(ctx context.Context, uid, billID, contentType string) (string, string, time.Time, error) {
@@ -4,9 +4,18 @@
 //   - Both JSON and Firestore field names use camelCase
 //   - Firestore documents do not store userId (it is part of the path)
 //   - Document IDs are exposed via Go ID string `firestore:"-"` and never written into the doc data
+//   - Money is money.Amount (integer hundredths). It is stored under a
+//     "...Minor" Firestore field but keeps the original JSON name and number
+//     shape. The original float64 Firestore field is still written alongside
+//     it (Legacy*, json:"-") so older documents and older backend revisions
+//     keep reading correctly; see services.migrateLegacyBill.
 package models
 
-import "time"
+import (
+	"time"
+
+	"wattrent/internal/money"
+)
 
 // PaymentMethod is the payment method (frontend i18n key).
 type PaymentMethod string
@@ -19,6 +28,86 @@ const (
 	PaymentMethodOther        PaymentMethod = "other"
 )
 
+// BillingCycle is how often the landlord bills (frontend i18n key).
+//
+// Taipower bills many households every two months, so a bimonthly bill covers
+// two calendar months of usage. Both cycles can be shifted off the 1st of the
+// month with UserSettings.BillingAnchorDay (e.g. leases that run 15th to 15th).
+type BillingCycle string
+
+const (
+	BillingCycleMonthly   BillingCycle = "monthly"
+	BillingCycleBimonthly BillingCycle = "bimonthly"
+)
+
+// BillStatus is empty for a normal bill. A draft was created from a photo
+// whose reading still needs the user's confirmation (see
+// BillService.CreateFromPhoto).
+type BillStatus string
+
+const BillStatusDraft BillStatus = "draft"
+
+// MeterType identifies what a meter measures (frontend i18n key). Empty means
+// electricity: everything written before water and gas were supported is.
+type MeterType string
+
+const (
+	MeterTypeElectricity MeterType = "electricity"
+	MeterTypeWater       MeterType = "water"
+	MeterTypeGas         MeterType = "gas"
+)
+
+// MeterDisplay is how a meter shows its main register.
+type MeterDisplay string
+
+const (
+	MeterDisplayMechanical MeterDisplay = "mechanical" // digit wheels
+	MeterDisplayLCD        MeterDisplay = "lcd"
+)
+
+// MeterProfileSource says who last wrote a MeterProfile.
+type MeterProfileSource string
+
+const (
+	// MeterProfileSourceOCR: learned from the first confident OCR read.
+	MeterProfileSourceOCR MeterProfileSource = "ocr"
+	// MeterProfileSourceUser: edited by the user; OCR never overwrites it.
+	MeterProfileSourceUser MeterProfileSource = "user"
+)
+
+// MeterProfile describes one of the user's meters, so OCR can be told what
+// it is looking at and its answers checked against it. Zero fields are
+// unknown.
+// Path: /users/{uid}/meters/{meterType} (document ID = meter type)
+type MeterProfile struct {
+	MeterType MeterType `firestore:"meterType" json:"meterType"`
+	// DigitCount is the number of digits of the main register, leading
+	// zeros included and fraction digits excluded.
+	DigitCount int          `firestore:"digitCount,omitempty" json:"digitCount,omitempty"`
+	Display    MeterDisplay `firestore:"display,omitempty"    json:"display,omitempty"`
+	// DecimalDrum is a red (or otherwise marked) fraction wheel or digit
+	// after the register, which is not part of the reading.
+	DecimalDrum bool               `firestore:"decimalDrum"          json:"decimalDrum"`
+	Model       string             `firestore:"model,omitempty"      json:"model,omitempty"`
+	Serial      string             `firestore:"serial,omitempty"     json:"serial,omitempty"`
+	Source      MeterProfileSource `firestore:"source"               json:"source"`
+	UpdatedAt   time.Time          `firestore:"updatedAt"            json:"updatedAt"`
+}
+
+// Room is a room (or flat, or property) the user bills from one meter of a
+// meter bank. A bank read maps its meters to the user's rooms: by Serial or,
+// when none of the meters has that serial, by Position on the photo (see
+// OCRBankMeter.Position).
+// Path: /users/{uid}/rooms/{roomId}
+type Room struct {
+	ID        string    `firestore:"-"                  json:"id"`
+	Label     string    `firestore:"label"              json:"label"`
+	Serial    string    `firestore:"serial,omitempty"   json:"serial,omitempty"`
+	Position  int       `firestore:"position,omitempty" json:"position,omitempty"`
+	CreatedAt time.Time `firestore:"createdAt"          json:"createdAt"`
+	UpdatedAt time.Time `firestore:"updatedAt"          json:"updatedAt"`
+}
+
 // User is the user document (document ID = Firebase Auth uid).
 // Path: /users/{uid}
 type User struct {
@@ -35,33 +124,62 @@ type User struct {
 
 // UserSettings is the user settings document.
 // Path: /users/{uid}/settings/current (the document ID is always "current")
+//
+// DefaultElectricityRate stays float64 on purpose: it is a unit price (NT$ per
+// kWh, often with more decimals than the currency has), not an amount owed.
 type UserSettings struct {
-	DefaultElectricityRate float64       `firestore:"defaultElectricityRate" json:"defaultElectricityRate"`
-	DefaultRent            float64       `firestore:"defaultRent"            json:"defaultRent"`
-	PreviousMeterReading   float64       `firestore:"previousMeterReading"   json:"previousMeterReading"`
-	LandlordName           string        `firestore:"landlordName"           json:"landlordName,omitempty"`
-	PaymentMethod          PaymentMethod `firestore:"paymentMethod"          json:"paymentMethod,omitempty"`
+	DefaultElectricityRate float64        `firestore:"defaultElectricityRate" json:"defaultElectricityRate"`
+	DefaultRent            money.Amount   `firestore:"defaultRentMinor"       json:"defaultRent"`
+	LegacyDefaultRent      float64        `firestore:"defaultRent"            json:"-"`
+	Currency               money.Currency `firestore:"currency"               json:"currency,omitempty"`
+	PreviousMeterReading   float64        `firestore:"previousMeterReading"   json:"previousMeterReading"`
+	LandlordName           string         `firestore:"landlordName"           json:"landlordName,omitempty"`
+	PaymentMethod          PaymentMethod  `firestore:"paymentMethod"          json:"paymentMethod,omitempty"`
 	// MessageTemplate is the user-editable share text. Empty -> the frontend
 	// falls back to its localized default. Supports {period} {meterReading}
 	// {usage} {rate} {electricityCost} {rent} {totalAmount} placeholders.
 	MessageTemplate string `firestore:"messageTemplate" json:"messageTemplate,omitempty"`
+	// BillingCycle and BillingAnchorDay decide how a "YYYY-MM" period maps to
+	// a start/end date on new bills. AnchorDay is 1..28; 0 means the 1st.
+	BillingCycle     BillingCycle `firestore:"billingCycle"     json:"billingCycle,omitempty"`
+	BillingAnchorDay int          `firestore:"billingAnchorDay" json:"billingAnchorDay,omitempty"`
+	// Timezone is an IANA zone name (e.g. "Asia/Taipei", "Europe/Berlin").
+	// Period boundaries are computed in it. Empty means Asia/Taipei.
+	Timezone string `firestore:"timezone" json:"timezone,omitempty"`
+	// Region picks the grid emission factor for the carbon estimate on new
+	// bills (ISO 3166-1 alpha-2, e.g. "TW"). Empty means TW.
+	Region string `firestore:"region" json:"region,omitempty"`
+	// PreviousReadings is the reading chain of each water / gas meter, keyed by
+	// meter type; electricity keeps using PreviousMeterReading. DefaultUtilityRates
+	// is the matching price per m³.
+	PreviousReadings    map[MeterType]float64 `firestore:"previousReadings,omitempty"    json:"previousReadings,omitempty"`
+	DefaultUtilityRates map[MeterType]float64 `firestore:"defaultUtilityRates,omitempty" json:"defaultUtilityRates,omitempty"`
 	// SetupCompleted flips to true once the user saves their defaults the first
 	// time; the app uses it to gate the capture flow behind onboarding.
-	SetupCompleted       bool      `firestore:"setupCompleted"       json:"setupCompleted"`
-	Language             string    `firestore:"language"             json:"language,omitempty"`
-	NotificationsEnabled bool      `firestore:"notificationsEnabled" json:"notificationsEnabled"`
-	AutoBackup           bool      `firestore:"autoBackup"           json:"autoBackup"`
-	UpdatedAt            time.Time `firestore:"updatedAt"            json:"updatedAt"`
+	SetupCompleted       bool   `firestore:"setupCompleted"       json:"setupCompleted"`
+	Language             string `firestore:"language"             json:"language,omitempty"`
+	NotificationsEnabled bool   `firestore:"notificationsEnabled" json:"notificationsEnabled"`
+	AutoBackup           bool   `firestore:"autoBackup"           json:"autoBackup"`
+	// OCRLearnFromCorrections keeps the photos of OCR readings the user
+	// corrected as examples for later reads of the same meter (OCRCorrection).
+	// Off by default; turning it off deletes the stored corrections.
+	OCRLearnFromCorrections bool      `firestore:"ocrLearnFromCorrections" json:"ocrLearnFromCorrections"`
+	UpdatedAt               time.Time `firestore:"updatedAt"               json:"updatedAt"`
 }
 
 // DefaultUserSettings is the default value returned the first time a user reads settings.
 func DefaultUserSettings() UserSettings {
 	return UserSettings{
 		DefaultElectricityRate: 4.5,
-		DefaultRent:            8000,
+		DefaultRent:            money.FromMajor(8000),
+		Currency:               money.DefaultCurrency,
 		PreviousMeterReading:   0,
 		LandlordName:           "",
 		PaymentMethod:          PaymentMethodBankTransfer,
+		BillingCycle:           BillingCycleMonthly,
+		BillingAnchorDay:       1,
+		Timezone:               "Asia/Taipei",
+		Region:                 "TW",
 		Language:               "",
 		NotificationsEnabled:   true,
 		AutoBackup:             false,
@@ -69,27 +187,329 @@ func DefaultUserSettings() UserSettings {
 }
 
 // OCRResult records an OCR model's reading for a given image (embedded in Bill).
+// AttemptID points at the full OCRAttempt record, when there is one.
 type OCRResult struct {
-	Confidence  float64   `firestore:"confidence"   json:"confidence"`
-	Model       string    `firestore:"model"         json:"model"`
-	RawText     string    `firestore:"rawText"      json:"rawText,omitempty"`
-	ProcessedAt time.Time `firestore:"processedAt"  json:"processedAt"`
+	Confidence    float64   `firestore:"confidence"              json:"confidence"`
+	Model         string    `firestore:"model"                   json:"model"`
+	RawText       string    `firestore:"rawText"                 json:"rawText,omitempty"`
+	ProcessedAt   time.Time `firestore:"processedAt"             json:"processedAt"`
+	PromptVersion string    `firestore:"promptVersion,omitempty" json:"promptVersion,omitempty"`
+	AttemptID     string    `firestore:"attemptId,omitempty"     json:"attemptId,omitempty"`
+	// Warnings are the attempt's; a draft bill shows why it is a draft.
+	Warnings []OCRWarning `firestore:"warnings,omitempty" json:"warnings,omitempty"`
+	// MeterPosition is the OCRBankMeter of a bank attempt the reading is.
+	MeterPosition int `firestore:"meterPosition,omitempty" json:"meterPosition,omitempty"`
+}
+
+// OCRWarning flags an OCR answer the user must look at before it is billed.
+type OCRWarning string
+
+const (
+	// OCRWarningWrongMeter: the serial number on the photo is not the one
+	// of the user's MeterProfile, e.g. the neighbour's meter.
+	OCRWarningWrongMeter OCRWarning = "wrong_meter"
+)
+
+// OCRAttemptOutcome is what the user did with an OCR reading. Empty while the
+// attempt is not used by a bill yet (or the bill is an unconfirmed draft).
+type OCRAttemptOutcome string
+
+const (
+	OCRAttemptAccepted  OCRAttemptOutcome = "accepted"
+	OCRAttemptCorrected OCRAttemptOutcome = "corrected"
+)
+
+// OCRAttempt is the audit record of one OCR call, successful or not.
+// Path: /users/{uid}/ocrAttempts/{attemptId}
+//
+// ImageURL is the uploaded photo (empty for inline base64 images). Model is
+// the provider that answered; Fallbacks the ones that failed before it. Error is
+// the i18n key of a failed attempt. BillID, Outcome and FinalReading are set
+// when a bill is created from the attempt; comparing Reading with
+// FinalReading across attempts measures model accuracy. Examples are the
+// OCRCorrections (by attempt ID) shown to the model along with the photo.
+type OCRAttempt struct {
+	ID            string            `firestore:"-"                       json:"id"`
+	MeterType     MeterType         `firestore:"meterType"               json:"meterType"`
+	ImageURL      string            `firestore:"imageUrl,omitempty"      json:"imageUrl,omitempty"`
+	Model         string            `firestore:"model"                   json:"model"`
+	Fallbacks     []string          `firestore:"fallbacks,omitempty"     json:"fallbacks,omitempty"`
+	Escalation    *OCREscalation    `firestore:"escalation,omitempty"    json:"escalation,omitempty"`
+	Consensus     *OCRConsensus     `firestore:"consensus,omitempty"     json:"consensus,omitempty"`
+	Cached        bool              `firestore:"cached,omitempty"        json:"cached,omitempty"`
+	Quality       *OCRPhotoQuality  `firestore:"quality,omitempty"       json:"quality,omitempty"`
+	Digits        []OCRDigit        `firestore:"digits,omitempty"        json:"digits,omitempty"`
+	RegisterBox   *OCRBox           `firestore:"registerBox,omitempty"   json:"registerBox,omitempty"`
+	Serial        string            `firestore:"serial,omitempty"        json:"serial,omitempty"`
+	Warnings      []OCRWarning      `firestore:"warnings,omitempty"      json:"warnings,omitempty"`
+	Meters        []OCRBankMeter    `firestore:"meters,omitempty"        json:"meters,omitempty"`
+	Examples      []string          `firestore:"examples,omitempty"      json:"examples,omitempty"`
+	PromptVersion string            `firestore:"promptVersion"           json:"promptVersion"`
+	Reading       float64           `firestore:"reading"                 json:"reading"`
+	Confidence    float64           `firestore:"confidence"              json:"confidence"`
+	RawText       string            `firestore:"rawText,omitempty"       json:"rawText,omitempty"`
+	Notes         string            `firestore:"notes,omitempty"         json:"notes,omitempty"`
+	LatencyMs     int64             `firestore:"latencyMs"               json:"latencyMs"`
+	Error         string            `firestore:"error,omitempty"         json:"error,omitempty"`
+	BillID        string            `firestore:"billId,omitempty"        json:"billId,omitempty"`
+	Outcome       OCRAttemptOutcome `firestore:"outcome,omitempty"       json:"outcome,omitempty"`
+	FinalReading  *float64          `firestore:"finalReading,omitempty"  json:"finalReading,omitempty"`
+	CreatedAt     time.Time         `firestore:"createdAt"               json:"createdAt"`
+	ResolvedAt    *time.Time        `firestore:"resolvedAt,omitempty"    json:"resolvedAt,omitempty"`
+}
+
+// OCREscalationReason is why an OCR call was retried with the stronger model.
+type OCREscalationReason string
+
+const (
+	// OCREscalationLowConfidence: the model's own confidence was too low.
+	OCREscalationLowConfidence OCREscalationReason = "low_confidence"
+	// OCREscalationImplausible: the reading was below the previous reading,
+	// or far more than usual above it.
+	OCREscalationImplausible OCREscalationReason = "implausible"
+	// OCREscalationDigitCount: the reading did not have the digit count of
+	// the user's MeterProfile.
+	OCREscalationDigitCount OCREscalationReason = "digit_count"
+)
+
+// OCRCandidate is one model's answer within an escalated OCRAttempt.
+type OCRCandidate struct {
+	Model      string  `firestore:"model"      json:"model"`
+	Reading    float64 `firestore:"reading"    json:"reading"`
+	Confidence float64 `firestore:"confidence" json:"confidence"`
+}
+
+// OCREscalation records that an OCR call was retried with the stronger model.
+// Retry is nil (and Error set) when the retry failed; UsedRetry says which
+// answer the attempt returned.
+type OCREscalation struct {
+	Reason    OCREscalationReason `firestore:"reason"          json:"reason"`
+	Initial   OCRCandidate        `firestore:"initial"         json:"initial"`
+	Retry     *OCRCandidate       `firestore:"retry,omitempty" json:"retry,omitempty"`
+	Error     string              `firestore:"error,omitempty" json:"error,omitempty"`
+	UsedRetry bool                `firestore:"usedRetry"       json:"usedRetry"`
+}
+
+// OCRConsensus is how a consensus reading (OCRRequest.Consensus) was reached:
+// every sample's answer, and per digit position, left to right, the share of
+// samples that voted for the agreed digit. Failed counts samples that got no
+// answer; they count as votes against every digit.
+type OCRConsensus struct {
+	Samples   []OCRCandidate `firestore:"samples"   json:"samples"`
+	Failed    int            `firestore:"failed"    json:"failed"`
+	Digits    string         `firestore:"digits"    json:"digits"`
+	Agreement []float64      `firestore:"agreement" json:"agreement"`
+}
+
+// OCRPhotoQuality is how a photo scored before OCR (see the quality options
+// of services.OCROptions). Absent for formats the server does not decode.
+type OCRPhotoQuality struct {
+	// Sharpness is the variance of the luminance Laplacian; blurred photos
+	// score low.
+	Sharpness float64 `firestore:"sharpness"  json:"sharpness"`
+	// Clipped is the share of blown-out pixels (glare, overexposure), 0-1.
+	Clipped float64 `firestore:"clipped"    json:"clipped"`
+	// Brightness is the mean luminance, 0 (black) to 1 (white).
+	Brightness float64 `firestore:"brightness" json:"brightness"`
+}
+
+// OCRDigit is one digit of a meter register as read by OCR.
+type OCRDigit struct {
+	Value      int     `firestore:"value"         json:"value"`
+	Confidence float64 `firestore:"confidence"    json:"confidence"`
+	Box        *OCRBox `firestore:"box,omitempty" json:"box,omitempty"`
+}
+
+// OCRBox is a region of the (upright) photo, as fractions of its width and
+// height from the top left corner.
+type OCRBox struct {
+	Left   float64 `firestore:"left"   json:"left"`
+	Top    float64 `firestore:"top"    json:"top"`
+	Right  float64 `firestore:"right"  json:"right"`
+	Bottom float64 `firestore:"bottom" json:"bottom"`
+}
+
+// OCRCacheEntry is a cached OCR answer, keyed by a hash of the image bytes,
+// the configured models, the prompt version and the previous reading.
+// Path: /users/{uid}/ocrCache/{key}; Firestore's TTL policy on expiresAt
+// deletes it eventually, and reads ignore it once ExpiresAt has passed.
+type OCRCacheEntry struct {
+	Reading     float64        `firestore:"reading"              json:"reading"`
+	Confidence  float64        `firestore:"confidence"           json:"confidence"`
+	RawText     string         `firestore:"rawText,omitempty"    json:"rawText,omitempty"`
+	Notes       string         `firestore:"notes,omitempty"      json:"notes,omitempty"`
+	Model       string         `firestore:"model"                json:"model"`
+	Escalation  *OCREscalation `firestore:"escalation,omitempty" json:"escalation,omitempty"`
+	Consensus   *OCRConsensus  `firestore:"consensus,omitempty"   json:"consensus,omitempty"`
+	Digits      []OCRDigit     `firestore:"digits,omitempty"      json:"digits,omitempty"`
+	RegisterBox *OCRBox        `firestore:"registerBox,omitempty" json:"registerBox,omitempty"`
+	Serial      string         `firestore:"serial,omitempty"      json:"serial,omitempty"`
+	Meters      []OCRBankMeter `firestore:"meters,omitempty"      json:"meters,omitempty"`
+	CreatedAt   time.Time      `firestore:"createdAt"            json:"createdAt"`
+	ExpiresAt   time.Time      `firestore:"expiresAt"            json:"expiresAt"`
+}
+
+// OCRCorrection is an OCR reading the user corrected, kept as a labelled
+// example of their meter for later reads (see OCRService.fewShotExamples).
+// Path: /users/{uid}/ocrCorrections/{attemptId}. Only kept while
+// UserSettings.OCRLearnFromCorrections is on, for photos in Cloud Storage;
+// Firestore's TTL policy on expiresAt deletes it eventually.
+type OCRCorrection struct {
+	AttemptID        string    `firestore:"-"                json:"attemptId"`
+	MeterType        MeterType `firestore:"meterType"        json:"meterType"`
+	ImageURL         string    `firestore:"imageUrl"         json:"imageUrl"`
+	ModelReading     float64   `firestore:"modelReading"     json:"modelReading"`
+	CorrectedReading float64   `firestore:"correctedReading" json:"correctedReading"`
+	PromptVersion    string    `firestore:"promptVersion"    json:"promptVersion"`
+	CreatedAt        time.Time `firestore:"createdAt"        json:"createdAt"`
+	ExpiresAt        time.Time `firestore:"expiresAt"        json:"expiresAt"`
+}
+
+// OCRPromptTemplate is a versioned OCR prompt: the instructions part of a
+// meter type's prompt, the part the built-in prompts keep in code.
+// Path: /ocrPrompts/{version}; written by hand (console or script) and never
+// edited once in use: a new wording is a new version.
+type OCRPromptTemplate struct {
+	Version   string    `firestore:"-"         json:"version"`
+	MeterType MeterType `firestore:"meterType" json:"meterType"`
+	Base      string    `firestore:"base"      json:"base"`
+	CreatedAt time.Time `firestore:"createdAt" json:"createdAt"`
+}
+
+// OCRExperiment assigns the users of one meter type to prompt variants.
+// Path: /ocrExperiments/{meterType}. Each variant gets Percent of the users,
+// bucketed by uid and Name (a new name reshuffles them); the rest keep the
+// built-in prompt.
+type OCRExperiment struct {
+	Name      string             `firestore:"name"      json:"name"`
+	Variants  []OCRPromptVariant `firestore:"variants"  json:"variants"`
+	UpdatedAt time.Time          `firestore:"updatedAt" json:"updatedAt"`
+}
+
+// OCRPromptVariant is one arm of an OCRExperiment: an OCRPromptTemplate
+// version and the share of users (0-100) who get it.
+type OCRPromptVariant struct {
+	Version string `firestore:"version" json:"version"`
+	Percent int    `firestore:"percent" json:"percent"`
+}
+
+// OCRReport compares the OCR attempts of one meter type since Since by
+// prompt variant. Truncated means only the newest attempts were counted.
+type OCRReport struct {
+	MeterType MeterType         `json:"meterType"`
+	Since     time.Time         `json:"since"`
+	Truncated bool              `json:"truncated,omitempty"`
+	Variants  []OCRVariantStats `json:"variants"`
+}
+
+// OCRVariantStats is one prompt variant's line of an OCRReport.
+// PromptVersion is the variant's version without mode suffixes such as
+// "+bank-1". Resolved attempts are the ones a bill settled: Accepted kept the
+// OCR value, Corrected changed it; the rates are over Resolved.
+type OCRVariantStats struct {
+	PromptVersion  string  `json:"promptVersion"`
+	Attempts       int     `json:"attempts"`
+	Failed         int     `json:"failed"`
+	Resolved       int     `json:"resolved"`
+	Accepted       int     `json:"accepted"`
+	Corrected      int     `json:"corrected"`
+	AcceptanceRate float64 `json:"acceptanceRate"`
+	CorrectionRate float64 `json:"correctionRate"`
+	AvgConfidence  float64 `json:"avgConfidence"`
+	AvgLatencyMs   int64   `json:"avgLatencyMs"`
+}
+
+// Reading is one entry in the reading log: a meter value at a point in time,
+// independent of any bill. Tenants log mid-period readings to watch their
+// consumption; a bill can then start and end on logged readings.
+// Path: /users/{uid}/readings/{readingId}
+type Reading struct {
+	ID        string     `firestore:"-"                  json:"id"`
+	MeterType MeterType  `firestore:"meterType"          json:"meterType"`
+	Value     float64    `firestore:"value"              json:"value"`
+	TakenAt   time.Time  `firestore:"takenAt"            json:"takenAt"`
+	ImageURL  string     `firestore:"imageUrl,omitempty" json:"imageUrl,omitempty"`
+	OCR       *OCRResult `firestore:"ocr,omitempty"      json:"ocr,omitempty"`
+	Note      string     `firestore:"note,omitempty"     json:"note,omitempty"`
+	CreatedAt time.Time  `firestore:"createdAt"          json:"createdAt"`
+}
+
+// UtilityCharge is a water or gas meter billed on the same bill as the
+// electricity. Usage is in m³ and Rate is the price per m³.
+type UtilityCharge struct {
+	MeterType       MeterType    `firestore:"meterType"          json:"meterType"`
+	MeterReading    float64      `firestore:"meterReading"       json:"meterReading"`
+	PreviousReading float64      `firestore:"previousReading"    json:"previousReading"`
+	Usage           float64      `firestore:"usage"              json:"usage"`
+	Rate            float64      `firestore:"rate"               json:"rate"`
+	Cost            money.Amount `firestore:"costMinor"          json:"cost"`
+	ImageURL        string       `firestore:"imageUrl,omitempty" json:"imageUrl,omitempty"`
+}
+
+// BillEmissions is the electricity usage of a bill converted to kgCO2e.
+// Factor is kgCO2e per kWh, taken for Region and FactorYear from emission
+// table TableVersion; storing all of it keeps the figure reproducible after
+// the table is revised.
+type BillEmissions struct {
+	KgCO2e       float64 `firestore:"kgCO2e"       json:"kgCO2e"`
+	Factor       float64 `firestore:"factor"       json:"factor"`
+	Region       string  `firestore:"region"       json:"region"`
+	FactorYear   int     `firestore:"factorYear"   json:"factorYear"`
+	TableVersion string  `firestore:"tableVersion" json:"tableVersion"`
 }
 
 // Bill is a single bill.
 // Path: /users/{uid}/bills/{billId}
+//
+// The billing window is [PeriodStart, PeriodEnd): PeriodEnd is the day the
+// closing reading belongs to, which is also the start of the next period.
+// Period stays as the "YYYY-MM" label of the month the window starts in.
+// Timezone is the user's zone when the bill was created; every timestamp in
+// an API response is rendered in it.
+//
+// A deleted bill moves to /users/{uid}/trash/{billId} with DeletedAt set and
+// is purged, photo included, after the retention window (see
+// BillService.Delete). PurgeAt is only filled in on trash listings.
+//
+// StartReadingID / EndReadingID point at the reading log entries the bill was
+// computed from, when it was; bills from a typed-in reading leave them empty.
+//
+// The top-level reading fields are the electricity meter. Water and gas, when
+// the tenant pays for them too, are Utilities; TotalAmount covers all of them
+// plus rent.
 type Bill struct {
-	ID               string    `firestore:"-"                 json:"id"`
-	Period           string    `firestore:"period"             json:"period"`
-	PeriodStart      time.Time `firestore:"periodStart"        json:"periodStart"`
-	MeterReading     float64   `firestore:"meterReading"       json:"meterReading"`
-	PreviousReading  float64   `firestore:"previousReading"    json:"previousReading"`
-	ElectricityUsage float64   `firestore:"electricityUsage"   json:"electricityUsage"`
-	ElectricityRate  float64   `firestore:"electricityRate"    json:"electricityRate"`
-	ElectricityCost  float64   `firestore:"electricityCost"    json:"electricityCost"`
-	Rent             float64   `firestore:"rent"               json:"rent"`
-	TotalAmount      float64   `firestore:"totalAmount"        json:"totalAmount"`
-	ImageURL         string    `firestore:"imageUrl"           json:"imageUrl,omitempty"`
+	ID               string     `firestore:"-"                 json:"id"`
+	Status           BillStatus `firestore:"status,omitempty"   json:"status,omitempty"`
+	Period           string     `firestore:"period"             json:"period"`
+	PeriodStart      time.Time  `firestore:"periodStart"        json:"periodStart"`
+	PeriodEnd        time.Time  `firestore:"periodEnd"          json:"periodEnd"`
+	Timezone         string     `firestore:"timezone,omitempty" json:"timezone,omitempty"`
+	MeterReading     float64    `firestore:"meterReading"       json:"meterReading"`
+	PreviousReading  float64    `firestore:"previousReading"    json:"previousReading"`
+	StartReadingID   string     `firestore:"startReadingId,omitempty" json:"startReadingId,omitempty"`
+	EndReadingID     string     `firestore:"endReadingId,omitempty"   json:"endReadingId,omitempty"`
+	ElectricityUsage float64    `firestore:"electricityUsage"   json:"electricityUsage"`
+	ElectricityRate  float64    `firestore:"electricityRate"    json:"electricityRate"`
+	// Currency is empty only on bills written before the money migration;
+	// those are TWD.
+	Currency        money.Currency `firestore:"currency,omitempty"   json:"currency,omitempty"`
+	ElectricityCost money.Amount   `firestore:"electricityCostMinor" json:"electricityCost"`
+	Rent            money.Amount   `firestore:"rentMinor"            json:"rent"`
+	TotalAmount     money.Amount   `firestore:"totalAmountMinor"     json:"totalAmount"`
+	// Legacy float64 copies of the amounts above (see package doc).
+	LegacyElectricityCost float64 `firestore:"electricityCost"    json:"-"`
+	LegacyRent            float64 `firestore:"rent"               json:"-"`
+	LegacyTotalAmount     float64 `firestore:"totalAmount"        json:"-"`
+	// Utilities are the water / gas meters billed together with electricity.
+	Utilities []UtilityCharge `firestore:"utilities,omitempty" json:"utilities,omitempty"`
+	// Emissions is the carbon estimate of the electricity usage, frozen at
+	// creation. Nil on bills created before the estimate existed.
+	Emissions *BillEmissions `firestore:"emissions,omitempty" json:"emissions,omitempty"`
+	ImageURL  string         `firestore:"imageUrl"            json:"imageUrl,omitempty"`
+	// Room is the ID of the Room a bill made from one meter of a bank photo
+	// is for, and RoomLabel its label then; empty for the user's own meter.
+	Room      string `firestore:"room,omitempty"      json:"room,omitempty"`
+	RoomLabel string `firestore:"roomLabel,omitempty" json:"roomLabel,omitempty"`
 	// ImageViewURL is populated by the handler on read (short-lived signed GET URL).
 	// It is never persisted to Firestore.
 	ImageViewURL string     `firestore:"-"                  json:"imageViewUrl,omitempty"`
@@ -97,6 +517,26 @@ type Bill struct {
 	OCR          *OCRResult `firestore:"ocr,omitempty"      json:"ocr,omitempty"`
 	CreatedAt    time.Time  `firestore:"createdAt"          json:"createdAt"`
 	UpdatedAt    time.Time  `firestore:"updatedAt"          json:"updatedAt"`
+	DeletedAt    *time.Time `firestore:"deletedAt,omitempty" json:"deletedAt,omitempty"`
+	PurgeAt      *time.Time `firestore:"-"                  json:"purgeAt,omitempty"`
+}
+
+// PhotoDeletion is a queued delete of a meter photo whose bill or logged
+// reading is gone.
+// Path: /users/{uid}/photoDeletions/{id}
+//
+// It is written in the same transaction that removes the bill or reading, so
+// a photo is never orphaned without a record; the record is removed once the
+// object is gone from Cloud Storage, and retried until then.
+type PhotoDeletion struct {
+	ID        string    `firestore:"-"                   json:"id"`
+	GCSPath   string    `firestore:"gcsPath"             json:"gcsPath"`
+	BillID    string    `firestore:"billId"              json:"billId"`
+	ReadingID string    `firestore:"readingId,omitempty" json:"readingId,omitempty"`
+	Attempts  int       `firestore:"attempts"            json:"attempts"`
+	LastError string    `firestore:"lastError,omitempty" json:"lastError,omitempty"`
+	CreatedAt time.Time `firestore:"createdAt"           json:"createdAt"`
+	UpdatedAt time.Time `firestore:"updatedAt"           json:"updatedAt"`
 }
 
 // ------------------ API DTOs ------------------
@@ -107,14 +547,72 @@ type Bill struct {
 //   - PreviousReading is the meter reading the previous period ended on. The
 //     frontend sends the value shown (and editable) on the capture screen. When
 //     omitted (nil), the backend falls back to settings.PreviousMeterReading.
-//   - period format: YYYY-MM
+//   - EndReadingID picks an electricity reading from the reading log as the
+//     end of the period; its value replaces MeterReading. StartReadingID does
+//     the same for PreviousReading.
+//   - period format: YYYY-MM. The start/end dates are derived from it using
+//     the user's billing cycle; PeriodStart/PeriodEnd (YYYY-MM-DD) override
+//     that when the actual meter-reading dates are known. Send both or neither.
 type CreateBillRequest struct {
-	MeterReading    float64  `json:"meterReading"     binding:"required,gte=0"`
-	PreviousReading *float64 `json:"previousReading"  binding:"omitempty,gte=0"`
-	ElectricityRate float64  `json:"electricityRate"  binding:"required,gt=0"`
-	Rent            float64  `json:"rent"             binding:"required,gte=0"`
-	Period          string   `json:"period"           binding:"required,len=7"` // YYYY-MM
-	ImageURL        string   `json:"imageUrl"`
+	MeterReading    float64      `json:"meterReading"     binding:"required_without=EndReadingID,gte=0"`
+	PreviousReading *float64     `json:"previousReading"  binding:"omitempty,gte=0"`
+	StartReadingID  string       `json:"startReadingId"`
+	EndReadingID    string       `json:"endReadingId"`
+	ElectricityRate float64      `json:"electricityRate"  binding:"required,gt=0"`
+	Rent            money.Amount `json:"rent"             binding:"required,gte=0"`
+	Period          string       `json:"period"           binding:"required,len=7"` // YYYY-MM
+	PeriodStart     string       `json:"periodStart"      binding:"omitempty,datetime=2006-01-02"`
+	PeriodEnd       string       `json:"periodEnd"        binding:"omitempty,datetime=2006-01-02"`
+	ImageURL        string       `json:"imageUrl"`
+	// OCRAttemptID is OCRResponse.AttemptID when the reading came from
+	// /ocr/process. The OCR result is embedded in the bill and the attempt
+	// records whether the user kept or corrected the value. An attempt with
+	// the wrong_meter warning needs ConfirmWrongMeter: the user has seen it.
+	OCRAttemptID      string `json:"ocrAttemptId"`
+	ConfirmWrongMeter bool   `json:"confirmWrongMeter,omitempty"`
+	// OCRMeterPosition picks the meter of a bank attempt (OCRBankMeter) the
+	// reading came from; required for those. A meter mapped to a room makes
+	// a bill for that room, which must still exist: it needs PreviousReading
+	// and leaves the user's own reading chain alone.
+	OCRMeterPosition int `json:"ocrMeterPosition,omitempty"`
+	// Utilities adds water / gas meters to the same bill, at most one of each.
+	Utilities []UtilityChargeRequest `json:"utilities" binding:"omitempty,max=2,dive"`
+}
+
+// UtilityChargeRequest is one water or gas meter on CreateBillRequest.
+// PreviousReading and Rate fall back to the settings for that meter type.
+type UtilityChargeRequest struct {
+	MeterType       MeterType `json:"meterType"        binding:"required,oneof=water gas"`
+	MeterReading    float64   `json:"meterReading"     binding:"gte=0"`
+	PreviousReading *float64  `json:"previousReading"  binding:"omitempty,gte=0"`
+	Rate            *float64  `json:"rate"             binding:"omitempty,gt=0"`
+	ImageURL        string    `json:"imageUrl"`
+}
+
+// CreateReadingRequest is the body for POST /api/v1/readings. TakenAt
+// defaults to now; OCR carries the /ocr/process result the value came from.
+type CreateReadingRequest struct {
+	MeterType MeterType  `json:"meterType" binding:"omitempty,oneof=electricity water gas"`
+	Value     float64    `json:"value"     binding:"gte=0"`
+	TakenAt   *time.Time `json:"takenAt"`
+	ImageURL  string     `json:"imageUrl"`
+	OCR       *OCRResult `json:"ocr"`
+	Note      string     `json:"note"      binding:"max=200"`
+}
+
+// CreateBillFromPhotoRequest is the body for POST /api/v1/bills/from-photo.
+// GCSPath is the path returned by /uploads/sign; the bill takes the billId
+// the upload was signed for. Period defaults to the period after the latest
+// bill.
+type CreateBillFromPhotoRequest struct {
+	GCSPath string `json:"gcsPath" binding:"required"`
+	Period  string `json:"period"  binding:"omitempty,len=7"` // YYYY-MM
+}
+
+// ConfirmBillRequest confirms a draft bill. MeterReading corrects the OCR
+// value; omit it to accept the value as read.
+type ConfirmBillRequest struct {
+	MeterReading *float64 `json:"meterReading" binding:"omitempty,gte=0"`
 }
 
 // UpdateBillPaymentRequest marks a bill as paid or unpaid.
@@ -125,31 +623,162 @@ type UpdateBillPaymentRequest struct {
 // UpdateSettingsRequest is the body for PATCH /api/v1/settings.
 // Every field is an optional pointer; nil means "do not change".
 type UpdateSettingsRequest struct {
-	DefaultElectricityRate *float64       `json:"defaultElectricityRate"`
-	DefaultRent            *float64       `json:"defaultRent"`
-	PreviousMeterReading   *float64       `json:"previousMeterReading"`
-	LandlordName           *string        `json:"landlordName"`
-	PaymentMethod          *PaymentMethod `json:"paymentMethod"`
-	MessageTemplate        *string        `json:"messageTemplate"`
-	SetupCompleted         *bool          `json:"setupCompleted"`
-	Language               *string        `json:"language"`
-	NotificationsEnabled   *bool          `json:"notificationsEnabled"`
-	AutoBackup             *bool          `json:"autoBackup"`
-}
-
-// OCRRequest is the OCR request body.
+	DefaultElectricityRate  *float64        `json:"defaultElectricityRate"`
+	DefaultRent             *money.Amount   `json:"defaultRent"`
+	Currency                *money.Currency `json:"currency"`
+	PreviousMeterReading    *float64        `json:"previousMeterReading"`
+	LandlordName            *string         `json:"landlordName"`
+	PaymentMethod           *PaymentMethod  `json:"paymentMethod"`
+	MessageTemplate         *string         `json:"messageTemplate"`
+	BillingCycle            *BillingCycle   `json:"billingCycle"`
+	BillingAnchorDay        *int            `json:"billingAnchorDay"`
+	Timezone                *string         `json:"timezone"`
+	Region                  *string         `json:"region"`
+	SetupCompleted          *bool           `json:"setupCompleted"`
+	Language                *string         `json:"language"`
+	NotificationsEnabled    *bool           `json:"notificationsEnabled"`
+	AutoBackup              *bool           `json:"autoBackup"`
+	OCRLearnFromCorrections *bool           `json:"ocrLearnFromCorrections"`
+	// PreviousReadings / DefaultUtilityRates update only the meter types present.
+	PreviousReadings    map[MeterType]float64 `json:"previousReadings"`
+	DefaultUtilityRates map[MeterType]float64 `json:"defaultUtilityRates"`
+}
+
+// SaveMeterProfileRequest is the body for PUT /api/v1/meters/{meterType}; it
+// replaces the whole profile.
+type SaveMeterProfileRequest struct {
+	DigitCount  int          `json:"digitCount"`
+	Display     MeterDisplay `json:"display"`
+	DecimalDrum bool         `json:"decimalDrum"`
+	Model       string       `json:"model"`
+	Serial      string       `json:"serial"`
+}
+
+// SaveRoomRequest is the body for POST /api/v1/rooms and PUT
+// /api/v1/rooms/{roomId}; it replaces the whole room.
+type SaveRoomRequest struct {
+	Label    string `json:"label"`
+	Serial   string `json:"serial"`
+	Position int    `json:"position"`
+}
+
+// OCRRequest is the OCR request body. MeterType picks the prompt; empty means
+// electricity. Consensus reads the photo several times and votes on each
+// digit: slower and costlier, but its confidence can be trusted.
 type OCRRequest struct {
-	ImageBase64     string  `json:"imageBase64"`
-	ImageURL        string  `json:"imageUrl"`
-	PreviousReading float64 `json:"previousReading"`
+	ImageBase64     string    `json:"imageBase64"`
+	ImageURL        string    `json:"imageUrl"`
+	PreviousReading float64   `json:"previousReading"`
+	MeterType       MeterType `json:"meterType"`
+	Consensus       bool      `json:"consensus,omitempty"`
+	// Bank reads every meter of a photo of a meter bank into
+	// OCRResponse.Meters, mapped to the user's rooms. PreviousReading,
+	// Consensus and the meter profile do not apply.
+	Bank bool `json:"bank,omitempty"`
+}
+
+// OCRBankMeter is one meter found on a photo of a meter bank. Position
+// numbers the meters in reading order, top row first, from 1; Room is the ID
+// of the Room it was mapped to, and RoomLabel that room's label.
+type OCRBankMeter struct {
+	Position   int     `firestore:"position"            json:"position"`
+	Serial     string  `firestore:"serial,omitempty"    json:"serial,omitempty"`
+	Reading    float64 `firestore:"reading"             json:"reading"`
+	Confidence float64 `firestore:"confidence"          json:"confidence"`
+	Notes      string  `firestore:"notes,omitempty"     json:"notes,omitempty"`
+	Box        *OCRBox `firestore:"box,omitempty"       json:"box,omitempty"`
+	Room       string  `firestore:"room,omitempty"      json:"room,omitempty"`
+	RoomLabel  string  `firestore:"roomLabel,omitempty" json:"roomLabel,omitempty"`
 }
 
-// OCRResponse is the OCR response.
+// OCRResponse is the OCR response. Unit is "kWh" or "m3". AttemptID is the
+// OCRAttempt this call was recorded as; send it back on CreateBillRequest.
+// With Consensus, Confidence is the weakest digit's agreement rather than the
+// model's own estimate.
 type OCRResponse struct {
-	Reading    float64 `json:"reading"`
-	Confidence float64 `json:"confidence"`
-	RawText    string  `json:"rawText,omitempty"`
-	Model      string  `json:"model"`
+	Reading       float64          `json:"reading"`
+	Confidence    float64          `json:"confidence"`
+	RawText       string           `json:"rawText,omitempty"`
+	Model         string           `json:"model"`
+	MeterType     MeterType        `json:"meterType"`
+	Unit          string           `json:"unit"`
+	PromptVersion string           `json:"promptVersion"`
+	AttemptID     string           `json:"attemptId,omitempty"`
+	Escalated     bool             `json:"escalated,omitempty"`
+	Consensus     *OCRConsensus    `json:"consensus,omitempty"`
+	Cached        bool             `json:"cached,omitempty"`
+	Quality       *OCRPhotoQuality `json:"quality,omitempty"`
+	// Digits are the register's digits left to right, each with its own
+	// confidence, so a client can point at the doubtful one. Absent when
+	// the model did not return digits that spell Reading.
+	Digits      []OCRDigit `json:"digits,omitempty"`
+	RegisterBox *OCRBox    `json:"registerBox,omitempty"`
+	// Serial is the serial number read off the meter, when the user's
+	// MeterProfile has one to check it against; a mismatch adds
+	// OCRWarningWrongMeter to Warnings.
+	Serial   string       `json:"serial,omitempty"`
+	Warnings []OCRWarning `json:"warnings,omitempty"`
+	// Meters are the meters of a bank photo (OCRRequest.Bank); Reading and
+	// Confidence are 0 then. Create one bill per meter with
+	// CreateBillRequest.OCRMeterPosition.
+	Meters []OCRBankMeter `json:"meters,omitempty"`
+}
+
+// ForecastBasis says what a Forecast was extrapolated from.
+type ForecastBasis string
+
+const (
+	// ForecastBasisReadings: at least one reading was logged this period.
+	ForecastBasisReadings ForecastBasis = "readings"
+	// ForecastBasisHistory: no reading yet, bill history only.
+	ForecastBasisHistory ForecastBasis = "history"
+)
+
+// Forecast is the response of GET /api/v1/forecast: the projected electricity
+// usage and cost of the period that is still open, i.e. the one the next bill
+// would be created for. Usage is counted from the latest bill's reading
+// (StartReading), so Days* and Usage* also cover any cycle skipped since.
+// Usage{Low,High} and Cost{Low,High} are an ~80% band.
+type Forecast struct {
+	PeriodStart     time.Time      `json:"periodStart"`
+	PeriodEnd       time.Time      `json:"periodEnd"`
+	AsOf            time.Time      `json:"asOf"`
+	Basis           ForecastBasis  `json:"basis"`
+	DaysElapsed     float64        `json:"daysElapsed"`
+	DaysTotal       float64        `json:"daysTotal"`
+	StartReading    float64        `json:"startReading"`
+	LatestReading   *float64       `json:"latestReading,omitempty"`
+	UsageSoFar      float64        `json:"usageSoFar"`
+	Usage           float64        `json:"usage"`
+	UsageLow        float64        `json:"usageLow"`
+	UsageHigh       float64        `json:"usageHigh"`
+	SeasonalFactor  float64        `json:"seasonalFactor"`
+	ElectricityRate float64        `json:"electricityRate"`
+	Currency        money.Currency `json:"currency"`
+	Cost            money.Amount   `json:"cost"`
+	CostLow         money.Amount   `json:"costLow"`
+	CostHigh        money.Amount   `json:"costHigh"`
+	Rent            money.Amount   `json:"rent"`
+	TotalAmount     money.Amount   `json:"totalAmount"`
+}
+
+// EmissionStats is the response of GET /api/v1/stats/emissions.
+type EmissionStats struct {
+	Region string            `json:"region"`
+	Years  []YearlyEmissions `json:"years"`
+}
+
+// YearlyEmissions totals the bills whose period starts in Year. Estimated
+// counts bills that predate stored emissions and were priced with the current
+// factor table. ChangePct compares KgCO2e with the previous year and is
+// omitted for the first year.
+type YearlyEmissions struct {
+	Year      int      `json:"year"`
+	Bills     int      `json:"bills"`
+	Estimated int      `json:"estimated"`
+	Usage     float64  `json:"usage"`
+	KgCO2e    float64  `json:"kgCO2e"`
+	ChangePct *float64 `json:"changePct,omitempty"`
 }
 
 // SignedUploadRequest requests a signed upload URL.
@@ -0,0 +1,176 @@
+// Package money does exact arithmetic on bill amounts.
+//
+// Costs used to be float64 end to end, so 123.7 kWh × 4.5 came out as
+// 556.6500000000001 and every client rounded differently. Amount fixes the
+// storage side (an integer count of hundredths) and Cost fixes the arithmetic
+// side (inputs are treated as the decimals the user typed, multiplied exactly,
+// then rounded once using the currency's rule).
+//
+// The JSON form is unchanged: an Amount marshals as a plain number in major
+// units (8000, 556.65), so existing clients keep working.
+package money
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"strconv"
+)
+
+// Amount is a money value in hundredths of the currency's major unit (cents
+// for USD, hundredths of a dollar for TWD). Two decimals cover every currency
+// we support; currencies without a minor unit in practice simply stay on
+// multiples of 100 after Round.
+type Amount int64
+
+// Currency is an ISO 4217 code.
+type Currency string
+
+const (
+	TWD Currency = "TWD"
+	USD Currency = "USD"
+	EUR Currency = "EUR"
+	JPY Currency = "JPY"
+	HKD Currency = "HKD"
+)
+
+// DefaultCurrency applies to users and bills that predate the currency field.
+const DefaultCurrency = TWD
+
+// roundingIncrements is the smallest amount each currency is billed in, in
+// hundredths. TWD has cents on paper but nobody hands over 0.50 NT$, and
+// Taipower itself rounds bills to whole dollars.
+var roundingIncrements = map[Currency]Amount{
+	TWD: 100,
+	JPY: 100,
+	USD: 1,
+	EUR: 1,
+	HKD: 1,
+}
+
+// Valid reports whether c is a supported currency.
+func (c Currency) Valid() bool {
+	_, ok := roundingIncrements[c]
+	return ok
+}
+
+// OrDefault returns c, or DefaultCurrency when c is empty.
+func (c Currency) OrDefault() Currency {
+	if c == "" {
+		return DefaultCurrency
+	}
+	return c
+}
+
+// FromMajor converts whole major units (e.g. 8000 NT$) to an Amount.
+func FromMajor(units int64) Amount {
+	return Amount(units * 100)
+}
+
+// FromFloat converts a float64 in major units to an Amount, reading it as the
+// shortest decimal that round-trips (so 0.1 is exactly one tenth) and rounding
+// half away from zero to hundredths. Used to migrate legacy float fields and
+// to accept JSON numbers.
+func FromFloat(f float64) Amount {
+	r := exact(f)
+	return Amount(roundRat(r.Mul(r, big.NewRat(100, 1)), 1))
+}
+
+// Float64 returns the amount in major units. Only for display and for the
+// legacy float fields; never do arithmetic on the result.
+func (a Amount) Float64() float64 {
+	return float64(a) / 100
+}
+
+// String formats the amount in major units without trailing zeros
+// ("8000", "556.65", "556.6").
+func (a Amount) String() string {
+	sign := ""
+	v := int64(a)
+	if v < 0 {
+		sign, v = "-", -v
+	}
+	whole, frac := v/100, v%100
+	switch {
+	case frac == 0:
+		return fmt.Sprintf("%s%d", sign, whole)
+	case frac%10 == 0:
+		return fmt.Sprintf("%s%d.%d", sign, whole, frac/10)
+	default:
+		return fmt.Sprintf("%s%d.%02d", sign, whole, frac)
+	}
+}
+
+// Round rounds a to the currency's billing increment, half away from zero.
+// Unknown currencies are left untouched.
+func (a Amount) Round(c Currency) Amount {
+	inc, ok := roundingIncrements[c.OrDefault()]
+	if !ok || inc <= 1 {
+		return a
+	}
+	return Amount(roundRat(big.NewRat(int64(a), 1), int64(inc)))
+}
+
+// Cost returns qty × unitPrice rounded to the currency's billing increment.
+// Both operands are read as the decimals they print as, so the product is
+// exact before the single rounding step.
+func Cost(qty, unitPrice float64, c Currency) Amount {
+	r := exact(qty)
+	r.Mul(r, exact(unitPrice))
+	r.Mul(r, big.NewRat(100, 1))
+	return Amount(roundRat(r, 1)).Round(c)
+}
+
+// Sub returns a - b for two decimal quantities (e.g. meter readings) without
+// float noise: 1250.3 - 1000.1 is 250.2, not 250.19999999999993.
+func Sub(a, b float64) float64 {
+	r := exact(a)
+	f, _ := r.Sub(r, exact(b)).Float64()
+	return f
+}
+
+// MarshalJSON writes the amount as a JSON number in major units.
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalJSON accepts a JSON number in major units. Values with more than
+// two decimals are rounded half away from zero.
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	r, ok := new(big.Rat).SetString(string(data))
+	if !ok {
+		return fmt.Errorf("money: invalid amount %s", data)
+	}
+	*a = Amount(roundRat(r.Mul(r, big.NewRat(100, 1)), 1))
+	return nil
+}
+
+// --------------- helpers ---------------
+
+// exact parses f's shortest round-trip representation as a rational. NaN and
+// ±Inf have no decimal form and become zero.
+func exact(f float64) *big.Rat {
+	r, ok := new(big.Rat).SetString(strconv.FormatFloat(f, 'f', -1, 64))
+	if !ok {
+		return new(big.Rat)
+	}
+	return r
+}
+
+// roundRat rounds r to the nearest multiple of step, half away from zero.
+func roundRat(r *big.Rat, step int64) int64 {
+	q := new(big.Rat).Quo(r, big.NewRat(step, 1))
+	num, den := q.Num(), q.Denom()
+	// Add or subtract half a unit, then truncate toward zero.
+	twice := new(big.Int).Mul(num, big.NewInt(2))
+	if num.Sign() >= 0 {
+		twice.Add(twice, den)
+	} else {
+		twice.Sub(twice, den)
+	}
+	n := new(big.Int).Quo(twice, new(big.Int).Mul(den, big.NewInt(2)))
+	return n.Int64() * step
+}
@@ -0,0 +1,138 @@
+package money
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestCost(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name           string
+		qty, unitPrice float64
+		currency       Currency
+		want           Amount
+	}{
+		{name: "float noise case rounds to whole TWD", qty: 123.7, unitPrice: 4.5, currency: TWD, want: FromMajor(557)},
+		{name: "same case keeps cents in USD", qty: 123.7, unitPrice: 4.5, currency: USD, want: 55665},
+		{name: "half rounds up in TWD", qty: 101, unitPrice: 4.5, currency: TWD, want: FromMajor(455)},
+		{name: "below half rounds down in TWD", qty: 100.1, unitPrice: 4.4, currency: TWD, want: FromMajor(440)},
+		{name: "empty currency uses default", qty: 250, unitPrice: 4.5, currency: "", want: FromMajor(1125)},
+		{name: "JPY has no minor unit", qty: 10.5, unitPrice: 31.3, currency: JPY, want: FromMajor(329)},
+		{name: "zero usage", qty: 0, unitPrice: 4.5, currency: TWD, want: 0},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			if got := Cost(tc.qty, tc.unitPrice, tc.currency); got != tc.want {
+				t.Errorf("Cost(%v, %v, %q) = %s, want %s", tc.qty, tc.unitPrice, tc.currency, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestAmountRound(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		in       Amount
+		currency Currency
+		want     Amount
+	}{
+		{in: 55665, currency: TWD, want: 55700},
+		{in: 55649, currency: TWD, want: 55600},
+		{in: 55650, currency: TWD, want: 55700},
+		{in: -55650, currency: TWD, want: -55700},
+		{in: 55665, currency: EUR, want: 55665},
+		{in: 55665, currency: "XXX", want: 55665},
+	}
+	for _, tc := range cases {
+		if got := tc.in.Round(tc.currency); got != tc.want {
+			t.Errorf("%d.Round(%q) = %d, want %d", tc.in, tc.currency, got, tc.want)
+		}
+	}
+}
+
+func TestFromFloat(t *testing.T) {
+	t.Parallel()
+
+	cases := map[float64]Amount{
+		8000:               800000,
+		556.65:             55665,
+		0.1 + 0.2:          30, // 0.30000000000000004
+		1125.0000000000002: 112500,
+		0.005:              1,
+		-0.005:             -1,
+		math.NaN():         0,
+	}
+	for in, want := range cases {
+		if got := FromFloat(in); got != want {
+			t.Errorf("FromFloat(%v) = %d, want %d", in, got, want)
+		}
+	}
+}
+
+func TestSub(t *testing.T) {
+	t.Parallel()
+
+	if got := Sub(1250.3, 1000.1); got != 250.2 {
+		t.Errorf("Sub(1250.3, 1000.1) = %v, want 250.2", got)
+	}
+	if got := Sub(500, 500); got != 0 {
+		t.Errorf("Sub(500, 500) = %v, want 0", got)
+	}
+}
+
+// TestAmountJSON pins the wire format: existing clients read amounts as plain
+// numbers in major units.
+func TestAmountJSON(t *testing.T) {
+	t.Parallel()
+
+	type bill struct {
+		Rent  Amount `json:"rent"`
+		Total Amount `json:"totalAmount"`
+	}
+
+	raw, err := json.Marshal(bill{Rent: FromMajor(8000), Total: 55665})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if string(raw) != `{"rent":8000,"totalAmount":556.65}` {
+		t.Errorf("marshal = %s", raw)
+	}
+
+	var back bill
+	if err := json.Unmarshal([]byte(`{"rent":8000.5,"totalAmount":556.655}`), &back); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if back.Rent != 800050 || back.Total != 55666 {
+		t.Errorf("unmarshal = %+v", back)
+	}
+
+	if err := json.Unmarshal([]byte(`{"rent":"8000"}`), &back); err == nil {
+		t.Error("expected error for string amount")
+	}
+}
+
+func TestAmountString(t *testing.T) {
+	t.Parallel()
+
+	cases := map[Amount]string{
+		0:       "0",
+		800000:  "8000",
+		55665:   "556.65",
+		55660:   "556.6",
+		5:       "0.05",
+		-55665:  "-556.65",
+		-800000: "-8000",
+	}
+	for in, want := range cases {
+		if got := in.String(); got != want {
+			t.Errorf("Amount(%d).String() = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -3,6 +3,8 @@ package services
 import (
 	"context"
 	"fmt"
+	"log/slog"
+	"slices"
 	"time"
 
 	"cloud.google.com/go/firestore"
@@ -12,22 +14,53 @@ import (
 
 	"wattrent/internal/middleware"
 	"wattrent/internal/models"
+	"wattrent/internal/money"
 )
 
-// BillService operates on /users/{uid}/bills/{billId}.
+// photoDeleter is the narrow slice of StorageService that BillService and
+// ReadingService need to remove the photos of purged bills and readings.
+type photoDeleter interface {
+	DeletePhoto(ctx context.Context, uid, gcsPath string) error
+}
+
+// meterReader is the slice of OCRService that CreateFromPhoto needs.
+type meterReader interface {
+	Process(ctx context.Context, uid string, req *models.OCRRequest) (*models.OCRResponse, error)
+}
+
+// BillService operates on /users/{uid}/bills/{billId} and on the trash,
+// /users/{uid}/trash/{billId}.
 type BillService struct {
 	fs       *firestore.Client
 	settings *SettingsService
+	storage  photoDeleter
+	ocr      meterReader
 }
 
-func NewBillService(fs *firestore.Client, settings *SettingsService) *BillService {
-	return &BillService{fs: fs, settings: settings}
+func NewBillService(fs *firestore.Client, settings *SettingsService, storage photoDeleter, ocr meterReader) *BillService {
+	return &BillService{fs: fs, settings: settings, storage: storage, ocr: ocr}
 }
 
 func (s *BillService) billsCol(uid string) *firestore.CollectionRef {
 	return s.fs.Collection("users").Doc(uid).Collection("bills")
 }
 
+func (s *BillService) trashCol(uid string) *firestore.CollectionRef {
+	return trashCol(s.fs, uid)
+}
+
+func trashCol(fs *firestore.Client, uid string) *firestore.CollectionRef {
+	return fs.Collection("users").Doc(uid).Collection("trash")
+}
+
+func (s *BillService) photoDeletionsCol(uid string) *firestore.CollectionRef {
+	return photoDeletionsCol(s.fs, uid)
+}
+
+func photoDeletionsCol(fs *firestore.Client, uid string) *firestore.CollectionRef {
+	return fs.Collection("users").Doc(uid).Collection("photoDeletions")
+}
+
 // Create creates a new bill.
 //
 // Inside one transaction:
@@ -35,85 +68,256 @@ func (s *BillService) billsCol(uid string) *firestore.CollectionRef {
 //  2. Update settings.previousMeterReading to this reading.
 //
 // Note: previousReading is taken from the current settings; if this is the
-// first bill, previousReading=0.
+// first bill, previousReading=0. The period window follows the billing cycle
+// stored in the same settings document (see resolvePeriod).
+//
+// When req.EndReadingID / StartReadingID name entries from the reading log,
+// their values are used as the current / previous reading.
 func (s *BillService) Create(ctx context.Context, uid string, req *models.CreateBillRequest) (*models.Bill, error) {
-	periodStart, err := parsePeriod(req.Period)
-	if err != nil {
-		return nil, &middleware.AppError{
-			HTTPStatus: 400,
-			Key:        "errors.bill.invalid_period",
-			Cause:      err,
-		}
-	}
+	return s.create(ctx, uid, s.billsCol(uid).NewDoc(), req, nil)
+}
+
+// photoDraft is what CreateFromPhoto adds to a create: the OCR result to
+// embed, whether the model itself was unsure and whether the photo showed
+// another meter (OCRWarningWrongMeter).
+type photoDraft struct {
+	ocr           *models.OCRResult
+	lowConfidence bool
+	wrongMeter    bool
+}
 
+// create is Create with a caller-chosen document. With a photoDraft, an
+// existing bill at billRef is returned as is (the request is a retry), and a
+// reading that is low-confidence, from another meter or below the previous
+// one makes the bill a draft: it is saved for the user to confirm but does
+// not advance the reading chain. Without one, an OCR attempt from another
+// meter needs req.ConfirmWrongMeter.
+func (s *BillService) create(ctx context.Context, uid string, billRef *firestore.DocumentRef, req *models.CreateBillRequest, draft *photoDraft) (*models.Bill, error) {
+	if err := validateBillPhotos(uid, req); err != nil {
+		return nil, err
+	}
 	settingsRef := s.fs.Collection("users").Doc(uid).Collection("settings").Doc(settingsDocID)
-	billRef := s.billsCol(uid).NewDoc()
 
 	var created models.Bill
+	var corrected models.MeterType
 
-	err = s.fs.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
-		// 1. Determine the previous meter reading. Prefer the value the client
-		//    sent (the user can view/edit it on the capture screen); fall back to
-		//    settings.previousMeterReading (default 0 if missing).
-		var prevReading float64
-		if snap, err := tx.Get(settingsRef); err == nil {
-			if v, err := snap.DataAt("previousMeterReading"); err == nil {
-				if f, ok := v.(float64); ok {
-					prevReading = f
+	err := s.fs.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		corrected = ""
+		if draft != nil {
+			if snap, err := tx.Get(billRef); err == nil {
+				existing, err := docToBill(snap)
+				if err != nil {
+					return err
 				}
+				created = *existing
+				return nil
+			} else if status.Code(err) != codes.NotFound {
+				return err
+			}
+			// The photo's bill was deleted: a new one under its ID would be
+			// overwritten by a restore.
+			if _, err := tx.Get(s.trashCol(uid).Doc(billRef.ID)); err == nil {
+				return &middleware.AppError{HTTPStatus: 409, Key: "errors.bill.in_trash"}
+			} else if status.Code(err) != codes.NotFound {
+				return err
 			}
+		}
+
+		// 1. Load settings for the previous meter reading and billing cycle. A
+		//    missing document means defaults: previous reading 0, monthly cycle
+		//    anchored on the 1st.
+		var settings models.UserSettings
+		if snap, err := tx.Get(settingsRef); err == nil {
+			if err := snap.DataTo(&settings); err != nil {
+				return err
+			}
+			migrateLegacySettings(&settings)
 		} else if status.Code(err) != codes.NotFound {
 			return err
 		}
+
+		loc, err := loadTimezone(settings.Timezone)
+		if err != nil {
+			return err
+		}
+		periodStart, periodEnd, err := resolvePeriod(req, &settings, loc)
+		if err != nil {
+			return &middleware.AppError{
+				HTTPStatus: 400,
+				Key:        "errors.bill.invalid_period",
+				Cause:      err,
+			}
+		}
+
+		// 2. Determine the previous meter reading. Prefer the value the client
+		//    sent (the user can view/edit it on the capture screen); fall back to
+		//    settings.previousMeterReading.
+		prevReading := settings.PreviousMeterReading
 		if req.PreviousReading != nil {
 			prevReading = *req.PreviousReading
 		}
 
-		if req.MeterReading < prevReading {
-			return &middleware.AppError{
-				HTTPStatus: 400,
-				Key:        "errors.bill.reading_decreased",
+		// Logged readings picked as the period's boundaries override the
+		// typed-in values; the usage is then exactly what the log shows.
+		meterReading := req.MeterReading
+		var startReading, endReading *models.Reading
+		if req.EndReadingID != "" {
+			if endReading, err = txGetBillReading(tx, readingsCol(s.fs, uid).Doc(req.EndReadingID)); err != nil {
+				return err
+			}
+			meterReading = endReading.Value
+		}
+		if req.StartReadingID != "" {
+			if startReading, err = txGetBillReading(tx, readingsCol(s.fs, uid).Doc(req.StartReadingID)); err != nil {
+				return err
+			}
+			if endReading != nil && !startReading.TakenAt.Before(endReading.TakenAt) {
+				return &middleware.AppError{HTTPStatus: 400, Key: "errors.bill.reading_order"}
+			}
+			prevReading = startReading.Value
+		}
+		var attemptRef *firestore.DocumentRef
+		var attempt *models.OCRAttempt
+		var bankMeter *models.OCRBankMeter
+		if req.OCRAttemptID != "" {
+			attemptRef = ocrAttemptsCol(s.fs, uid).Doc(req.OCRAttemptID)
+			if attempt, err = txGetOCRAttempt(tx, attemptRef); err != nil {
+				return err
+			}
+			if draft == nil {
+				if err := checkAttemptWarnings(attempt, req.ConfirmWrongMeter); err != nil {
+					return err
+				}
+			}
+			if bankMeter, err = attemptBankMeter(attempt, req.OCRMeterPosition); err != nil {
+				return err
+			}
+		}
+		// A room's meter is not the one settings.previousMeterReading follows,
+		// so its bill needs the previous reading from the client.
+		var room *models.Room
+		if bankMeter != nil && bankMeter.Room != "" {
+			if room, err = txGetRoom(tx, roomsCol(s.fs, uid).Doc(bankMeter.Room)); err != nil {
+				return err
+			}
+			if req.PreviousReading == nil {
+				return &middleware.AppError{HTTPStatus: 400, Key: "errors.bill.previous_reading_required"}
 			}
 		}
 
-		usage := req.MeterReading - prevReading
-		electricityCost := usage * req.ElectricityRate
-		now := time.Now().UTC()
+		isDraft := draft != nil && (draft.lowConfidence || draft.wrongMeter || meterReading < prevReading)
+		// A draft keeps the reading as read so the user sees what to correct;
+		// its amounts assume no usage until then.
+		billedReading := meterReading
+		if meterReading < prevReading {
+			if !isDraft {
+				return &middleware.AppError{
+					HTTPStatus: 400,
+					Key:        "errors.bill.reading_decreased",
+				}
+			}
+			billedReading = prevReading
+		}
+
+		currency := settings.Currency.OrDefault()
+		usage, electricityCost, rent, total := billTotals(prevReading, billedReading, req.ElectricityRate, req.Rent, currency)
+		utilities, utilitiesCost, err := utilityCharges(req.Utilities, &settings, currency)
+		if err != nil {
+			return err
+		}
+		total += utilitiesCost
+		now := time.Now().In(loc)
 
 		bill := models.Bill{
 			Period:           req.Period,
 			PeriodStart:      periodStart,
-			MeterReading:     req.MeterReading,
+			PeriodEnd:        periodEnd,
+			Timezone:         loc.String(),
+			MeterReading:     meterReading,
 			PreviousReading:  prevReading,
+			StartReadingID:   req.StartReadingID,
+			EndReadingID:     req.EndReadingID,
 			ElectricityUsage: usage,
 			ElectricityRate:  req.ElectricityRate,
+			Currency:         currency,
 			ElectricityCost:  electricityCost,
-			Rent:             req.Rent,
-			TotalAmount:      electricityCost + req.Rent,
+			Rent:             rent,
+			TotalAmount:      total,
+			Utilities:        utilities,
+			Emissions:        billEmissions(settings.Region, periodStart.Year(), usage),
 			ImageURL:         req.ImageURL,
 			CreatedAt:        now,
 			UpdatedAt:        now,
 		}
+		setLegacyBillAmounts(&bill)
+		if endReading != nil {
+			if bill.ImageURL == "" {
+				bill.ImageURL = endReading.ImageURL
+			}
+			bill.OCR = endReading.OCR
+		}
+		if attempt != nil {
+			bill.OCR = attemptResult(attemptRef.ID, attempt)
+			if bankMeter != nil {
+				bill.OCR.Confidence = bankMeter.Confidence
+				bill.OCR.MeterPosition = bankMeter.Position
+			}
+		} else if draft != nil {
+			bill.OCR = draft.ocr
+		}
+		if isDraft {
+			bill.Status = models.BillStatusDraft
+		}
+		if room != nil {
+			bill.Room, bill.RoomLabel = room.ID, room.Label
+		}
 
 		if err := tx.Set(billRef, bill); err != nil {
 			return err
 		}
-
-		// 2. Update settings.previousMeterReading
-		if err := tx.Set(settingsRef, map[string]interface{}{
-			"previousMeterReading": req.MeterReading,
-			"updatedAt":            firestore.ServerTimestamp,
-		}, firestore.MergeAll); err != nil {
-			return err
+		if attempt != nil && bankMeter == nil {
+			// A draft's reading is not settled yet; Confirm resolves it. A bank
+			// attempt is not linked: one photo makes a bill per meter.
+			if err := tx.Update(attemptRef, attemptLinkUpdates(billRef.ID, attempt, meterReading, !isDraft)); err != nil {
+				return err
+			}
+			if !isDraft {
+				if ok, err := txRecordCorrection(tx, s.fs, uid, attemptRef.ID, attempt, meterReading, &settings); err != nil {
+					return err
+				} else if ok {
+					corrected = attempt.MeterType
+				}
+			}
 		}
-
 		bill.ID = billRef.ID
 		created = bill
-		return nil
+		if isDraft || room != nil {
+			// Confirm advances the chain once the reading is settled; a room's
+			// bill has no chain in settings.
+			return nil
+		}
+
+		// 3. Advance each meter's reading chain: previousMeterReading for
+		//    electricity, previousReadings.{type} for water / gas.
+		chain := map[string]interface{}{
+			"previousMeterReading": meterReading,
+			"updatedAt":            firestore.ServerTimestamp,
+		}
+		if len(utilities) > 0 {
+			prev := make(map[string]interface{}, len(utilities))
+			for _, u := range utilities {
+				prev[string(u.MeterType)] = u.MeterReading
+			}
+			chain["previousReadings"] = prev
+		}
+		return tx.Set(settingsRef, chain, firestore.MergeAll)
 	})
 	if err != nil {
 		return nil, err
 	}
+	if corrected != "" {
+		trimOCRCorrectionsQuietly(ctx, s.fs, uid, corrected)
+	}
 
 	return &created, nil
 }
@@ -133,13 +337,17 @@ func (s *BillService) Get(ctx context.Context, uid, billID string) (*models.Bill
 	return docToBill(snap)
 }
 
-// List lists every bill for a user (newest first).
+// List lists every bill for a user, newest billing period first. Bills for the
+// same period (e.g. a re-read) fall back to creation order.
 func (s *BillService) List(ctx context.Context, uid string, limit int) ([]*models.Bill, error) {
 	if limit <= 0 || limit > 100 {
 		limit = 50
 	}
 
-	q := s.billsCol(uid).OrderBy("createdAt", firestore.Desc).Limit(limit)
+	q := s.billsCol(uid).
+		OrderBy("periodStart", firestore.Desc).
+		OrderBy("createdAt", firestore.Desc).
+		Limit(limit)
 	iter := q.Documents(ctx)
 	defer iter.Stop()
 
@@ -161,9 +369,10 @@ func (s *BillService) List(ctx context.Context, uid string, limit int) ([]*model
 	return bills, nil
 }
 
-// Latest returns the most recent bill.
+// Latest returns the user's own settled bill for the most recent billing
+// period (see History).
 func (s *BillService) Latest(ctx context.Context, uid string) (*models.Bill, error) {
-	bills, err := s.List(ctx, uid, 1)
+	bills, err := s.History(ctx, uid, 1)
 	if err != nil {
 		return nil, err
 	}
@@ -173,8 +382,107 @@ func (s *BillService) Latest(ctx context.Context, uid string) (*models.Bill, err
 	return bills[0], nil
 }
 
+// maxHistoryScan bounds how many bills History reads past room bills and
+// drafts to find the user's own.
+const maxHistoryScan = 500
+
+// History lists up to limit of the bills in the user's own reading history
+// (see inHistory), newest billing period first. Room bills share the
+// collection, and bills from before rooms existed have no room field for a
+// query to match, so the others are skipped as they are read.
+func (s *BillService) History(ctx context.Context, uid string, limit int) ([]*models.Bill, error) {
+	iter := s.billsCol(uid).
+		OrderBy("periodStart", firestore.Desc).
+		OrderBy("createdAt", firestore.Desc).
+		Limit(maxHistoryScan).
+		Documents(ctx)
+	defer iter.Stop()
+
+	bills := make([]*models.Bill, 0, limit)
+	for len(bills) < limit {
+		snap, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		b, err := docToBill(snap)
+		if err != nil {
+			return nil, err
+		}
+		if inHistory(b) {
+			bills = append(bills, b)
+		}
+	}
+	return bills, nil
+}
+
+// inHistory reports whether b belongs to the user's own reading history,
+// the one settings.previousMeterReading follows: not a draft, whose reading
+// is unconfirmed, nor a room's bill, which is another meter's.
+func inHistory(b *models.Bill) bool {
+	return b.Status != models.BillStatusDraft && b.Room == ""
+}
+
+// maxEmissionStatsYears caps how far back EmissionStats reads.
+const maxEmissionStatsYears = 10
+
+// EmissionStats totals the carbon estimate of the user's bills per calendar
+// year for the last `years` years, including the current one.
+func (s *BillService) EmissionStats(ctx context.Context, uid string, years int) (*models.EmissionStats, error) {
+	if years <= 0 || years > maxEmissionStatsYears {
+		years = 5
+	}
+	settings, err := s.settings.Get(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := loadTimezone(settings.Timezone)
+	if err != nil {
+		return nil, err
+	}
+	region := settings.Region
+	if region == "" {
+		region = defaultRegion
+	}
+	from := time.Date(time.Now().In(loc).Year()-years+1, time.January, 1, 0, 0, 0, 0, loc)
+
+	iter := s.billsCol(uid).
+		Where("periodStart", ">=", from).
+		OrderBy("periodStart", firestore.Desc).
+		Documents(ctx)
+	defer iter.Stop()
+
+	var bills []*models.Bill
+	for {
+		snap, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		b, err := docToBill(snap)
+		if err != nil {
+			return nil, err
+		}
+		bills = append(bills, b)
+	}
+	return &models.EmissionStats{Region: region, Years: yearlyEmissions(bills, region)}, nil
+}
+
 // SetPaid toggles the payment status. paid=true -> paidAt=now; paid=false -> paidAt=nil.
+// A draft has to be confirmed before it can be paid.
 func (s *BillService) SetPaid(ctx context.Context, uid, billID string, paid bool) (*models.Bill, error) {
+	bill, err := s.Get(ctx, uid, billID)
+	if err != nil {
+		return nil, err
+	}
+	if bill.Status == models.BillStatusDraft {
+		return nil, &middleware.AppError{HTTPStatus: 409, Key: "errors.bill.draft_unconfirmed"}
+	}
+
 	updates := []firestore.Update{
 		{Path: "updatedAt", Value: firestore.ServerTimestamp},
 	}
@@ -193,27 +501,556 @@ func (s *BillService) SetPaid(ctx context.Context, uid, billID string, paid bool
 	return s.Get(ctx, uid, billID)
 }
 
-// Delete deletes a bill; paid bills cannot be deleted.
+// trashRetention is how long a deleted bill stays restorable.
+const trashRetention = 30 * 24 * time.Hour
+
+// Delete moves a bill to the trash; paid bills cannot be deleted.
+//
+// The document is copied as is (plus deletedAt) so Restore puts back exactly
+// what was there, legacy fields included. The settings reading chain is not
+// touched, same as before soft delete. Trash past the retention window is
+// purged on the way out.
 func (s *BillService) Delete(ctx context.Context, uid, billID string) error {
-	bill, err := s.Get(ctx, uid, billID)
+	billRef := s.billsCol(uid).Doc(billID)
+	trashRef := s.trashCol(uid).Doc(billID)
+
+	err := s.fs.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		snap, err := tx.Get(billRef)
+		if err != nil {
+			if status.Code(err) == codes.NotFound {
+				return &middleware.AppError{HTTPStatus: 404, Key: "errors.bill.not_found"}
+			}
+			return err
+		}
+		data := snap.Data()
+		if paidAt, ok := data["paidAt"]; ok && paidAt != nil {
+			return &middleware.AppError{
+				HTTPStatus: 409,
+				Key:        "errors.bill.cannot_delete_paid",
+			}
+		}
+		data["deletedAt"] = time.Now().UTC()
+		if err := tx.Set(trashRef, data); err != nil {
+			return err
+		}
+		return tx.Delete(billRef)
+	})
 	if err != nil {
 		return err
 	}
-	if bill.PaidAt != nil {
-		return &middleware.AppError{
-			HTTPStatus: 409,
-			Key:        "errors.bill.cannot_delete_paid",
+
+	s.purgeTrashQuietly(ctx, uid)
+	return nil
+}
+
+// ListTrash lists the bills in the trash, most recently deleted first, each
+// with the time it will be purged.
+func (s *BillService) ListTrash(ctx context.Context, uid string) ([]*models.Bill, error) {
+	s.purgeTrashQuietly(ctx, uid)
+
+	iter := s.trashCol(uid).OrderBy("deletedAt", firestore.Desc).Limit(100).Documents(ctx)
+	defer iter.Stop()
+
+	bills := make([]*models.Bill, 0)
+	for {
+		snap, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		b, err := docToBill(snap)
+		if err != nil {
+			return nil, err
+		}
+		if b.DeletedAt != nil {
+			purgeAt := b.DeletedAt.Add(trashRetention)
+			b.PurgeAt = &purgeAt
+		}
+		bills = append(bills, b)
+	}
+	return bills, nil
+}
+
+// Restore moves a bill back out of the trash.
+func (s *BillService) Restore(ctx context.Context, uid, billID string) (*models.Bill, error) {
+	billRef := s.billsCol(uid).Doc(billID)
+	trashRef := s.trashCol(uid).Doc(billID)
+
+	err := s.fs.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		snap, err := tx.Get(trashRef)
+		if err != nil {
+			if status.Code(err) == codes.NotFound {
+				return &middleware.AppError{HTTPStatus: 404, Key: "errors.bill.not_in_trash"}
+			}
+			return err
+		}
+		if _, err := tx.Get(billRef); err == nil {
+			return &middleware.AppError{HTTPStatus: 409, Key: "errors.bill.restore_conflict"}
+		} else if status.Code(err) != codes.NotFound {
+			return err
+		}
+		data := snap.Data()
+		delete(data, "deletedAt")
+		data["updatedAt"] = time.Now().UTC()
+		if err := tx.Set(billRef, data); err != nil {
+			return err
+		}
+		return tx.Delete(trashRef)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, uid, billID)
+}
+
+// DeleteFromTrash permanently deletes one bill from the trash, photo
+// included, without waiting for the retention window.
+func (s *BillService) DeleteFromTrash(ctx context.Context, uid, billID string) error {
+	if err := s.purgeBill(ctx, uid, billID); err != nil {
+		return err
+	}
+	s.retryPhotoDeletionsQuietly(ctx, uid)
+	return nil
+}
+
+// PurgeTrash permanently removes bills deleted more than trashRetention ago,
+// then retries photo deletions that failed earlier. Returns the first error.
+func (s *BillService) PurgeTrash(ctx context.Context, uid string) error {
+	cutoff := time.Now().UTC().Add(-trashRetention)
+	snaps, err := s.trashCol(uid).Where("deletedAt", "<", cutoff).Documents(ctx).GetAll()
+	if err != nil {
+		return err
+	}
+
+	var firstErr error
+	for _, snap := range snaps {
+		if err := s.purgeBill(ctx, uid, snap.Ref.ID); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("purge bill %s: %w", snap.Ref.ID, err)
+		}
+	}
+	if err := s.retryPhotoDeletions(ctx, uid); err != nil && firstErr == nil {
+		firstErr = err
+	}
+	return firstErr
+}
+
+// purgeTrashQuietly runs PurgeTrash for requests that are not about the
+// purge; a failure there must not fail them.
+func (s *BillService) purgeTrashQuietly(ctx context.Context, uid string) {
+	if err := s.PurgeTrash(ctx, uid); err != nil {
+		slog.Warn("trash purge failed", "uid", uid, "err", err)
+	}
+}
+
+// purgeBill deletes a trashed bill for good.
+//
+// Firestore and Cloud Storage cannot be updated atomically, so the bill's
+// photos are first queued as PhotoDeletion records in the same transaction
+// that deletes the bill, then deleted from storage. A storage failure leaves
+// the record behind for retryPhotoDeletions; the bill is gone either way.
+func (s *BillService) purgeBill(ctx context.Context, uid, billID string) error {
+	trashRef := s.trashCol(uid).Doc(billID)
+
+	var queued []*firestore.DocumentRef
+	var paths []string
+	err := s.fs.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		queued, paths = nil, nil
+
+		snap, err := tx.Get(trashRef)
+		if err != nil {
+			if status.Code(err) == codes.NotFound {
+				return &middleware.AppError{HTTPStatus: 404, Key: "errors.bill.not_in_trash"}
+			}
+			return err
+		}
+		bill, err := docToBill(snap)
+		if err != nil {
+			return err
+		}
+		keep, err := s.txReadingPhotos(tx, uid, bill)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now().UTC()
+		for _, path := range billPhotoPaths(bill, keep) {
+			if _, err := userObject(uid, path); err != nil {
+				// Never the user's to delete, whatever the bill says.
+				slog.Warn("purge: photo outside the user's folder left alone", "uid", uid, "bill", billID, "path", path)
+				continue
+			}
+			ref := s.photoDeletionsCol(uid).NewDoc()
+			if err := tx.Create(ref, models.PhotoDeletion{
+				GCSPath:   path,
+				BillID:    billID,
+				CreatedAt: now,
+				UpdatedAt: now,
+			}); err != nil {
+				return err
+			}
+			queued = append(queued, ref)
+			paths = append(paths, path)
+		}
+		if bill.OCR != nil && bill.OCR.AttemptID != "" {
+			// Its photo is about to go; so does the example made of it.
+			if err := tx.Delete(ocrCorrectionsCol(s.fs, uid).Doc(bill.OCR.AttemptID)); err != nil {
+				return err
+			}
+		}
+		return tx.Delete(trashRef)
+	})
+	if err != nil {
+		return err
+	}
+
+	for i, ref := range queued {
+		if err := deleteQueuedPhoto(ctx, s.storage, uid, ref, paths[i]); err != nil {
+			// Queued; retryPhotoDeletions picks it up.
+			slog.Warn("photo delete failed, queued for retry", "uid", uid, "bill", billID, "path", paths[i], "err", err)
+		}
+	}
+	return nil
+}
+
+// maxPhotoDeletionRetries bounds the work one request does on the queue.
+const maxPhotoDeletionRetries = 20
+
+// retryPhotoDeletions works through the user's queued photo deletions, oldest
+// first. It runs whenever the trash is touched: Cloud Run only has CPU while
+// serving a request, so there is no background loop to hand this to. A user
+// who never comes back keeps their queue, which DeleteAccount / ClearData
+// clear along with every photo.
+func (s *BillService) retryPhotoDeletions(ctx context.Context, uid string) error {
+	snaps, err := s.photoDeletionsCol(uid).OrderBy("createdAt", firestore.Asc).Limit(maxPhotoDeletionRetries).Documents(ctx).GetAll()
+	if err != nil {
+		return err
+	}
+	var firstErr error
+	for _, snap := range snaps {
+		var d models.PhotoDeletion
+		if err := snap.DataTo(&d); err != nil {
+			return err
+		}
+		if _, err := userObject(uid, d.GCSPath); err != nil {
+			// Queued before purgeBill checked the path; drop the record only.
+			slog.Warn("photo delete: path outside the user's folder dropped", "uid", uid, "path", d.GCSPath)
+			if _, err := snap.Ref.Delete(ctx); err != nil && firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if err := deleteQueuedPhoto(ctx, s.storage, uid, snap.Ref, d.GCSPath); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (s *BillService) retryPhotoDeletionsQuietly(ctx context.Context, uid string) {
+	if err := s.retryPhotoDeletions(ctx, uid); err != nil {
+		slog.Warn("photo delete retry failed", "uid", uid, "err", err)
+	}
+}
+
+// deleteQueuedPhoto deletes one queued photo and, on success, its queue
+// record. On failure the record keeps the attempt count and error for the
+// next retry.
+func deleteQueuedPhoto(ctx context.Context, storage photoDeleter, uid string, ref *firestore.DocumentRef, gcsPath string) error {
+	if storage == nil {
+		return nil
+	}
+	if err := storage.DeletePhoto(ctx, uid, gcsPath); err != nil {
+		if _, uerr := ref.Update(ctx, []firestore.Update{
+			{Path: "attempts", Value: firestore.Increment(1)},
+			{Path: "lastError", Value: err.Error()},
+			{Path: "updatedAt", Value: firestore.ServerTimestamp},
+		}); uerr != nil {
+			slog.Error("photo delete: record attempt failed", "path", gcsPath, "err", uerr)
 		}
+		return err
 	}
-	_, err = s.billsCol(uid).Doc(billID).Delete(ctx)
+	_, err := ref.Delete(ctx)
 	return err
 }
 
+// txReadingPhotos returns the photos of the reading-log entries a bill was
+// computed from. A bill created from a logged reading borrows that reading's
+// photo, which must outlive the bill.
+func (s *BillService) txReadingPhotos(tx *firestore.Transaction, uid string, bill *models.Bill) (map[string]bool, error) {
+	keep := make(map[string]bool)
+	for _, id := range []string{bill.StartReadingID, bill.EndReadingID} {
+		if id == "" {
+			continue
+		}
+		snap, err := tx.Get(readingsCol(s.fs, uid).Doc(id))
+		if err != nil {
+			if status.Code(err) == codes.NotFound {
+				continue
+			}
+			return nil, err
+		}
+		r, err := docToReading(snap)
+		if err != nil {
+			return nil, err
+		}
+		keep[r.ImageURL] = true
+	}
+	return keep, nil
+}
+
 // ----------------------- helpers -----------------------
 
-// parsePeriod converts "YYYY-MM" to time.Time (first day of the month at 00:00 Asia/Taipei).
-func parsePeriod(period string) (time.Time, error) {
-	loc, _ := time.LoadLocation("Asia/Taipei")
+// billTotals is the bill formula. usage is exact in decimal terms, the
+// electricity cost is rounded once to the currency's billing increment, and
+// rent is rounded the same way so the total is too.
+func billTotals(prev, current, rate float64, rent money.Amount, currency money.Currency) (usage float64, cost, roundedRent, total money.Amount) {
+	usage = money.Sub(current, prev)
+	cost = money.Cost(usage, rate, currency)
+	roundedRent = rent.Round(currency)
+	return usage, cost, roundedRent, cost + roundedRent
+}
+
+// validateBillPhotos checks that the photos a new bill names are the user's
+// own uploads (see userObject): a bill's photos are deleted with it.
+func validateBillPhotos(uid string, req *models.CreateBillRequest) error {
+	paths := []string{req.ImageURL}
+	for _, u := range req.Utilities {
+		paths = append(paths, u.ImageURL)
+	}
+	for _, p := range paths {
+		if p == "" {
+			continue
+		}
+		if _, err := userObject(uid, p); err != nil {
+			return &middleware.AppError{HTTPStatus: 400, Key: "errors.bill.invalid_image_url", Cause: err}
+		}
+	}
+	return nil
+}
+
+// billPhotoPaths lists the distinct gs:// photos of a bill (electricity and
+// utility meters) that are not in keep.
+func billPhotoPaths(bill *models.Bill, keep map[string]bool) []string {
+	var paths []string
+	seen := make(map[string]bool)
+	add := func(p string) {
+		if p == "" || seen[p] || keep[p] {
+			return
+		}
+		seen[p] = true
+		paths = append(paths, p)
+	}
+	add(bill.ImageURL)
+	for _, u := range bill.Utilities {
+		add(u.ImageURL)
+	}
+	return paths
+}
+
+// txGetOCRAttempt loads the OCR attempt a new bill's reading came from.
+func txGetOCRAttempt(tx *firestore.Transaction, ref *firestore.DocumentRef) (*models.OCRAttempt, error) {
+	snap, err := tx.Get(ref)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, &middleware.AppError{HTTPStatus: 404, Key: "errors.ocr.attempt_not_found"}
+		}
+		return nil, err
+	}
+	var a models.OCRAttempt
+	if err := snap.DataTo(&a); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// attemptResult is the OCRResult embedded in a bill made from attempt id.
+func attemptResult(id string, a *models.OCRAttempt) *models.OCRResult {
+	return &models.OCRResult{
+		Confidence:    a.Confidence,
+		Model:         a.Model,
+		RawText:       a.RawText,
+		ProcessedAt:   a.CreatedAt,
+		PromptVersion: a.PromptVersion,
+		AttemptID:     id,
+		Warnings:      a.Warnings,
+	}
+}
+
+// checkAttemptWarnings keeps a bill from being made silently out of an OCR
+// attempt that read another meter: the client has to say the user saw the
+// warning.
+func checkAttemptWarnings(a *models.OCRAttempt, confirmedWrongMeter bool) error {
+	if slices.Contains(a.Warnings, models.OCRWarningWrongMeter) && !confirmedWrongMeter {
+		return &middleware.AppError{HTTPStatus: 409, Key: "errors.bill.wrong_meter"}
+	}
+	return nil
+}
+
+// attemptBankMeter returns the meter at position of a bank attempt, or nil
+// for a single-meter attempt. A bank attempt needs a position that names one
+// of its meters, and only a bank attempt takes one.
+func attemptBankMeter(a *models.OCRAttempt, position int) (*models.OCRBankMeter, error) {
+	if len(a.Meters) == 0 {
+		if position != 0 {
+			return nil, &middleware.AppError{HTTPStatus: 400, Key: "errors.bill.ocr_meter_required"}
+		}
+		return nil, nil
+	}
+	for i := range a.Meters {
+		if a.Meters[i].Position == position {
+			return &a.Meters[i], nil
+		}
+	}
+	return nil, &middleware.AppError{HTTPStatus: 400, Key: "errors.bill.ocr_meter_required"}
+}
+
+// attemptLinkUpdates links an OCR attempt to the bill made from it and, once
+// the reading is final, records whether the user kept the OCR value.
+func attemptLinkUpdates(billID string, a *models.OCRAttempt, finalReading float64, resolved bool) []firestore.Update {
+	updates := []firestore.Update{{Path: "billId", Value: billID}}
+	if resolved {
+		updates = append(updates, attemptResolveUpdates(a, finalReading)...)
+	}
+	return updates
+}
+
+func attemptResolveUpdates(a *models.OCRAttempt, finalReading float64) []firestore.Update {
+	return []firestore.Update{
+		{Path: "outcome", Value: string(attemptOutcome(a.Reading, finalReading))},
+		{Path: "finalReading", Value: finalReading},
+		{Path: "resolvedAt", Value: firestore.ServerTimestamp},
+	}
+}
+
+// attemptOutcome compares the OCR value with the reading the bill ended up
+// with. Readings are whole units, so any difference is a correction.
+func attemptOutcome(ocrReading, finalReading float64) models.OCRAttemptOutcome {
+	if ocrReading == finalReading {
+		return models.OCRAttemptAccepted
+	}
+	return models.OCRAttemptCorrected
+}
+
+// txGetBillReading loads a reading-log entry a new bill starts or ends on.
+// Only electricity readings qualify: the bill's top-level meter is electricity.
+func txGetBillReading(tx *firestore.Transaction, ref *firestore.DocumentRef) (*models.Reading, error) {
+	snap, err := tx.Get(ref)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, &middleware.AppError{HTTPStatus: 404, Key: "errors.reading.not_found"}
+		}
+		return nil, err
+	}
+	r, err := docToReading(snap)
+	if err != nil {
+		return nil, err
+	}
+	if r.MeterType != "" && r.MeterType != models.MeterTypeElectricity {
+		return nil, &middleware.AppError{HTTPStatus: 400, Key: "errors.bill.reading_meter_mismatch"}
+	}
+	return r, nil
+}
+
+// utilityCharges prices the water / gas meters of a new bill. Each meter has
+// its own reading chain, so the previous reading and the rate fall back to
+// the settings entry for that meter type, never to the electricity values.
+func utilityCharges(reqs []models.UtilityChargeRequest, settings *models.UserSettings, currency money.Currency) ([]models.UtilityCharge, money.Amount, error) {
+	if len(reqs) == 0 {
+		return nil, 0, nil
+	}
+	charges := make([]models.UtilityCharge, 0, len(reqs))
+	seen := make(map[models.MeterType]bool, len(reqs))
+	var sum money.Amount
+	for _, r := range reqs {
+		if !isUtilityMeter(r.MeterType) {
+			return nil, 0, &middleware.AppError{HTTPStatus: 400, Key: "errors.bill.invalid_meter_type"}
+		}
+		if seen[r.MeterType] {
+			return nil, 0, &middleware.AppError{HTTPStatus: 400, Key: "errors.bill.duplicate_meter_type"}
+		}
+		seen[r.MeterType] = true
+
+		prev := settings.PreviousReadings[r.MeterType]
+		if r.PreviousReading != nil {
+			prev = *r.PreviousReading
+		}
+		if r.MeterReading < prev {
+			return nil, 0, &middleware.AppError{HTTPStatus: 400, Key: "errors.bill.reading_decreased"}
+		}
+		rate := settings.DefaultUtilityRates[r.MeterType]
+		if r.Rate != nil {
+			rate = *r.Rate
+		}
+		if rate <= 0 {
+			return nil, 0, &middleware.AppError{HTTPStatus: 400, Key: "errors.bill.utility_rate_required"}
+		}
+
+		usage := money.Sub(r.MeterReading, prev)
+		cost := money.Cost(usage, rate, currency)
+		charges = append(charges, models.UtilityCharge{
+			MeterType:       r.MeterType,
+			MeterReading:    r.MeterReading,
+			PreviousReading: prev,
+			Usage:           usage,
+			Rate:            rate,
+			Cost:            cost,
+			ImageURL:        r.ImageURL,
+		})
+		sum += cost
+	}
+	return charges, sum, nil
+}
+
+// setLegacyBillAmounts mirrors the amounts into the float64 fields older
+// backend revisions read, so a rollback does not show zero totals.
+func setLegacyBillAmounts(bill *models.Bill) {
+	bill.LegacyElectricityCost = bill.ElectricityCost.Float64()
+	bill.LegacyRent = bill.Rent.Float64()
+	bill.LegacyTotalAmount = bill.TotalAmount.Float64()
+}
+
+// migrateLegacyBill fills the money fields of a bill written before they
+// existed (no currency) from its float64 fields. Float noise such as
+// 556.6500000000001 is dropped at the cent; the amount is not re-rounded so
+// history shows what the user saw at the time.
+func migrateLegacyBill(bill *models.Bill) {
+	if bill.Currency != "" {
+		return
+	}
+	bill.Currency = money.DefaultCurrency
+	bill.ElectricityCost = money.FromFloat(bill.LegacyElectricityCost)
+	bill.Rent = money.FromFloat(bill.LegacyRent)
+	bill.TotalAmount = money.FromFloat(bill.LegacyTotalAmount)
+}
+
+// maxBillingAnchorDay caps the anchor day so every month has that date; a cycle
+// anchored on the 31st would otherwise drift through February.
+const maxBillingAnchorDay = 28
+
+// periodGraceDays is how far an explicit periodEnd may run past one full
+// cycle. Taipower readers rarely come on exactly the same day each time.
+const periodGraceDays = 10
+
+// defaultTimezone is used for users who never picked one (and for bills
+// created before the setting existed). The app launched in Taiwan.
+const defaultTimezone = "Asia/Taipei"
+
+// loadTimezone resolves an IANA zone name; empty means defaultTimezone.
+func loadTimezone(name string) (*time.Location, error) {
+	if name == "" {
+		name = defaultTimezone
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("load timezone %q: %w", name, err)
+	}
+	return loc, nil
+}
+
+// parsePeriod converts "YYYY-MM" to time.Time (first day of the month at 00:00 in loc).
+func parsePeriod(period string, loc *time.Location) (time.Time, error) {
 	t, err := time.ParseInLocation("2006-01", period, loc)
 	if err != nil {
 		return time.Time{}, fmt.Errorf("invalid period %q (expected YYYY-MM): %w", period, err)
@@ -221,11 +1058,121 @@ func parsePeriod(period string) (time.Time, error) {
 	return t, nil
 }
 
+// nextBillRate is the electricity rate of the next bill: the one
+// CreateFromPhoto uses and the forecast projects. Create takes the rate from
+// the request, which the capture screen prefills from
+// settings.defaultElectricityRate, so that wins; the latest bill's rate covers
+// settings that never had one. 0 when neither does.
+func nextBillRate(settings *models.UserSettings, latest *models.Bill) float64 {
+	if settings.DefaultElectricityRate > 0 || latest == nil {
+		return settings.DefaultElectricityRate
+	}
+	return latest.ElectricityRate
+}
+
+// cycleMonths returns how many calendar months one billing cycle spans. Empty
+// or unknown values count as monthly so settings written before the field
+// existed keep their old behaviour.
+func cycleMonths(c models.BillingCycle) int {
+	if c == models.BillingCycleBimonthly {
+		return 2
+	}
+	return 1
+}
+
+func validBillingCycle(c models.BillingCycle) bool {
+	switch c {
+	case "", models.BillingCycleMonthly, models.BillingCycleBimonthly:
+		return true
+	default:
+		return false
+	}
+}
+
+// resolvePeriod works out the [start, end) window of a new bill.
+//
+//   - With explicit PeriodStart/PeriodEnd dates, those win. The window must be
+//     non-empty and no longer than one cycle plus periodGraceDays.
+//   - Otherwise the "YYYY-MM" label is the month the cycle starts in: the
+//     window opens on the anchor day and lasts one cycle. For a monthly cycle
+//     anchored on the 1st this is exactly the calendar month, as before.
+//
+// All dates are midnight in loc, the user's timezone. The label itself is
+// always validated because it is what the app displays.
+func resolvePeriod(req *models.CreateBillRequest, settings *models.UserSettings, loc *time.Location) (start, end time.Time, err error) {
+	monthStart, err := parsePeriod(req.Period, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	months := cycleMonths(settings.BillingCycle)
+
+	if req.PeriodStart != "" || req.PeriodEnd != "" {
+		if req.PeriodStart == "" || req.PeriodEnd == "" {
+			return time.Time{}, time.Time{}, fmt.Errorf("periodStart and periodEnd must be sent together")
+		}
+		start, err = time.ParseInLocation("2006-01-02", req.PeriodStart, loc)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid periodStart %q: %w", req.PeriodStart, err)
+		}
+		end, err = time.ParseInLocation("2006-01-02", req.PeriodEnd, loc)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid periodEnd %q: %w", req.PeriodEnd, err)
+		}
+		if !end.After(start) {
+			return time.Time{}, time.Time{}, fmt.Errorf("periodEnd %s is not after periodStart %s", req.PeriodEnd, req.PeriodStart)
+		}
+		if end.After(start.AddDate(0, months, periodGraceDays)) {
+			return time.Time{}, time.Time{}, fmt.Errorf("period %s..%s is longer than a %d-month cycle", req.PeriodStart, req.PeriodEnd, months)
+		}
+		return start, end, nil
+	}
+
+	anchor := settings.BillingAnchorDay
+	if anchor < 1 || anchor > maxBillingAnchorDay {
+		anchor = 1
+	}
+	start = monthStart.AddDate(0, 0, anchor-1)
+	return start, start.AddDate(0, months, 0), nil
+}
+
 func docToBill(snap *firestore.DocumentSnapshot) (*models.Bill, error) {
 	var bill models.Bill
 	if err := snap.DataTo(&bill); err != nil {
 		return nil, err
 	}
 	bill.ID = snap.Ref.ID
+	migrateLegacyBill(&bill)
+	localizeBill(&bill)
+	// Bills written before periodEnd existed were always calendar months.
+	if bill.PeriodEnd.IsZero() && !bill.PeriodStart.IsZero() {
+		bill.PeriodEnd = bill.PeriodStart.AddDate(0, 1, 0)
+	}
 	return &bill, nil
 }
+
+// localizeBill renders the bill's timestamps in the zone it was created in.
+// Firestore hands every timestamp back in UTC, which would show a Taipei
+// period starting on the 1st as the last day of the previous month.
+func localizeBill(bill *models.Bill) {
+	loc, err := loadTimezone(bill.Timezone)
+	if err != nil {
+		// A zone that no longer resolves is not worth failing a read over.
+		return
+	}
+	bill.Timezone = loc.String()
+	bill.PeriodStart = bill.PeriodStart.In(loc)
+	bill.PeriodEnd = bill.PeriodEnd.In(loc)
+	bill.CreatedAt = bill.CreatedAt.In(loc)
+	bill.UpdatedAt = bill.UpdatedAt.In(loc)
+	if bill.PaidAt != nil {
+		t := bill.PaidAt.In(loc)
+		bill.PaidAt = &t
+	}
+	if bill.DeletedAt != nil {
+		t := bill.DeletedAt.In(loc)
+		bill.DeletedAt = &t
+	}
+	if bill.OCR != nil {
+		bill.OCR.ProcessedAt = bill.OCR.ProcessedAt.In(loc)
+	}
+}
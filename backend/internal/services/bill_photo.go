@@ -0,0 +1,253 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"wattrent/internal/middleware"
+	"wattrent/internal/models"
+)
+
+// draftConfidenceThreshold is the OCR confidence below which a bill created
+// from a photo is saved as a draft for the user to confirm.
+const draftConfidenceThreshold = 0.8
+
+// CreateFromPhoto creates a bill from an uploaded meter photo in one call:
+// OCR with the stored previous reading, then Create with the user's default
+// rate and rent and the OCR result embedded.
+//
+// The bill's document ID is the billId the upload was signed for, so a client
+// retrying after a dropped response gets the same bill back instead of a
+// second one (and the OCR call is skipped).
+//
+// A low-confidence reading, one from another meter (OCRWarningWrongMeter) or
+// one below the previous reading gives a draft; see Confirm.
+func (s *BillService) CreateFromPhoto(ctx context.Context, uid string, req *models.CreateBillFromPhotoRequest) (*models.Bill, error) {
+	billID, err := billIDFromPhotoPath(uid, req.GCSPath)
+	if err != nil {
+		return nil, &middleware.AppError{HTTPStatus: 400, Key: "errors.bill.invalid_photo_path", Cause: err}
+	}
+	billRef := s.billsCol(uid).Doc(billID)
+	if existing, err := s.Get(ctx, uid, billID); err == nil {
+		return existing, nil
+	} else if !isNotFound(err) {
+		return nil, err
+	}
+	// Checked again when the bill is written; this saves the OCR call.
+	if _, err := s.trashCol(uid).Doc(billID).Get(ctx); err == nil {
+		return nil, &middleware.AppError{HTTPStatus: 409, Key: "errors.bill.in_trash"}
+	} else if status.Code(err) != codes.NotFound {
+		return nil, err
+	}
+	if s.ocr == nil {
+		return nil, &middleware.AppError{HTTPStatus: 503, Key: "errors.ocr.not_configured"}
+	}
+
+	settings, err := s.settings.Get(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	latest, err := s.Latest(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	rate := nextBillRate(settings, latest)
+	if rate <= 0 {
+		return nil, &middleware.AppError{HTTPStatus: 400, Key: "errors.bill.default_rate_required"}
+	}
+	period := req.Period
+	if period == "" {
+		if period, err = nextPeriod(settings, latest, time.Now()); err != nil {
+			return nil, err
+		}
+	}
+
+	ocr, err := s.ocr.Process(ctx, uid, &models.OCRRequest{
+		ImageURL:        req.GCSPath,
+		PreviousReading: settings.PreviousMeterReading,
+		MeterType:       models.MeterTypeElectricity,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	createReq := &models.CreateBillRequest{
+		MeterReading:    ocr.Reading,
+		ElectricityRate: rate,
+		Rent:            settings.DefaultRent,
+		Period:          period,
+		ImageURL:        req.GCSPath,
+		OCRAttemptID:    ocr.AttemptID,
+	}
+	// The attempt record supplies the embedded OCR result; this one only
+	// stands in when the attempt could not be recorded.
+	return s.create(ctx, uid, billRef, createReq, &photoDraft{
+		ocr: &models.OCRResult{
+			Confidence:    ocr.Confidence,
+			Model:         ocr.Model,
+			RawText:       ocr.RawText,
+			ProcessedAt:   time.Now().UTC(),
+			PromptVersion: ocr.PromptVersion,
+			Warnings:      ocr.Warnings,
+		},
+		lowConfidence: ocr.Reading <= 0 || ocr.Confidence < draftConfidenceThreshold,
+		wrongMeter:    slices.Contains(ocr.Warnings, models.OCRWarningWrongMeter),
+	})
+}
+
+// Confirm turns a draft into a normal bill, optionally with a corrected
+// reading, and advances the reading chain the draft held back unless a newer
+// bill already took it further (see confirmAdvancesChain).
+func (s *BillService) Confirm(ctx context.Context, uid, billID string, req *models.ConfirmBillRequest) (*models.Bill, error) {
+	billRef := s.billsCol(uid).Doc(billID)
+	settingsRef := s.fs.Collection("users").Doc(uid).Collection("settings").Doc(settingsDocID)
+
+	var corrected models.MeterType
+	err := s.fs.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		corrected = ""
+		snap, err := tx.Get(billRef)
+		if err != nil {
+			if status.Code(err) == codes.NotFound {
+				return &middleware.AppError{HTTPStatus: 404, Key: "errors.bill.not_found"}
+			}
+			return err
+		}
+		bill, err := docToBill(snap)
+		if err != nil {
+			return err
+		}
+		if bill.Status != models.BillStatusDraft {
+			return &middleware.AppError{HTTPStatus: 409, Key: "errors.bill.not_draft"}
+		}
+		var settings models.UserSettings
+		if snap, err := tx.Get(settingsRef); err == nil {
+			if err := snap.DataTo(&settings); err != nil {
+				return err
+			}
+		} else if status.Code(err) != codes.NotFound {
+			return err
+		}
+		var attemptRef *firestore.DocumentRef
+		var attempt *models.OCRAttempt
+		if bill.OCR != nil && bill.OCR.AttemptID != "" {
+			attemptRef = ocrAttemptsCol(s.fs, uid).Doc(bill.OCR.AttemptID)
+			if attempt, err = txGetOCRAttempt(tx, attemptRef); err != nil {
+				// The audit record is not worth blocking the user over.
+				if !isNotFound(err) {
+					return err
+				}
+				attempt = nil
+			}
+		}
+
+		reading := bill.MeterReading
+		if req.MeterReading != nil {
+			reading = *req.MeterReading
+		}
+		if reading < bill.PreviousReading {
+			return &middleware.AppError{HTTPStatus: 400, Key: "errors.bill.reading_decreased"}
+		}
+
+		usage, cost, rent, total := billTotals(bill.PreviousReading, reading, bill.ElectricityRate, bill.Rent, bill.Currency)
+		for _, u := range bill.Utilities {
+			total += u.Cost
+		}
+		bill.Status = ""
+		bill.MeterReading = reading
+		bill.ElectricityUsage = usage
+		bill.ElectricityCost = cost
+		bill.Rent = rent
+		bill.TotalAmount = total
+		bill.Emissions = billEmissions(settings.Region, bill.PeriodStart.Year(), usage)
+		bill.UpdatedAt = time.Now().UTC()
+		setLegacyBillAmounts(bill)
+
+		if err := tx.Set(billRef, bill); err != nil {
+			return err
+		}
+		if attempt != nil {
+			if err := tx.Update(attemptRef, attemptResolveUpdates(attempt, reading)); err != nil {
+				return err
+			}
+			if ok, err := txRecordCorrection(tx, s.fs, uid, attemptRef.ID, attempt, reading, &settings); err != nil {
+				return err
+			} else if ok {
+				corrected = attempt.MeterType
+			}
+		}
+		if !confirmAdvancesChain(reading, &settings) {
+			return nil
+		}
+		return tx.Set(settingsRef, map[string]interface{}{
+			"previousMeterReading": reading,
+			"updatedAt":            firestore.ServerTimestamp,
+		}, firestore.MergeAll)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if corrected != "" {
+		trimOCRCorrectionsQuietly(ctx, s.fs, uid, corrected)
+	}
+	return s.Get(ctx, uid, billID)
+}
+
+// ----------------------- helpers -----------------------
+
+// confirmAdvancesChain reports whether confirming a draft at reading moves
+// settings.previousMeterReading. It never moves backwards: a draft confirmed
+// after a newer bill was created is older than the chain.
+func confirmAdvancesChain(reading float64, settings *models.UserSettings) bool {
+	return reading >= settings.PreviousMeterReading
+}
+
+// billIDFromPhotoPath extracts {billId} from the gs://{bucket}/users/{uid}/bills/{billId}.{ext}
+// path /uploads/sign hands out, and rejects photos outside the caller's
+// folder.
+func billIDFromPhotoPath(uid, gcsPath string) (string, error) {
+	object, err := userObject(uid, gcsPath)
+	if err != nil {
+		return "", err
+	}
+	prefix := "users/" + uid + "/bills/"
+	if !strings.HasPrefix(object, prefix) {
+		return "", fmt.Errorf("photo %s is not in %s", gcsPath, prefix)
+	}
+	name := strings.TrimPrefix(object, prefix)
+	dot := strings.LastIndexByte(name, '.')
+	if dot <= 0 || !ValidBillID(name[:dot]) {
+		return "", fmt.Errorf("photo %s is not a bill photo", gcsPath)
+	}
+	return name[:dot], nil
+}
+
+// nextPeriod is the "YYYY-MM" label of the period after latest, or of the
+// current month when there is no bill yet.
+func nextPeriod(settings *models.UserSettings, latest *models.Bill, now time.Time) (string, error) {
+	loc, err := loadTimezone(settings.Timezone)
+	if err != nil {
+		return "", err
+	}
+	if latest != nil && !latest.PeriodEnd.IsZero() {
+		return latest.PeriodEnd.In(loc).Format("2006-01"), nil
+	}
+	return now.In(loc).Format("2006-01"), nil
+}
+
+// isNotFound matches both the 404 AppError Get returns and a raw Firestore
+// NotFound.
+func isNotFound(err error) bool {
+	var appErr *middleware.AppError
+	if errors.As(err, &appErr) {
+		return appErr.HTTPStatus == 404
+	}
+	return status.Code(err) == codes.NotFound
+}
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"wattrent/internal/models"
+)
+
+func TestBillIDFromPhotoPath(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{name: "signed upload path", path: "gs://meters/users/u1/bills/abc123.jpg", want: "abc123"},
+		{name: "other user's photo", path: "gs://meters/users/u2/bills/abc123.jpg", wantErr: true},
+		{name: "prefix of another uid", path: "gs://meters/users/u10/bills/abc123.jpg", wantErr: true},
+		{name: "nested object", path: "gs://meters/users/u1/bills/x/abc123.jpg", wantErr: true},
+		{name: "no extension", path: "gs://meters/users/u1/bills/abc123", wantErr: true},
+		{name: "rendition", path: "gs://meters/users/u1/bills/abc123_thumb.jpg", wantErr: true},
+		{name: "dotted name", path: "gs://meters/users/u1/bills/abc.123.jpg", wantErr: true},
+		{name: "not gs", path: "https://example.com/users/u1/bills/abc123.jpg", wantErr: true},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := billIDFromPhotoPath("u1", tc.path)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("billIDFromPhotoPath = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestNextPeriod(t *testing.T) {
+	t.Parallel()
+
+	settings := &models.UserSettings{Timezone: "Asia/Taipei"}
+	// Stored in UTC, the end of April in Taipei is still April 30th.
+	latest := &models.Bill{PeriodEnd: time.Date(2026, 4, 30, 16, 0, 0, 0, time.UTC)}
+	now := time.Date(2026, 5, 20, 0, 0, 0, 0, time.UTC)
+
+	if got, err := nextPeriod(settings, latest, now); err != nil || got != "2026-05" {
+		t.Errorf("nextPeriod(latest) = %q, %v; want 2026-05", got, err)
+	}
+	if got, err := nextPeriod(settings, nil, now); err != nil || got != "2026-05" {
+		t.Errorf("nextPeriod(no bills) = %q, %v; want 2026-05", got, err)
+	}
+}
+
+func TestConfirmAdvancesChain(t *testing.T) {
+	t.Parallel()
+
+	settings := &models.UserSettings{PreviousMeterReading: 36034}
+	tests := []struct {
+		name    string
+		reading float64
+		want    bool
+	}{
+		{name: "newer reading", reading: 36120, want: true},
+		{name: "same reading", reading: 36034, want: true},
+		// A newer bill moved the chain past this draft.
+		{name: "older draft", reading: 35920},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			if got := confirmAdvancesChain(tc.reading, settings); got != tc.want {
+				t.Errorf("confirmAdvancesChain(%v) = %v, want %v", tc.reading, got, tc.want)
+			}
+		})
+	}
+}
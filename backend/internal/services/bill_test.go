@@ -1,10 +1,26 @@
 package services
 
 import (
+	"errors"
+	"slices"
 	"testing"
 	"time"
+
+	"wattrent/internal/middleware"
+	"wattrent/internal/models"
+	"wattrent/internal/money"
 )
 
+var taipei = mustLoadLocation("Asia/Taipei")
+
+func mustLoadLocation(name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		panic(err)
+	}
+	return loc
+}
+
 // parsePeriod is a pure helper; covering it directly keeps us off Firestore.
 func TestParsePeriod(t *testing.T) {
 	t.Parallel()
@@ -29,7 +45,7 @@ func TestParsePeriod(t *testing.T) {
 		tc := tc
 		t.Run(tc.name, func(t *testing.T) {
 			t.Parallel()
-			got, err := parsePeriod(tc.input)
+			got, err := parsePeriod(tc.input, taipei)
 			if tc.wantErr {
 				if err == nil {
 					t.Fatalf("expected error for %q, got nil (parsed=%v)", tc.input, got)
@@ -45,18 +61,155 @@ func TestParsePeriod(t *testing.T) {
 			if got.Day() != 1 {
 				t.Fatalf("parsePeriod(%q) day = %d, want 1", tc.input, got.Day())
 			}
+			if got.Hour() != 0 || got.Location() != taipei {
+				t.Fatalf("parsePeriod(%q) = %v, want midnight Asia/Taipei", tc.input, got)
+			}
+		})
+	}
+}
+
+func TestResolvePeriod(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		req       models.CreateBillRequest
+		settings  models.UserSettings
+		loc       *time.Location
+		wantStart string
+		wantEnd   string
+		wantErr   bool
+	}{
+		{
+			name:      "legacy settings: calendar month",
+			req:       models.CreateBillRequest{Period: "2026-05"},
+			wantStart: "2026-05-01", wantEnd: "2026-06-01",
+		},
+		{
+			name:      "monthly anchored on the 15th",
+			req:       models.CreateBillRequest{Period: "2026-05"},
+			settings:  models.UserSettings{BillingCycle: models.BillingCycleMonthly, BillingAnchorDay: 15},
+			wantStart: "2026-05-15", wantEnd: "2026-06-15",
+		},
+		{
+			name:      "bimonthly crosses the year",
+			req:       models.CreateBillRequest{Period: "2025-12"},
+			settings:  models.UserSettings{BillingCycle: models.BillingCycleBimonthly},
+			wantStart: "2025-12-01", wantEnd: "2026-02-01",
+		},
+		{
+			name:      "out of range anchor falls back to the 1st",
+			req:       models.CreateBillRequest{Period: "2026-02"},
+			settings:  models.UserSettings{BillingAnchorDay: 31},
+			wantStart: "2026-02-01", wantEnd: "2026-03-01",
+		},
+		{
+			name:      "explicit dates win",
+			req:       models.CreateBillRequest{Period: "2026-05", PeriodStart: "2026-05-03", PeriodEnd: "2026-07-04"},
+			settings:  models.UserSettings{BillingCycle: models.BillingCycleBimonthly},
+			wantStart: "2026-05-03", wantEnd: "2026-07-04",
+		},
+		{
+			name:      "user zone west of UTC",
+			req:       models.CreateBillRequest{Period: "2026-05"},
+			loc:       mustLoadLocation("America/Los_Angeles"),
+			wantStart: "2026-05-01T00:00:00-07:00", wantEnd: "2026-06-01T00:00:00-07:00",
+		},
+		{
+			name:     "explicit window longer than the cycle",
+			req:      models.CreateBillRequest{Period: "2026-05", PeriodStart: "2026-05-03", PeriodEnd: "2026-07-04"},
+			settings: models.UserSettings{BillingCycle: models.BillingCycleMonthly},
+			wantErr:  true,
+		},
+		{
+			name:    "explicit end before start",
+			req:     models.CreateBillRequest{Period: "2026-05", PeriodStart: "2026-05-10", PeriodEnd: "2026-05-10"},
+			wantErr: true,
+		},
+		{
+			name:    "only one explicit date",
+			req:     models.CreateBillRequest{Period: "2026-05", PeriodStart: "2026-05-10"},
+			wantErr: true,
+		},
+		{
+			name:    "bad label",
+			req:     models.CreateBillRequest{Period: "2026/05"},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			loc := tc.loc
+			if loc == nil {
+				loc = taipei
+			}
+			start, end, err := resolvePeriod(&tc.req, &tc.settings, loc)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %v..%v", start, end)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			layout := "2006-01-02"
+			if len(tc.wantStart) > len(layout) {
+				layout = time.RFC3339
+			}
+			if got := start.Format(layout); got != tc.wantStart {
+				t.Errorf("start = %s, want %s", got, tc.wantStart)
+			}
+			if got := end.Format(layout); got != tc.wantEnd {
+				t.Errorf("end = %s, want %s", got, tc.wantEnd)
+			}
 		})
 	}
 }
 
-// computeBillTotals mirrors the math inside BillService.Create so we can verify
-// the cost/usage formulas without touching Firestore. If you change the formula
-// in bill.go, mirror it here and add a row below.
-func computeBillTotals(prev, current, rate, rent float64) (usage, cost, total float64) {
-	usage = current - prev
-	cost = usage * rate
-	total = cost + rent
-	return
+func TestLocalizeBill(t *testing.T) {
+	t.Parallel()
+
+	// Firestore returns timestamps in UTC: midnight 1 May in Taipei comes back
+	// as 30 April 16:00Z.
+	start := time.Date(2026, 4, 30, 16, 0, 0, 0, time.UTC)
+	paid := time.Date(2026, 6, 2, 1, 0, 0, 0, time.UTC)
+
+	legacy := models.Bill{PeriodStart: start, PaidAt: &paid}
+	localizeBill(&legacy)
+	if legacy.Timezone != "Asia/Taipei" {
+		t.Errorf("legacy timezone = %q, want Asia/Taipei", legacy.Timezone)
+	}
+	if got := legacy.PeriodStart.Format("2006-01-02T15:04"); got != "2026-05-01T00:00" {
+		t.Errorf("legacy periodStart = %s", got)
+	}
+	if got := legacy.PaidAt.Format(time.RFC3339); got != "2026-06-02T09:00:00+08:00" {
+		t.Errorf("legacy paidAt = %s", got)
+	}
+
+	berlin := models.Bill{PeriodStart: start, Timezone: "Europe/Berlin"}
+	localizeBill(&berlin)
+	if got := berlin.PeriodStart.Format(time.RFC3339); got != "2026-04-30T18:00:00+02:00" {
+		t.Errorf("berlin periodStart = %s", got)
+	}
+}
+
+func TestValidateTimezone(t *testing.T) {
+	t.Parallel()
+
+	for _, ok := range []string{"", "UTC", "Asia/Taipei", "America/New_York"} {
+		if err := validateTimezone(ok); err != nil {
+			t.Errorf("validateTimezone(%q) = %v, want nil", ok, err)
+		}
+	}
+	for _, bad := range []string{"Local", "Taipei", "Mars/Olympus_Mons", "+08:00"} {
+		if err := validateTimezone(bad); err == nil {
+			t.Errorf("validateTimezone(%q) = nil, want error", bad)
+		}
+	}
 }
 
 func TestBillTotals(t *testing.T) {
@@ -67,26 +220,39 @@ func TestBillTotals(t *testing.T) {
 		prev      float64
 		current   float64
 		rate      float64
-		rent      float64
+		rent      money.Amount
+		currency  money.Currency
 		wantUsage float64
-		wantCost  float64
-		wantTotal float64
+		wantCost  money.Amount
+		wantTotal money.Amount
 	}{
 		{
-			name: "typical month", prev: 1000, current: 1250, rate: 4.5, rent: 8000,
-			wantUsage: 250, wantCost: 1125, wantTotal: 9125,
+			name: "typical month", prev: 1000, current: 1250, rate: 4.5, rent: money.FromMajor(8000),
+			wantUsage: 250, wantCost: money.FromMajor(1125), wantTotal: money.FromMajor(9125),
+		},
+		{
+			name: "first bill (no previous)", prev: 0, current: 100, rate: 5, rent: money.FromMajor(10000),
+			wantUsage: 100, wantCost: money.FromMajor(500), wantTotal: money.FromMajor(10500),
+		},
+		{
+			name: "no usage this month", prev: 500, current: 500, rate: 4.5, rent: money.FromMajor(8000),
+			wantUsage: 0, wantCost: 0, wantTotal: money.FromMajor(8000),
+		},
+		{
+			name: "fractional reading", prev: 1000.0, current: 1100.5, rate: 4.0, rent: money.FromMajor(7500),
+			wantUsage: 100.5, wantCost: money.FromMajor(402), wantTotal: money.FromMajor(7902),
 		},
 		{
-			name: "first bill (no previous)", prev: 0, current: 100, rate: 5, rent: 10000,
-			wantUsage: 100, wantCost: 500, wantTotal: 10500,
+			name: "float noise rounds to whole TWD", prev: 1000.1, current: 1123.8, rate: 4.5, rent: money.FromMajor(8000),
+			wantUsage: 123.7, wantCost: money.FromMajor(557), wantTotal: money.FromMajor(8557),
 		},
 		{
-			name: "no usage this month", prev: 500, current: 500, rate: 4.5, rent: 8000,
-			wantUsage: 0, wantCost: 0, wantTotal: 8000,
+			name: "USD keeps cents", prev: 1000.1, current: 1123.8, rate: 0.15, rent: 120050, currency: money.USD,
+			wantUsage: 123.7, wantCost: 1856, wantTotal: 121906,
 		},
 		{
-			name: "fractional reading", prev: 1000.0, current: 1100.5, rate: 4.0, rent: 7500,
-			wantUsage: 100.5, wantCost: 402, wantTotal: 7902,
+			name: "fractional TWD rent is rounded", prev: 0, current: 10, rate: 4.5, rent: 800050,
+			wantUsage: 10, wantCost: money.FromMajor(45), wantTotal: money.FromMajor(8046),
 		},
 	}
 
@@ -94,15 +260,300 @@ func TestBillTotals(t *testing.T) {
 		tc := tc
 		t.Run(tc.name, func(t *testing.T) {
 			t.Parallel()
-			usage, cost, total := computeBillTotals(tc.prev, tc.current, tc.rate, tc.rent)
+			usage, cost, _, total := billTotals(tc.prev, tc.current, tc.rate, tc.rent, tc.currency)
 			if usage != tc.wantUsage {
 				t.Errorf("usage = %v, want %v", usage, tc.wantUsage)
 			}
 			if cost != tc.wantCost {
-				t.Errorf("cost = %v, want %v", cost, tc.wantCost)
+				t.Errorf("cost = %s, want %s", cost, tc.wantCost)
 			}
 			if total != tc.wantTotal {
-				t.Errorf("total = %v, want %v", total, tc.wantTotal)
+				t.Errorf("total = %s, want %s", total, tc.wantTotal)
+			}
+		})
+	}
+}
+
+func TestMigrateLegacyBill(t *testing.T) {
+	t.Parallel()
+
+	legacy := models.Bill{LegacyElectricityCost: 556.6500000000001, LegacyRent: 8000, LegacyTotalAmount: 8556.65}
+	migrateLegacyBill(&legacy)
+	if legacy.Currency != money.TWD {
+		t.Errorf("currency = %q, want TWD", legacy.Currency)
+	}
+	if legacy.ElectricityCost != 55665 || legacy.Rent != money.FromMajor(8000) || legacy.TotalAmount != 855665 {
+		t.Errorf("migrated = %s / %s / %s", legacy.ElectricityCost, legacy.Rent, legacy.TotalAmount)
+	}
+
+	// Already migrated: the minor fields win even if the floats disagree.
+	current := models.Bill{Currency: money.USD, TotalAmount: 100, LegacyTotalAmount: 999}
+	migrateLegacyBill(&current)
+	if current.TotalAmount != 100 || current.Currency != money.USD {
+		t.Errorf("current bill changed: %+v", current)
+	}
+}
+
+func TestMigrateLegacySettings(t *testing.T) {
+	t.Parallel()
+
+	legacy := models.UserSettings{LegacyDefaultRent: 7500.5}
+	migrateLegacySettings(&legacy)
+	if legacy.DefaultRent != 750050 || legacy.Currency != money.TWD {
+		t.Errorf("migrated = %s %q", legacy.DefaultRent, legacy.Currency)
+	}
+
+	// Currency patched onto an old document before the rent was ever rewritten.
+	patched := models.UserSettings{Currency: money.USD, LegacyDefaultRent: 1200}
+	migrateLegacySettings(&patched)
+	if patched.DefaultRent != money.FromMajor(1200) || patched.Currency != money.USD {
+		t.Errorf("patched = %s %q", patched.DefaultRent, patched.Currency)
+	}
+}
+
+func TestUtilityCharges(t *testing.T) {
+	t.Parallel()
+
+	f := func(v float64) *float64 { return &v }
+	settings := &models.UserSettings{
+		PreviousMeterReading: 36000, // electricity chain must not leak into water / gas
+		PreviousReadings:     map[models.MeterType]float64{models.MeterTypeWater: 840.5},
+		DefaultUtilityRates:  map[models.MeterType]float64{models.MeterTypeWater: 12.075, models.MeterTypeGas: 18.5},
+	}
+
+	charges, sum, err := utilityCharges([]models.UtilityChargeRequest{
+		{MeterType: models.MeterTypeWater, MeterReading: 852.5},
+		{MeterType: models.MeterTypeGas, MeterReading: 130, PreviousReading: f(118), Rate: f(20)},
+	}, settings, money.TWD)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(charges) != 2 {
+		t.Fatalf("charges = %+v", charges)
+	}
+	water, gas := charges[0], charges[1]
+	if water.PreviousReading != 840.5 || water.Usage != 12 || water.Rate != 12.075 || water.Cost != money.FromMajor(145) {
+		t.Errorf("water = %+v", water)
+	}
+	if gas.PreviousReading != 118 || gas.Usage != 12 || gas.Rate != 20 || gas.Cost != money.FromMajor(240) {
+		t.Errorf("gas = %+v", gas)
+	}
+	if sum != money.FromMajor(385) {
+		t.Errorf("sum = %s, want 385", sum)
+	}
+
+	noGasRate := &models.UserSettings{PreviousReadings: settings.PreviousReadings}
+	errCases := map[string]struct {
+		reqs     []models.UtilityChargeRequest
+		settings *models.UserSettings
+		key      string
+	}{
+		"electricity is not a utility line": {
+			reqs: []models.UtilityChargeRequest{{MeterType: models.MeterTypeElectricity, MeterReading: 1}},
+			key:  "errors.bill.invalid_meter_type",
+		},
+		"duplicate meter": {
+			reqs: []models.UtilityChargeRequest{{MeterType: models.MeterTypeGas, MeterReading: 1}, {MeterType: models.MeterTypeGas, MeterReading: 2}},
+			key:  "errors.bill.duplicate_meter_type",
+		},
+		"reading went down": {
+			reqs: []models.UtilityChargeRequest{{MeterType: models.MeterTypeWater, MeterReading: 800}},
+			key:  "errors.bill.reading_decreased",
+		},
+		"no rate anywhere": {
+			reqs:     []models.UtilityChargeRequest{{MeterType: models.MeterTypeGas, MeterReading: 10}},
+			settings: noGasRate,
+			key:      "errors.bill.utility_rate_required",
+		},
+	}
+	for name, tc := range errCases {
+		s := settings
+		if tc.settings != nil {
+			s = tc.settings
+		}
+		_, _, err := utilityCharges(tc.reqs, s, money.TWD)
+		var ae *middleware.AppError
+		if !errors.As(err, &ae) || ae.Key != tc.key {
+			t.Errorf("%s: err = %v, want %s", name, err, tc.key)
+		}
+	}
+}
+
+func TestInHistory(t *testing.T) {
+	t.Parallel()
+
+	own := &models.Bill{ID: "own", Period: "2025-03", MeterReading: 1200}
+	room := &models.Bill{ID: "room", Period: "2025-04", MeterReading: 80, Room: "3F"}
+	draft := &models.Bill{ID: "draft", Period: "2025-04", MeterReading: 1300, Status: models.BillStatusDraft}
+
+	// Newest period first, as History reads them: the room's bill and the
+	// draft are newer than the user's own bill, which is still the latest.
+	bills := []*models.Bill{room, draft, own}
+	if i := slices.IndexFunc(bills, inHistory); i < 0 || bills[i] != own {
+		t.Errorf("latest in history = %d, want the user's own bill", i)
+	}
+	for _, b := range []*models.Bill{room, draft} {
+		if inHistory(b) {
+			t.Errorf("%s bill is in the history", b.ID)
+		}
+	}
+}
+
+func TestBillPhotoPaths(t *testing.T) {
+	t.Parallel()
+
+	bill := &models.Bill{
+		ImageURL: "gs://b/users/u/readings/r1.jpg",
+		Utilities: []models.UtilityCharge{
+			{MeterType: models.MeterTypeWater, ImageURL: "gs://b/users/u/bills/w.jpg"},
+			{MeterType: models.MeterTypeGas, ImageURL: "gs://b/users/u/bills/w.jpg"},
+		},
+	}
+
+	got := billPhotoPaths(bill, nil)
+	want := []string{"gs://b/users/u/readings/r1.jpg", "gs://b/users/u/bills/w.jpg"}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("billPhotoPaths = %v, want %v", got, want)
+	}
+
+	// The photo borrowed from a logged reading stays with the reading.
+	got = billPhotoPaths(bill, map[string]bool{"gs://b/users/u/readings/r1.jpg": true})
+	if len(got) != 1 || got[0] != "gs://b/users/u/bills/w.jpg" {
+		t.Errorf("billPhotoPaths with keep = %v", got)
+	}
+
+	if got := billPhotoPaths(&models.Bill{}, nil); len(got) != 0 {
+		t.Errorf("billPhotoPaths(no photos) = %v", got)
+	}
+}
+
+func TestAttemptLinkUpdates(t *testing.T) {
+	t.Parallel()
+
+	attempt := &models.OCRAttempt{Reading: 1234}
+	tests := []struct {
+		name     string
+		final    float64
+		resolved bool
+		want     map[string]interface{}
+	}{
+		{"draft links only", 1234, false, map[string]interface{}{"billId": "b1"}},
+		{"accepted", 1234, true, map[string]interface{}{"billId": "b1", "outcome": "accepted", "finalReading": 1234.0}},
+		{"corrected", 1243, true, map[string]interface{}{"billId": "b1", "outcome": "corrected", "finalReading": 1243.0}},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			got := make(map[string]interface{})
+			for _, u := range attemptLinkUpdates("b1", attempt, tc.final, tc.resolved) {
+				if u.Path == "resolvedAt" {
+					continue
+				}
+				got[u.Path] = u.Value
+			}
+			if len(got) != len(tc.want) {
+				t.Fatalf("updates = %v, want %v", got, tc.want)
+			}
+			for k, v := range tc.want {
+				if got[k] != v {
+					t.Errorf("%s = %v, want %v", k, got[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckAttemptWarnings(t *testing.T) {
+	t.Parallel()
+
+	wrong := &models.OCRAttempt{Warnings: []models.OCRWarning{models.OCRWarningWrongMeter}}
+	var appErr *middleware.AppError
+	if err := checkAttemptWarnings(wrong, false); !errors.As(err, &appErr) || appErr.HTTPStatus != 409 || appErr.Key != "errors.bill.wrong_meter" {
+		t.Errorf("unconfirmed wrong meter: err = %v, want 409 errors.bill.wrong_meter", err)
+	}
+	if err := checkAttemptWarnings(wrong, true); err != nil {
+		t.Errorf("confirmed wrong meter: err = %v", err)
+	}
+	if err := checkAttemptWarnings(&models.OCRAttempt{}, false); err != nil {
+		t.Errorf("no warnings: err = %v", err)
+	}
+}
+
+func TestAttemptBankMeter(t *testing.T) {
+	t.Parallel()
+
+	bank := &models.OCRAttempt{Meters: []models.OCRBankMeter{{Position: 1, Reading: 1204}, {Position: 2, Reading: 877, Room: "3F"}}}
+	tests := []struct {
+		name     string
+		attempt  *models.OCRAttempt
+		position int
+		want     float64 // reading of the meter returned; -1 for nil
+		wantErr  bool
+	}{
+		{name: "single meter", attempt: &models.OCRAttempt{Reading: 36034}, want: -1},
+		{name: "single meter with a position", attempt: &models.OCRAttempt{Reading: 36034}, position: 1, wantErr: true},
+		{name: "bank meter", attempt: bank, position: 2, want: 877},
+		{name: "bank without a position", attempt: bank, wantErr: true},
+		{name: "bank position out of range", attempt: bank, position: 3, wantErr: true},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			m, err := attemptBankMeter(tc.attempt, tc.position)
+			if tc.wantErr {
+				var appErr *middleware.AppError
+				if !errors.As(err, &appErr) || appErr.HTTPStatus != 400 || appErr.Key != "errors.bill.ocr_meter_required" {
+					t.Errorf("err = %v, want 400 errors.bill.ocr_meter_required", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if (m == nil) != (tc.want == -1) || (m != nil && m.Reading != tc.want) {
+				t.Errorf("meter = %+v, want reading %v", m, tc.want)
+			}
+		})
+	}
+}
+
+func TestValidateBillPhotos(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		req     models.CreateBillRequest
+		wantErr bool
+	}{
+		{name: "no photos"},
+		{name: "own photos", req: models.CreateBillRequest{
+			ImageURL:  "gs://b/users/u1/bills/b1.jpg",
+			Utilities: []models.UtilityChargeRequest{{MeterType: models.MeterTypeWater, ImageURL: "gs://b/users/u1/bills/w1.jpg"}},
+		}},
+		{name: "another user's photo", req: models.CreateBillRequest{ImageURL: "gs://b/users/u2/bills/b1.jpg"}, wantErr: true},
+		{name: "another user's utility photo", req: models.CreateBillRequest{
+			Utilities: []models.UtilityChargeRequest{{MeterType: models.MeterTypeGas, ImageURL: "gs://b/users/u2/bills/g1.jpg"}},
+		}, wantErr: true},
+		{name: "not gs", req: models.CreateBillRequest{ImageURL: "https://example.com/users/u1/b1.jpg"}, wantErr: true},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			err := validateBillPhotos("u1", &tc.req)
+			if !tc.wantErr {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			var appErr *middleware.AppError
+			if !errors.As(err, &appErr) || appErr.HTTPStatus != 400 || appErr.Key != "errors.bill.invalid_image_url" {
+				t.Errorf("err = %v, want 400 errors.bill.invalid_image_url", err)
 			}
 		})
 	}
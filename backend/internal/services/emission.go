@@ -0,0 +1,136 @@
+package services
+
+import (
+	"math"
+	"sort"
+
+	"wattrent/internal/models"
+)
+
+// defaultRegion is the emission region for users who never picked one.
+const defaultRegion = "TW"
+
+// emissionTableVersion names the factor table new bills are computed with.
+// Bump it (and add a new entry to emissionTables, keeping the old one) when
+// factors are published or revised; bills keep the version they were created
+// with in BillEmissions.TableVersion.
+const emissionTableVersion = "2025.1"
+
+// emissionTables holds grid emission factors in kgCO2e per kWh, by table
+// version, region and year.
+//
+// TW: electricity emission factors published yearly by the Energy
+// Administration, Ministry of Economic Affairs (電力排碳係數).
+var emissionTables = map[string]map[string]map[int]float64{
+	"2025.1": {
+		"TW": {
+			2017: 0.554,
+			2018: 0.533,
+			2019: 0.509,
+			2020: 0.502,
+			2021: 0.509,
+			2022: 0.495,
+			2023: 0.494,
+			2024: 0.474,
+		},
+	},
+}
+
+// validEmissionRegion reports whether the current table has factors for region.
+func validEmissionRegion(region string) bool {
+	_, ok := emissionTables[emissionTableVersion][region]
+	return ok
+}
+
+// emissionFactor looks up region's factor for year in table version. Factors
+// are published with a lag of a year or more, so a year past the end of the
+// table uses the newest factor, and one before the start uses the oldest.
+func emissionFactor(version, region string, year int) (factor float64, factorYear int, ok bool) {
+	byYear := emissionTables[version][region]
+	if len(byYear) == 0 {
+		return 0, 0, false
+	}
+	years := make([]int, 0, len(byYear))
+	for y := range byYear {
+		years = append(years, y)
+	}
+	sort.Ints(years)
+	factorYear = years[0]
+	for _, y := range years {
+		if y > year {
+			break
+		}
+		factorYear = y
+	}
+	return byYear[factorYear], factorYear, true
+}
+
+// billEmissions estimates the emissions of usage kWh consumed in year with the
+// current table. Nil when region has no factors.
+func billEmissions(region string, year int, usage float64) *models.BillEmissions {
+	if region == "" {
+		region = defaultRegion
+	}
+	factor, factorYear, ok := emissionFactor(emissionTableVersion, region, year)
+	if !ok {
+		return nil
+	}
+	return &models.BillEmissions{
+		KgCO2e:       roundHundredth(usage * factor),
+		Factor:       factor,
+		Region:       region,
+		FactorYear:   factorYear,
+		TableVersion: emissionTableVersion,
+	}
+}
+
+// yearlyEmissions totals bills by the year their period starts in, oldest
+// year first. Bills without stored emissions are estimated in region with
+// the current table and counted in Estimated. Only bills of the user's own
+// history count (see inHistory): a draft's reading is unconfirmed, and a
+// room's usage is its tenant's.
+func yearlyEmissions(bills []*models.Bill, region string) []models.YearlyEmissions {
+	byYear := make(map[int]*models.YearlyEmissions)
+	for _, b := range bills {
+		if !inHistory(b) {
+			continue
+		}
+		year := b.PeriodStart.Year()
+		y := byYear[year]
+		if y == nil {
+			y = &models.YearlyEmissions{Year: year}
+			byYear[year] = y
+		}
+		e := b.Emissions
+		if e == nil {
+			if e = billEmissions(region, year, b.ElectricityUsage); e == nil {
+				continue
+			}
+			y.Estimated++
+		}
+		y.Bills++
+		y.Usage += b.ElectricityUsage
+		y.KgCO2e += e.KgCO2e
+	}
+
+	out := make([]models.YearlyEmissions, 0, len(byYear))
+	for _, y := range byYear {
+		y.Usage = roundHundredth(y.Usage)
+		y.KgCO2e = roundHundredth(y.KgCO2e)
+		out = append(out, *y)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Year < out[j].Year })
+	for i := 1; i < len(out); i++ {
+		prev := out[i-1]
+		if prev.Year != out[i].Year-1 || prev.KgCO2e == 0 {
+			continue
+		}
+		pct := math.Round((out[i].KgCO2e-prev.KgCO2e)/prev.KgCO2e*1000) / 10
+		out[i].ChangePct = &pct
+	}
+	return out
+}
+
+func roundHundredth(f float64) float64 {
+	return math.Round(f*100) / 100
+}
@@ -0,0 +1,91 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"wattrent/internal/models"
+)
+
+func TestEmissionFactor(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		region     string
+		year       int
+		wantFactor float64
+		wantYear   int
+		wantOK     bool
+	}{
+		{name: "published year", region: "TW", year: 2022, wantFactor: 0.495, wantYear: 2022, wantOK: true},
+		{name: "not yet published uses newest", region: "TW", year: 2030, wantFactor: 0.474, wantYear: 2024, wantOK: true},
+		{name: "before the table uses oldest", region: "TW", year: 2010, wantFactor: 0.554, wantYear: 2017, wantOK: true},
+		{name: "unknown region", region: "XX", year: 2022},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			f, y, ok := emissionFactor(emissionTableVersion, tc.region, tc.year)
+			if ok != tc.wantOK || f != tc.wantFactor || y != tc.wantYear {
+				t.Errorf("emissionFactor = (%v, %d, %v), want (%v, %d, %v)", f, y, ok, tc.wantFactor, tc.wantYear, tc.wantOK)
+			}
+		})
+	}
+}
+
+func TestBillEmissions(t *testing.T) {
+	t.Parallel()
+
+	e := billEmissions("", 2023, 250)
+	if e == nil {
+		t.Fatal("billEmissions = nil for the default region")
+	}
+	want := models.BillEmissions{KgCO2e: 123.5, Factor: 0.494, Region: "TW", FactorYear: 2023, TableVersion: emissionTableVersion}
+	if *e != want {
+		t.Errorf("billEmissions = %+v, want %+v", *e, want)
+	}
+	if e := billEmissions("XX", 2023, 250); e != nil {
+		t.Errorf("billEmissions(XX) = %+v, want nil", e)
+	}
+}
+
+func TestYearlyEmissions(t *testing.T) {
+	t.Parallel()
+
+	bill := func(year int, usage float64, e *models.BillEmissions) *models.Bill {
+		return &models.Bill{
+			PeriodStart:      time.Date(year, time.March, 1, 0, 0, 0, 0, taipei),
+			ElectricityUsage: usage,
+			Emissions:        e,
+		}
+	}
+	// A stored figure from an older table revision is kept as is.
+	stored := &models.BillEmissions{KgCO2e: 60, Factor: 0.6, Region: "TW", FactorYear: 2021, TableVersion: "old"}
+	bills := []*models.Bill{
+		bill(2023, 200, nil), // estimated: 200 * 0.494
+		bill(2022, 100, stored),
+		bill(2022, 100, nil), // estimated: 100 * 0.495
+	}
+	draft := bill(2023, 500, nil)
+	draft.Status = models.BillStatusDraft
+	room := bill(2023, 300, nil)
+	room.Room = "3F"
+	bills = append(bills, draft, room)
+
+	got := yearlyEmissions(bills, "TW")
+	if len(got) != 2 {
+		t.Fatalf("len = %d, want 2: %+v", len(got), got)
+	}
+	y22, y23 := got[0], got[1]
+	if y22.Year != 2022 || y22.Bills != 2 || y22.Estimated != 1 || y22.Usage != 200 || y22.KgCO2e != 109.5 {
+		t.Errorf("2022 = %+v", y22)
+	}
+	if y22.ChangePct != nil {
+		t.Errorf("2022 ChangePct = %v, want nil", *y22.ChangePct)
+	}
+	if y23.Year != 2023 || y23.KgCO2e != 98.8 || y23.ChangePct == nil || *y23.ChangePct != -9.8 {
+		t.Errorf("2023 = %+v (ChangePct %v)", y23, y23.ChangePct)
+	}
+}
@@ -0,0 +1,293 @@
+package services
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"wattrent/internal/middleware"
+	"wattrent/internal/models"
+)
+
+// ForecastService projects the electricity usage and cost of the open period
+// (the one after the latest bill) from the reading log and bill history.
+//
+// It reads only; nothing it computes is persisted.
+type ForecastService struct {
+	settings *SettingsService
+	bills    *BillService
+	readings *ReadingService
+}
+
+func NewForecastService(settings *SettingsService, bills *BillService, readings *ReadingService) *ForecastService {
+	return &ForecastService{settings: settings, bills: bills, readings: readings}
+}
+
+// forecastHistoryBills is how much bill history is considered: three years of
+// monthly bills is enough for the seasonal comparison.
+const forecastHistoryBills = 36
+
+// Forecast projects the open period as of now.
+//
+// The period is the one the next bill would be created for (see
+// forecastPeriod), with its usage counted from the latest bill's reading. The
+// newest electricity reading logged since, if any, gives the usage so far; the
+// rest is extrapolated (see projectUsage).
+func (s *ForecastService) Forecast(ctx context.Context, uid string) (*models.Forecast, error) {
+	settings, err := s.settings.Get(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := loadTimezone(settings.Timezone)
+	if err != nil {
+		return nil, err
+	}
+	// Drafts and room bills would skew both the start of the open period and
+	// the history.
+	bills, err := s.bills.History(ctx, uid, forecastHistoryBills)
+	if err != nil {
+		return nil, err
+	}
+	if len(bills) == 0 {
+		// Without a bill there is no reading the open period starts from.
+		return nil, &middleware.AppError{HTTPStatus: 404, Key: "errors.forecast.no_bills"}
+	}
+	latest := bills[0]
+
+	now := time.Now().In(loc)
+	start, end, err := forecastPeriod(settings, latest, now, loc)
+	if err != nil {
+		return nil, err
+	}
+	// The next bill starts from the latest one's reading whatever the period,
+	// so usage counts from there: cycles skipped since are billed too.
+	from := latest.PeriodEnd.In(loc)
+
+	readings, err := s.readings.List(ctx, uid, models.MeterTypeElectricity, 50)
+	if err != nil {
+		return nil, err
+	}
+	var current *models.Reading
+	for _, r := range readings { // newest first
+		if r.TakenAt.Before(from) || r.TakenAt.After(now) || r.TakenAt.After(end) {
+			continue
+		}
+		if r.Value < latest.MeterReading {
+			// A reading below the bill's is a typo or a meter swap; either
+			// way it says nothing about this period.
+			continue
+		}
+		current = r
+		break
+	}
+
+	p := projectUsage(bills, from, end, now, latest.MeterReading, current)
+
+	currency := settings.Currency.OrDefault()
+	rate := nextBillRate(settings, latest)
+	_, cost, rent, total := billTotals(0, p.usage, rate, settings.DefaultRent, currency)
+	_, costLow, _, _ := billTotals(0, p.low, rate, 0, currency)
+	_, costHigh, _, _ := billTotals(0, p.high, rate, 0, currency)
+
+	f := &models.Forecast{
+		PeriodStart:     start,
+		PeriodEnd:       end,
+		AsOf:            now,
+		Basis:           p.basis,
+		DaysElapsed:     roundTenth(p.daysElapsed),
+		DaysTotal:       roundTenth(p.daysTotal),
+		StartReading:    latest.MeterReading,
+		UsageSoFar:      roundTenth(p.usageSoFar),
+		Usage:           roundTenth(p.usage),
+		UsageLow:        roundTenth(p.low),
+		UsageHigh:       roundTenth(p.high),
+		SeasonalFactor:  math.Round(p.seasonal*100) / 100,
+		ElectricityRate: rate,
+		Currency:        currency,
+		Cost:            cost,
+		CostLow:         costLow,
+		CostHigh:        costHigh,
+		Rent:            rent,
+		TotalAmount:     total,
+	}
+	if current != nil {
+		v := current.Value
+		f.LatestReading = &v
+	}
+	return f, nil
+}
+
+// ----------------------- helpers -----------------------
+
+// forecastPeriod is the [start, end) window of the next bill: the period
+// after latest, labelled and anchored like BillService.create does (see
+// nextPeriod and resolvePeriod), rolled forward a cycle at a time until it
+// has not ended by now.
+func forecastPeriod(settings *models.UserSettings, latest *models.Bill, now time.Time, loc *time.Location) (start, end time.Time, err error) {
+	label, err := nextPeriod(settings, latest, now)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	for {
+		start, end, err = resolvePeriod(&models.CreateBillRequest{Period: label}, settings, loc)
+		if err != nil || end.After(now) {
+			return start, end, err
+		}
+		label = end.Format("2006-01")
+	}
+}
+
+const (
+	// forecastBandZ is the z-score of the confidence band (~80% two-sided).
+	forecastBandZ = 1.28
+	// Bounds on the relative spread of daily usage. Fewer than three bills is
+	// not enough to measure it, so the default applies.
+	defaultUsageSpread = 0.25
+	minUsageSpread     = 0.1
+	// Bounds on the seasonal factor, so one odd bill (a month away, a new
+	// heater) cannot double or halve the forecast.
+	minSeasonalFactor = 0.5
+	maxSeasonalFactor = 2.0
+	// recentBills is how many bills "current consumption" averages over.
+	recentBills = 3
+)
+
+// usageProjection is projectUsage's result, before rounding and pricing.
+type usageProjection struct {
+	basis       models.ForecastBasis
+	daysElapsed float64
+	daysTotal   float64
+	usageSoFar  float64
+	usage       float64
+	low, high   float64
+	seasonal    float64
+}
+
+// projectUsage extrapolates the [start, end) period.
+//
+// The history rate is the average daily usage of the last recentBills bills,
+// scaled by a seasonal factor: in earlier years, how the bill for the month
+// this period starts in compared with the bills just before it. With a
+// current reading, the remaining days run at a blend of the observed rate and
+// the history rate, weighted by how much of the period has been observed.
+// The band is the spread of past daily usage applied to the part of the
+// period that is still extrapolated, so it narrows as readings come in.
+//
+// bills must be newest first, as BillService.List returns them.
+func projectUsage(bills []*models.Bill, start, end, now time.Time, startReading float64, current *models.Reading) usageProjection {
+	p := usageProjection{
+		basis:     models.ForecastBasisHistory,
+		daysTotal: days(end.Sub(start)),
+		seasonal:  seasonalFactor(bills, start),
+	}
+	p.daysElapsed = math.Min(math.Max(days(now.Sub(start)), 0), p.daysTotal)
+
+	historyDaily := meanDailyUsage(bills, recentBills) * p.seasonal
+	spread := usageSpread(bills)
+
+	remainingDaily, remainingDays := historyDaily, p.daysTotal
+	if current != nil {
+		if observedDays := days(current.TakenAt.Sub(start)); observedDays > 0 {
+			p.basis = models.ForecastBasisReadings
+			p.usageSoFar = current.Value - startReading
+			observedDaily := p.usageSoFar / observedDays
+			remainingDays = math.Max(p.daysTotal-observedDays, 0)
+			remainingDaily = observedDaily
+			if historyDaily > 0 {
+				w := math.Min(observedDays/p.daysTotal, 1)
+				remainingDaily = w*observedDaily + (1-w)*historyDaily
+			}
+		}
+	}
+
+	extrapolated := remainingDaily * remainingDays
+	band := forecastBandZ * spread * extrapolated
+	p.usage = p.usageSoFar + extrapolated
+	p.low = math.Max(p.usage-band, p.usageSoFar)
+	p.high = p.usage + band
+	return p
+}
+
+// seasonalFactor compares, in each earlier year, the bill starting in the
+// same month as start with the recentBills bills before it, and averages the
+// ratios. 1 when there is no such bill.
+func seasonalFactor(bills []*models.Bill, start time.Time) float64 {
+	var sum float64
+	var n int
+	for i, b := range bills {
+		if b.PeriodStart.Month() != start.Month() || b.PeriodStart.Year() >= start.Year() {
+			continue
+		}
+		base := meanDailyUsage(bills[i+1:], recentBills)
+		if base <= 0 {
+			continue
+		}
+		sum += dailyUsage(b) / base
+		n++
+	}
+	if n == 0 {
+		return 1
+	}
+	return math.Min(math.Max(sum/float64(n), minSeasonalFactor), maxSeasonalFactor)
+}
+
+// usageSpread is the coefficient of variation of daily usage across the
+// history, floored at minUsageSpread.
+func usageSpread(bills []*models.Bill) float64 {
+	rates := make([]float64, 0, len(bills))
+	for _, b := range bills {
+		if d := dailyUsage(b); d > 0 {
+			rates = append(rates, d)
+		}
+	}
+	if len(rates) < 3 {
+		return defaultUsageSpread
+	}
+	var mean float64
+	for _, r := range rates {
+		mean += r
+	}
+	mean /= float64(len(rates))
+	var variance float64
+	for _, r := range rates {
+		variance += (r - mean) * (r - mean)
+	}
+	cv := math.Sqrt(variance/float64(len(rates)-1)) / mean
+	return math.Max(cv, minUsageSpread)
+}
+
+// meanDailyUsage averages dailyUsage over the first n bills that have one.
+func meanDailyUsage(bills []*models.Bill, n int) float64 {
+	var sum float64
+	var used int
+	for _, b := range bills {
+		if used == n {
+			break
+		}
+		if d := dailyUsage(b); d > 0 {
+			sum += d
+			used++
+		}
+	}
+	if used == 0 {
+		return 0
+	}
+	return sum / float64(used)
+}
+
+// dailyUsage is a bill's kWh per day; 0 when its window is empty.
+func dailyUsage(b *models.Bill) float64 {
+	d := days(b.PeriodEnd.Sub(b.PeriodStart))
+	if d <= 0 {
+		return 0
+	}
+	return b.ElectricityUsage / d
+}
+
+func days(d time.Duration) float64 {
+	return d.Hours() / 24
+}
+
+func roundTenth(f float64) float64 {
+	return math.Round(f*10) / 10
+}
@@ -0,0 +1,192 @@
+package services
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"wattrent/internal/models"
+)
+
+// monthlyBill is a calendar-month bill in Taipei with the given usage.
+func monthlyBill(year int, month time.Month, usage float64) *models.Bill {
+	start := time.Date(year, month, 1, 0, 0, 0, 0, taipei)
+	return &models.Bill{PeriodStart: start, PeriodEnd: start.AddDate(0, 1, 0), ElectricityUsage: usage}
+}
+
+// newestFirst builds a history of consecutive monthly bills ending with the
+// month before (year, month), returned in BillService.List order.
+func newestFirst(year int, month time.Month, usages ...float64) []*models.Bill {
+	start := time.Date(year, month, 1, 0, 0, 0, 0, taipei).AddDate(0, -len(usages), 0)
+	bills := make([]*models.Bill, len(usages))
+	for i, u := range usages {
+		m := start.AddDate(0, i, 0)
+		bills[len(usages)-1-i] = monthlyBill(m.Year(), m.Month(), u)
+	}
+	return bills
+}
+
+func approx(a, b float64) bool { return math.Abs(a-b) < 0.01 }
+
+func TestSeasonalFactor(t *testing.T) {
+	t.Parallel()
+
+	july := time.Date(2026, time.July, 1, 0, 0, 0, 0, taipei)
+
+	// Last year April..June averaged 3 kWh/day and July ran at 6: summer
+	// doubles consumption.
+	history := newestFirst(2026, time.July,
+		// Apr 2025 .. Jun 2025 (30, 31, 30 days)
+		90, 93, 90,
+		// Jul 2025 (31 days)
+		186,
+		// Aug 2025 .. Jun 2026, flat
+		100, 100, 100, 100, 100, 100, 100, 100, 100, 100, 100,
+	)
+	if got := seasonalFactor(history, july); !approx(got, 2) {
+		t.Errorf("seasonalFactor = %v, want 2", got)
+	}
+
+	// No bill from an earlier July: no adjustment.
+	if got := seasonalFactor(history[:5], july); got != 1 {
+		t.Errorf("seasonalFactor without last year = %v, want 1", got)
+	}
+
+	// An extreme ratio is capped.
+	spike := newestFirst(2026, time.July,
+		90, 93, 90, 31*30,
+		100, 100, 100, 100, 100, 100, 100, 100, 100, 100, 100,
+	)
+	if got := seasonalFactor(spike, july); got != maxSeasonalFactor {
+		t.Errorf("seasonalFactor spike = %v, want %v", got, maxSeasonalFactor)
+	}
+}
+
+func TestProjectUsage(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2026, time.May, 1, 0, 0, 0, 0, taipei)
+	end := start.AddDate(0, 1, 0) // 31 days
+	// Feb..Apr flat at 5 kWh/day, no earlier May to compare with.
+	history := newestFirst(2026, time.May, 140, 155, 150)
+
+	t.Run("history only", func(t *testing.T) {
+		t.Parallel()
+		now := start.AddDate(0, 0, 10)
+		p := projectUsage(history, start, end, now, 1000, nil)
+		if p.basis != models.ForecastBasisHistory {
+			t.Errorf("basis = %q", p.basis)
+		}
+		if !approx(p.usage, 5*31) {
+			t.Errorf("usage = %v, want %v", p.usage, 5*31.0)
+		}
+		if !approx(p.daysElapsed, 10) || !approx(p.daysTotal, 31) {
+			t.Errorf("days = %v / %v", p.daysElapsed, p.daysTotal)
+		}
+		// Flat history has no spread of its own, so the floor applies.
+		band := forecastBandZ * minUsageSpread * p.usage
+		if !approx(p.low, p.usage-band) || !approx(p.high, p.usage+band) {
+			t.Errorf("band = [%v, %v], want ±%v around %v", p.low, p.high, band, p.usage)
+		}
+	})
+
+	t.Run("reading blends with history", func(t *testing.T) {
+		t.Parallel()
+		// After 15.5 days (half the period) the tenant has used 155 kWh,
+		// 10 kWh/day: twice the history rate.
+		taken := start.Add(time.Duration(15.5 * 24 * float64(time.Hour)))
+		r := &models.Reading{Value: 1155, TakenAt: taken}
+		p := projectUsage(history, start, end, taken.Add(time.Hour), 1000, r)
+		if p.basis != models.ForecastBasisReadings {
+			t.Errorf("basis = %q", p.basis)
+		}
+		if !approx(p.usageSoFar, 155) {
+			t.Errorf("usageSoFar = %v", p.usageSoFar)
+		}
+		// Remaining 15.5 days at (10+5)/2 = 7.5 kWh/day.
+		if want := 155 + 7.5*15.5; !approx(p.usage, want) {
+			t.Errorf("usage = %v, want %v", p.usage, want)
+		}
+		if p.low < p.usageSoFar || p.high <= p.usage {
+			t.Errorf("band = [%v, %v] around %v", p.low, p.high, p.usage)
+		}
+	})
+
+	t.Run("reading without history", func(t *testing.T) {
+		t.Parallel()
+		taken := start.AddDate(0, 0, 10)
+		r := &models.Reading{Value: 1080, TakenAt: taken}
+		p := projectUsage(nil, start, end, taken, 1000, r)
+		if want := 8.0 * 31; !approx(p.usage, want) {
+			t.Errorf("usage = %v, want %v", p.usage, want)
+		}
+	})
+
+	t.Run("band narrows as the period fills", func(t *testing.T) {
+		t.Parallel()
+		early := &models.Reading{Value: 1025, TakenAt: start.AddDate(0, 0, 5)}
+		late := &models.Reading{Value: 1125, TakenAt: start.AddDate(0, 0, 25)}
+		pe := projectUsage(history, start, end, early.TakenAt, 1000, early)
+		pl := projectUsage(history, start, end, late.TakenAt, 1000, late)
+		if pl.high-pl.low >= pe.high-pe.low {
+			t.Errorf("late band %v not narrower than early band %v", pl.high-pl.low, pe.high-pe.low)
+		}
+	})
+}
+
+func TestForecastPeriod(t *testing.T) {
+	t.Parallel()
+
+	date := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 0, 0, 0, 0, taipei) }
+	// April's bill, in a user's zone stored as UTC.
+	latest := &models.Bill{PeriodEnd: date(2026, time.May, 1).UTC()}
+	tests := []struct {
+		name      string
+		settings  models.UserSettings
+		now       time.Time
+		wantStart time.Time
+		wantEnd   time.Time
+	}{
+		{name: "the period after the latest bill", now: date(2026, time.May, 20),
+			wantStart: date(2026, time.May, 1), wantEnd: date(2026, time.June, 1)},
+		{name: "rolled forward to now", now: date(2026, time.August, 3),
+			wantStart: date(2026, time.August, 1), wantEnd: date(2026, time.September, 1)},
+		{name: "anchor day", settings: models.UserSettings{BillingAnchorDay: 15}, now: date(2026, time.May, 20),
+			wantStart: date(2026, time.May, 15), wantEnd: date(2026, time.June, 15)},
+		{name: "bimonthly rolled forward", settings: models.UserSettings{BillingCycle: models.BillingCycleBimonthly}, now: date(2026, time.September, 10),
+			wantStart: date(2026, time.September, 1), wantEnd: date(2026, time.November, 1)},
+		{name: "next bill not due yet", now: date(2026, time.April, 28),
+			wantStart: date(2026, time.May, 1), wantEnd: date(2026, time.June, 1)},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			settings := tc.settings
+			settings.Timezone = "Asia/Taipei"
+			start, end, err := forecastPeriod(&settings, latest, tc.now, taipei)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !start.Equal(tc.wantStart) || !end.Equal(tc.wantEnd) {
+				t.Errorf("period = %v..%v, want %v..%v", start, end, tc.wantStart, tc.wantEnd)
+			}
+		})
+	}
+}
+
+func TestNextBillRate(t *testing.T) {
+	t.Parallel()
+
+	latest := &models.Bill{ElectricityRate: 5.2}
+	if got := nextBillRate(&models.UserSettings{DefaultElectricityRate: 4.5}, latest); got != 4.5 {
+		t.Errorf("with a default rate: %v, want 4.5", got)
+	}
+	if got := nextBillRate(&models.UserSettings{}, latest); got != 5.2 {
+		t.Errorf("without one: %v, want the latest bill's 5.2", got)
+	}
+	if got := nextBillRate(&models.UserSettings{}, nil); got != 0 {
+		t.Errorf("without either: %v, want 0", got)
+	}
+}
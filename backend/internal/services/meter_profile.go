@@ -0,0 +1,196 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"wattrent/internal/middleware"
+	"wattrent/internal/models"
+)
+
+// MeterProfiles is what OCRService needs of the meter profiles: the one to
+// fold into the prompt, and a way to record what the first read learned.
+type MeterProfiles interface {
+	// Get returns the profile of the user's meter of type t, or nil when
+	// there is none.
+	Get(ctx context.Context, uid string, t models.MeterType) (*models.MeterProfile, error)
+	// Learn stores p unless the meter already has a profile.
+	Learn(ctx context.Context, uid string, p *models.MeterProfile) error
+}
+
+// MeterProfileService operates on /users/{uid}/meters/{meterType}: one
+// profile per meter type, learned by OCR and editable by the user.
+type MeterProfileService struct {
+	fs *firestore.Client
+}
+
+func NewMeterProfileService(fs *firestore.Client) *MeterProfileService {
+	return &MeterProfileService{fs: fs}
+}
+
+// Register digit counts a profile may have; real meters have 4 to 8.
+const (
+	minMeterDigits = 3
+	maxMeterDigits = 10
+)
+
+// maxMeterLabelLen caps the free-text model and serial fields.
+const maxMeterLabelLen = 64
+
+func (s *MeterProfileService) ref(uid string, t models.MeterType) *firestore.DocumentRef {
+	return s.fs.Collection("users").Doc(uid).Collection("meters").Doc(string(t))
+}
+
+func (s *MeterProfileService) Get(ctx context.Context, uid string, t models.MeterType) (*models.MeterProfile, error) {
+	snap, err := s.ref(uid, t).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var p models.MeterProfile
+	if err := snap.DataTo(&p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// List returns every profile of the user, in meter type order.
+func (s *MeterProfileService) List(ctx context.Context, uid string) ([]*models.MeterProfile, error) {
+	iter := s.fs.Collection("users").Doc(uid).Collection("meters").Documents(ctx)
+	defer iter.Stop()
+	out := []*models.MeterProfile{}
+	for {
+		snap, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var p models.MeterProfile
+		if err := snap.DataTo(&p); err != nil {
+			return nil, err
+		}
+		out = append(out, &p)
+	}
+	return out, nil
+}
+
+// Save replaces the profile of the user's meter of type t with the user's
+// own, which OCR will not overwrite.
+func (s *MeterProfileService) Save(ctx context.Context, uid string, t models.MeterType, req *models.SaveMeterProfileRequest) (*models.MeterProfile, error) {
+	if uid == "" {
+		return nil, middleware.ErrUnauthorized
+	}
+	if _, _, ok := lookupMeterPrompt(t); !ok || t == "" {
+		return nil, &middleware.AppError{HTTPStatus: 400, Key: "errors.meter.invalid_meter_type"}
+	}
+	p := &models.MeterProfile{
+		MeterType:   t,
+		DigitCount:  req.DigitCount,
+		Display:     req.Display,
+		DecimalDrum: req.DecimalDrum,
+		Model:       strings.TrimSpace(req.Model),
+		Serial:      strings.TrimSpace(req.Serial),
+		Source:      models.MeterProfileSourceUser,
+		UpdatedAt:   time.Now().UTC(),
+	}
+	if err := validateMeterProfile(p); err != nil {
+		return nil, err
+	}
+	if _, err := s.ref(uid, t).Set(ctx, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (s *MeterProfileService) Learn(ctx context.Context, uid string, p *models.MeterProfile) error {
+	if err := validateMeterProfile(p); err != nil {
+		return err
+	}
+	p.Source = models.MeterProfileSourceOCR
+	p.UpdatedAt = time.Now().UTC()
+	// Create fails when the user (or a concurrent read) got there first.
+	_, err := s.ref(uid, p.MeterType).Create(ctx, p)
+	if status.Code(err) == codes.AlreadyExists {
+		return nil
+	}
+	return err
+}
+
+// Delete forgets the profile; the next confident read learns it again.
+func (s *MeterProfileService) Delete(ctx context.Context, uid string, t models.MeterType) error {
+	_, err := s.ref(uid, t).Delete(ctx)
+	if err != nil && status.Code(err) != codes.NotFound {
+		return err
+	}
+	return nil
+}
+
+// validateMeterProfile checks the fields the user can set. Zero values
+// (unknown) always pass.
+func validateMeterProfile(p *models.MeterProfile) error {
+	if p.DigitCount != 0 && (p.DigitCount < minMeterDigits || p.DigitCount > maxMeterDigits) {
+		return &middleware.AppError{HTTPStatus: 400, Key: "errors.meter.invalid_digit_count"}
+	}
+	switch p.Display {
+	case "", models.MeterDisplayMechanical, models.MeterDisplayLCD:
+	default:
+		return &middleware.AppError{HTTPStatus: 400, Key: "errors.meter.invalid_display"}
+	}
+	if utf8.RuneCountInString(p.Model) > maxMeterLabelLen || utf8.RuneCountInString(p.Serial) > maxMeterLabelLen {
+		return &middleware.AppError{HTTPStatus: 400, Key: "errors.meter.label_too_long"}
+	}
+	return nil
+}
+
+// meterProfile returns the profile of the user's meter of type t, and
+// whether a read may learn one (there is none, and a place to keep it). A
+// failing lookup is logged and reads the meter as unknown.
+func (s *OCRService) meterProfile(ctx context.Context, uid string, t models.MeterType) (*models.MeterProfile, bool) {
+	if s.profiles == nil || uid == "" {
+		return nil, false
+	}
+	p, err := s.profiles.Get(ctx, uid, t)
+	if err != nil {
+		slog.Warn("ocr: meter profile get failed", "uid", uid, "err", err)
+		return nil, false
+	}
+	return p, p == nil
+}
+
+// learnProfile creates the profile of the user's meter of type t from a
+// read: the digit count it spelled out, the serial number and the model's
+// description of the meter. Only a confident read that spelled its digits teaches anything.
+func (s *OCRService) learnProfile(ctx context.Context, uid string, t models.MeterType, out *ocrModelOutput) {
+	digits := spelledDigits(out)
+	if out.Confidence < draftConfidenceThreshold || len(digits) < minMeterDigits || len(digits) > maxMeterDigits {
+		return
+	}
+	p := &models.MeterProfile{MeterType: t, DigitCount: len(digits)}
+	if serial := strings.TrimSpace(out.Serial); utf8.RuneCountInString(serial) <= maxMeterLabelLen {
+		p.Serial = serial
+	}
+	if m := out.Meter; m != nil {
+		p.DecimalDrum = m.DecimalDrum
+		if m.Display == models.MeterDisplayMechanical || m.Display == models.MeterDisplayLCD {
+			p.Display = m.Display
+		}
+		if model := strings.TrimSpace(m.Model); utf8.RuneCountInString(model) <= maxMeterLabelLen {
+			p.Model = model
+		}
+	}
+	if err := s.profiles.Learn(ctx, uid, p); err != nil {
+		slog.Warn("ocr: meter profile learn failed", "uid", uid, "err", err)
+	}
+}
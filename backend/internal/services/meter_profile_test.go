@@ -0,0 +1,260 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"wattrent/internal/middleware"
+	"wattrent/internal/models"
+)
+
+// memoryMeterProfiles is an in-memory MeterProfiles.
+type memoryMeterProfiles struct {
+	mu       sync.Mutex
+	profiles map[string]*models.MeterProfile
+}
+
+func (m *memoryMeterProfiles) Get(_ context.Context, uid string, t models.MeterType) (*models.MeterProfile, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.profiles[uid+"/"+string(t)], nil
+}
+
+func (m *memoryMeterProfiles) Learn(_ context.Context, uid string, p *models.MeterProfile) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.profiles == nil {
+		m.profiles = make(map[string]*models.MeterProfile)
+	}
+	if _, ok := m.profiles[uid+"/"+string(p.MeterType)]; !ok {
+		p.Source = models.MeterProfileSourceOCR
+		m.profiles[uid+"/"+string(p.MeterType)] = p
+	}
+	return nil
+}
+
+func TestValidateMeterProfile(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		profile models.MeterProfile
+		wantKey string
+	}{
+		{name: "all unknown", profile: models.MeterProfile{}},
+		{name: "full", profile: models.MeterProfile{DigitCount: 5, Display: models.MeterDisplayMechanical, DecimalDrum: true, Model: "KEC-91E", Serial: "3146159"}},
+		{name: "too few digits", profile: models.MeterProfile{DigitCount: 2}, wantKey: "errors.meter.invalid_digit_count"},
+		{name: "too many digits", profile: models.MeterProfile{DigitCount: 11}, wantKey: "errors.meter.invalid_digit_count"},
+		{name: "unknown display", profile: models.MeterProfile{Display: "dial"}, wantKey: "errors.meter.invalid_display"},
+		{name: "long serial", profile: models.MeterProfile{Serial: strings.Repeat("9", 65)}, wantKey: "errors.meter.label_too_long"},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			err := validateMeterProfile(&tc.profile)
+			if tc.wantKey == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			var appErr *middleware.AppError
+			if !errors.As(err, &appErr) || appErr.Key != tc.wantKey {
+				t.Errorf("err = %v, want %s", err, tc.wantKey)
+			}
+		})
+	}
+}
+
+func TestMeterPrompt_WithProfile(t *testing.T) {
+	t.Parallel()
+
+	_, elec, _ := lookupMeterPrompt(models.MeterTypeElectricity)
+	if got := buildOCRPrompt(elec.withProfile(nil, false), 0); got != elec.base+ocrPromptDigits {
+		t.Errorf("no profile store: prompt changed")
+	}
+	learn := elec.withProfile(nil, true)
+	if !learn.learn || !strings.HasSuffix(buildOCRPrompt(learn, 0), ocrPromptDescribeMeter+ocrPromptSerial) {
+		t.Errorf("unknown meter: want the prompt to ask for a description and the serial")
+	}
+
+	known := elec.withProfile(&models.MeterProfile{DigitCount: 5, Display: models.MeterDisplayMechanical, DecimalDrum: true, Model: "KEC-91E"}, false)
+	got := buildOCRPrompt(known, 36034)
+	for _, want := range []string{"digit wheels", "exactly 5 digits", "fraction digit", `"KEC-91E"`, "previous reading was 36034"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("prompt lacks %q:\n%s", want, got)
+		}
+	}
+	if known.learn || known.digitCount != 5 {
+		t.Errorf("known meter = %+v, want digit count 5 and nothing to learn", known)
+	}
+	if empty := elec.withProfile(&models.MeterProfile{}, false); empty.profile != "" {
+		t.Errorf("empty profile adds %q to the prompt", empty.profile)
+	}
+
+	serial := elec.withProfile(&models.MeterProfile{Serial: "5208811"}, false)
+	if !strings.HasSuffix(serial.profile, ocrPromptSerial) || strings.Contains(serial.profile, "5208811") {
+		t.Errorf("profile with a serial: prompt %q, want it to ask for the serial without giving it away", serial.profile)
+	}
+}
+
+func TestSerialMatches(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		registered, read string
+		want             bool
+	}{
+		{"3146159", "3146159", true},
+		{"3146159", "No. 3146159", true},
+		{"AB-1234 567", "ab1234567", true},
+		{"3146159", "3146158", false},
+		{"3146159", "", true},
+		{"", "3146158", true},
+	}
+	for _, tc := range tests {
+		if got := serialMatches(tc.registered, tc.read); got != tc.want {
+			t.Errorf("serialMatches(%q, %q) = %v, want %v", tc.registered, tc.read, got, tc.want)
+		}
+	}
+}
+
+func TestDigitCountOK(t *testing.T) {
+	t.Parallel()
+
+	five := meterPrompt{digitCount: 5}
+	tests := []struct {
+		name   string
+		prompt meterPrompt
+		out    ocrModelOutput
+		want   bool
+	}{
+		{"no profile", meterPrompt{}, ocrModelOutput{Reading: 1234567, Notes: "1 2 3 4 5 6 7"}, true},
+		{"notes match", five, ocrModelOutput{Reading: 3604, Notes: "0 3 6 0 4"}, true},
+		{"notes one short", five, ocrModelOutput{Reading: 3604, Notes: "3 6 0 4"}, false},
+		{"tenths read as a digit", five, ocrModelOutput{Reading: 360347, Notes: "3 6 0 3 4 7"}, false},
+		{"no notes, fits", five, ocrModelOutput{Reading: 3604}, true},
+		{"no notes, too long", five, ocrModelOutput{Reading: 360347}, false},
+		{"unreadable", five, ocrModelOutput{}, true},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			if got := digitCountOK(tc.prompt, &tc.out); got != tc.want {
+				t.Errorf("digitCountOK = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestOCRService_MeterProfile(t *testing.T) {
+	t.Parallel()
+
+	req := func() *models.OCRRequest { return &models.OCRRequest{ImageBase64: testPhotoBase64} }
+
+	t.Run("first confident read learns the profile", func(t *testing.T) {
+		t.Parallel()
+		profiles := &memoryMeterProfiles{}
+		p := &stubOCRProvider{name: "gemini/flash-lite",
+			raw: `{"reading":3604,"confidence":0.95,"notes":"0 3 6 0 4","serial":"3146159","meter":{"display":"mechanical","decimal_drum":true,"model":" KEC-91E "}}`}
+		svc := NewOCRService(nil, nil, []OCRProvider{p}, OCROptions{Profiles: profiles})
+		if _, err := svc.Process(t.Context(), "u", req()); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(p.prompt, `"meter"`) {
+			t.Error("prompt did not ask for the meter description")
+		}
+		got, _ := profiles.Get(t.Context(), "u", models.MeterTypeElectricity)
+		want := models.MeterProfile{MeterType: models.MeterTypeElectricity, DigitCount: 5, Display: models.MeterDisplayMechanical,
+			DecimalDrum: true, Model: "KEC-91E", Serial: "3146159", Source: models.MeterProfileSourceOCR}
+		if got == nil || *got != want {
+			t.Errorf("profile = %+v, want %+v", got, want)
+		}
+	})
+
+	t.Run("unsure read learns nothing", func(t *testing.T) {
+		t.Parallel()
+		profiles := &memoryMeterProfiles{}
+		p := &stubOCRProvider{name: "gemini/flash-lite", raw: `{"reading":3604,"confidence":0.5,"notes":"0 3 6 0 4"}`}
+		svc := NewOCRService(nil, nil, []OCRProvider{p}, OCROptions{Profiles: profiles})
+		if _, err := svc.Process(t.Context(), "u", req()); err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := profiles.Get(t.Context(), "u", models.MeterTypeElectricity); got != nil {
+			t.Errorf("profile = %+v, want none", got)
+		}
+	})
+
+	known := func() *memoryMeterProfiles {
+		return &memoryMeterProfiles{profiles: map[string]*models.MeterProfile{
+			"u/electricity": {MeterType: models.MeterTypeElectricity, DigitCount: 5, Source: models.MeterProfileSourceUser},
+		}}
+	}
+
+	t.Run("known meter is described in the prompt", func(t *testing.T) {
+		t.Parallel()
+		p := &stubOCRProvider{name: "gemini/flash-lite", raw: `{"reading":3604,"confidence":0.95,"notes":"0 3 6 0 4"}`}
+		svc := NewOCRService(nil, nil, []OCRProvider{p}, OCROptions{Profiles: known()})
+		if _, err := svc.Process(t.Context(), "u", req()); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(p.prompt, "exactly 5 digits") || strings.Contains(p.prompt, `"meter"`) {
+			t.Errorf("prompt = %q, want the profile and no description request", p.prompt)
+		}
+	})
+
+	t.Run("wrong digit count escalates", func(t *testing.T) {
+		t.Parallel()
+		first := &stubOCRProvider{name: "gemini/flash-lite", raw: `{"reading":36047,"confidence":0.95,"notes":"0 3 6 0 4 7"}`}
+		stronger := &stubOCRProvider{name: "gemini/flash", raw: `{"reading":3604,"confidence":0.9,"notes":"0 3 6 0 4"}`}
+		svc := NewOCRService(nil, nil, []OCRProvider{first}, OCROptions{Profiles: known(), Escalation: []OCRProvider{stronger}})
+		resp, err := svc.Process(t.Context(), "u", req())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Reading != 3604 || !resp.Escalated {
+			t.Errorf("reading = %v (escalated %v), want the stronger model's 3604", resp.Reading, resp.Escalated)
+		}
+	})
+
+	t.Run("another meter's serial is flagged", func(t *testing.T) {
+		t.Parallel()
+		profiles := &memoryMeterProfiles{profiles: map[string]*models.MeterProfile{
+			"u/electricity": {MeterType: models.MeterTypeElectricity, Serial: "3146159", Source: models.MeterProfileSourceUser},
+		}}
+		p := &stubOCRProvider{name: "gemini/flash-lite", raw: `{"reading":3604,"confidence":0.95,"serial":"No. 3146160"}`}
+		svc := NewOCRService(nil, nil, []OCRProvider{p}, OCROptions{Profiles: profiles})
+		resp, err := svc.Process(t.Context(), "u", req())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(p.prompt, `"serial"`) {
+			t.Error("prompt did not ask for the serial")
+		}
+		if resp.Serial != "No. 3146160" || !slices.Equal(resp.Warnings, []models.OCRWarning{models.OCRWarningWrongMeter}) {
+			t.Errorf("serial %q, warnings %v, want the wrong_meter warning", resp.Serial, resp.Warnings)
+		}
+
+		p.raw = `{"reading":3604,"confidence":0.95,"serial":"3146159"}`
+		if resp, _ := svc.Process(t.Context(), "u", req()); len(resp.Warnings) != 0 {
+			t.Errorf("warnings = %v for the registered serial", resp.Warnings)
+		}
+	})
+
+	t.Run("wrong digit count is rejected", func(t *testing.T) {
+		t.Parallel()
+		p := &stubOCRProvider{name: "gemini/flash-lite", raw: `{"reading":36047,"confidence":0.95,"notes":"0 3 6 0 4 7"}`}
+		svc := NewOCRService(nil, nil, []OCRProvider{p}, OCROptions{Profiles: known()})
+		_, err := svc.Process(t.Context(), "u", req())
+		var appErr *middleware.AppError
+		if !errors.As(err, &appErr) || appErr.HTTPStatus != 422 || appErr.Key != "errors.ocr.wrong_digit_count" {
+			t.Errorf("err = %v, want 422 errors.ocr.wrong_digit_count", err)
+		}
+	})
+}
@@ -4,17 +4,24 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
+	"math"
 	"strconv"
 	"strings"
+	"time"
 
+	"cloud.google.com/go/firestore"
 	"google.golang.org/genai"
 
 	"wattrent/internal/middleware"
 	"wattrent/internal/models"
 )
 
-// OCRService reads electricity-meter readings via Gemini (AI Studio or Vertex AI).
+// OCRService reads electricity, water and gas meter readings through a chain
+// of OCRProviders (Gemini via AI Studio or Vertex AI by default). Each meter
+// type has its own prompt; see meterPrompts.
 //
 // Why Gemini Flash-Lite over a traditional OCR service:
 //   - The "rotor sitting between two digits" case on mechanical meters is
@@ -24,17 +31,108 @@ import (
 //   - The AI Studio free tier offers ~1500 requests/day on Flash-Lite, which
 //     is more than enough for a personal project.
 //
-// Image source handling: regardless of whether the backend is Gemini API or
-// Vertex, we always send the image as inline bytes. (1) The Gemini Developer
-// API cannot read gs:// directly. (2) It keeps both backend code paths uniform.
+// Image source handling: whatever the provider, we always send the image as
+// inline bytes. (1) The Gemini Developer API cannot read gs:// directly. (2) It
+// keeps every provider's code path uniform.
+//
+// Every call is recorded as an OCRAttempt under /users/{uid}/ocrAttempts.
 type OCRService struct {
-	client  *genai.Client
-	storage *StorageService
-	model   string
+	fs         *firestore.Client
+	storage    *StorageService
+	providers  []OCRProvider
+	escalation []OCRProvider
+	cacheStore OCRCache
+	cacheTTL   time.Duration
+	pipeline   imagePipeline
+	heic       HEICConverter
+	quality    qualityGate
+	profiles   MeterProfiles
+	// corrections feeds up to fewShot example photos into each read.
+	corrections OCRCorrections
+	fewShot     int
+	// prompts swaps the built-in prompt for an experiment's variant.
+	prompts    OCRPrompts
+	reportUIDs []string
+	// rooms are what bank reads map their meters to.
+	rooms Rooms
+}
+
+// OCROptions holds the optional parts of an OCRService.
+type OCROptions struct {
+	// Escalation is the provider chain with the stronger model; a reading
+	// the first chain is unsure about is retried with it. Empty disables
+	// escalation.
+	Escalation []OCRProvider
+	// Cache stores answers by image content for CacheTTL, so a re-submitted
+	// photo costs no quota. Nil, or a zero CacheTTL, disables caching.
+	Cache    OCRCache
+	CacheTTL time.Duration
+	// MaxImageDimension is the longest side a photo is scaled down to before
+	// OCR; 0 means defaultOCRMaxDimension. NormalizeContrast also stretches
+	// its contrast.
+	MaxImageDimension int
+	NormalizeContrast bool
+	// HEIC converts iPhone HEIC photos to JPEG, so they get the same
+	// preprocessing as the rest. Nil refuses them with
+	// errors.ocr.heic_not_supported.
+	HEIC HEICConverter
+	// MinSharpness, MaxClipped and MinBrightness reject a photo before any
+	// model call when its scores (models.OCRPhotoQuality) fall outside them,
+	// with an errors.ocr.photo_quality_* key. 0 turns a check off.
+	MinSharpness  float64
+	MaxClipped    float64
+	MinBrightness float64
+	// Profiles holds the users' meter profiles: a meter's profile is folded
+	// into the prompt and checked against the answer, and a meter without
+	// one gets one from its first confident read. Nil reads every meter
+	// with the generic prompt.
+	Profiles MeterProfiles
+	// Corrections holds the readings users corrected; the latest
+	// FewShotExamples (at most maxOCRExamples) of a meter are sent with each
+	// read of it as labelled example photos. Nil or 0 sends none.
+	Corrections     OCRCorrections
+	FewShotExamples int
+	// Prompts assigns users to the prompt variants of an experiment (see
+	// OCRExperiment). Nil reads every meter with the built-in prompts.
+	Prompts OCRPrompts
+	// Rooms holds the users' rooms, which bank reads map their meters to.
+	// Nil maps none.
+	Rooms Rooms
+	// ReportUIDs are the users who may see the cross-user variant report
+	// (OCRService.Report). Empty lets no one.
+	ReportUIDs []string
+}
+
+// NewOCRService takes the providers in fallback order: a provider is only
+// asked when every one before it failed.
+func NewOCRService(fs *firestore.Client, storage *StorageService, providers []OCRProvider, opts ...OCROptions) *OCRService {
+	s := &OCRService{fs: fs, storage: storage, providers: providers}
+	s.pipeline.maxDimension = defaultOCRMaxDimension
+	if len(opts) > 0 {
+		if opts[0].MaxImageDimension > 0 {
+			s.pipeline.maxDimension = opts[0].MaxImageDimension
+		}
+		s.pipeline.normalizeContrast = opts[0].NormalizeContrast
+		s.heic = opts[0].HEIC
+		s.quality = qualityGate{
+			minSharpness:  opts[0].MinSharpness,
+			maxClipped:    opts[0].MaxClipped,
+			minBrightness: opts[0].MinBrightness,
+		}
+		s.escalation = opts[0].Escalation
+		s.profiles = opts[0].Profiles
+		s.corrections, s.fewShot = opts[0].Corrections, min(opts[0].FewShotExamples, maxOCRExamples)
+		s.prompts, s.reportUIDs = opts[0].Prompts, opts[0].ReportUIDs
+		s.rooms = opts[0].Rooms
+		if opts[0].CacheTTL > 0 {
+			s.cacheStore, s.cacheTTL = opts[0].Cache, opts[0].CacheTTL
+		}
+	}
+	return s
 }
 
-func NewOCRService(client *genai.Client, storage *StorageService, model string) *OCRService {
-	return &OCRService{client: client, storage: storage, model: model}
+func ocrAttemptsCol(fs *firestore.Client, uid string) *firestore.CollectionRef {
+	return fs.Collection("users").Doc(uid).Collection("ocrAttempts")
 }
 
 const ocrPromptBase = `You are an expert at reading electricity meters (including Taiwan Taipower mechanical and digital meters). Return the current cumulative energy reading in whole kWh from the meter's MAIN register.
@@ -54,18 +152,151 @@ Confidence:
 
 Put the individual digits you read in "notes" (e.g. "3 6 0 3 4"). Return JSON only: no markdown, no explanation.`
 
+const ocrPromptWater = `You are an expert at reading water meters (including Taiwan Water Corporation dial-and-counter meters). Return the current cumulative volume in whole cubic metres (m3) from the meter's MAIN counter.
+
+WHICH number to read:
+- Read ONLY the row of black digit wheels (or the main LCD number) marked m3.
+- IGNORE the red digit wheels and the small red pointer dials: they show fractions of a cubic metre (x0.1, x0.01, x0.001, x0.0001).
+- IGNORE every other number printed on the meter: the serial number, the nominal size (e.g. "13mm", "DN15"), pressure or flow ratings (e.g. "Q3 2.5", "R160"), the manufacture year and any certification text.
+
+How to read the digits:
+- Read left to right and keep all leading digits shown, including leading zeros.
+- If a wheel is mid-rotation (a digit sitting between two numbers), use the SMALLER (lower) digit.
+
+Confidence:
+- Return confidence between 0 and 1. Lower it when there is condensation or dirt on the glass, glare, blur, an odd angle, or any digit you are unsure about.
+- If no counter is readable, return reading 0 and confidence 0.
+
+Put the individual digits you read in "notes" (e.g. "0 0 8 4 2"). Return JSON only: no markdown, no explanation.`
+
+const ocrPromptGas = `You are an expert at reading gas meters (including Taiwan city-gas diaphragm meters). Return the current cumulative volume in whole cubic metres (m3) from the meter's MAIN counter.
+
+WHICH number to read:
+- Read ONLY the black digit wheels of the main counter (marked m3).
+- IGNORE the digits on a red background or after the decimal mark: they are fractions of a cubic metre. Ignore the small test dial as well.
+- IGNORE every other number printed on the meter: the serial number, the model or type code (e.g. "G1.6", "G2.5"), Qmax / Qmin / pressure ratings, the manufacture year and any certification text.
+
+How to read the digits:
+- Read left to right and keep all leading digits shown, including leading zeros.
+- If a wheel is mid-rotation (a digit sitting between two numbers), use the SMALLER (lower) digit.
+
+Confidence:
+- Return confidence between 0 and 1. Lower it when there is glare, blur, an odd angle, or any digit you are unsure about.
+- If no counter is readable, return reading 0 and confidence 0.
+
+Put the individual digits you read in "notes" (e.g. "0 1 2 7 5"). Return JSON only: no markdown, no explanation.`
+
+// ocrPromptDigits asks every meter type for the register digit by digit,
+// with boxes in the 0-1000 [ymin, xmin, ymax, xmax] form Gemini is trained
+// on (see modelBox).
+const ocrPromptDigits = `
+
+Also return "digits": one entry per digit of the main register, left to right, exactly the digits of "reading" including leading zeros. Give each its "value" (0-9), its own "confidence" (0 to 1; lower it for a wheel between two numbers or a digit under glare) and "box_2d", the digit's bounding box as [ymin, xmin, ymax, xmax] scaled to 0-1000. Return the bounding box of the whole register the same way in "register_box".`
+
+// ocrPromptDescribeMeter asks for what a MeterProfile needs besides the
+// digit count, on reads of a meter without a profile.
+const ocrPromptDescribeMeter = `
+
+Also describe the meter in "meter": "display" is "mechanical" for digit wheels or "lcd" for a digital display; "decimal_drum" is true when the main register is followed by a red or otherwise marked fraction digit; "model" is the model or type code printed on the meter (e.g. "KEC-91E"), or "" when there is none.`
+
+// ocrPromptSerial asks for the serial number the base prompts tell the model
+// to ignore, to tell the user's meter from the neighbour's (see
+// serialMatches). The expected serial is never in the prompt: the model
+// would copy it.
+const ocrPromptSerial = `
+
+Separately, copy the meter's serial number (usually printed as "No." followed by digits, e.g. "No. 3146159") into "serial", exactly as printed but without the "No." label, or "" when you cannot read it. It is never part of the reading.`
+
+// meterPrompt is the per-meter-type part of an OCR call.
+type meterPrompt struct {
+	base     string  // instructions, ocrPromptDigits aside
+	unit     string  // unit of the reading, echoed in OCRResponse.Unit
+	usual    string  // typical usage between two readings, for the sanity hint
+	maxUsual float64 // usage above this counts as "far larger" than usual
+	version  string  // recorded on every attempt; bump when base changes
+
+	// Set by withProfile.
+	profile    string // appended to base
+	digitCount int    // digits a reading must have; 0: any
+	learn      bool   // a confident answer becomes the meter's profile
+	serial     string // the meter's serial number the answer's must match
+	bank       bool   // read every meter of a meter bank; see forBank
+
+	// Set by withCorrections: the user's corrected reads of this meter, sent
+	// as examples.
+	corrections []*models.OCRCorrection
+}
+
+// withProfile returns mp for the user's meter: p is described to the model
+// and its digit count checked on the answer (see digitCountOK). Without a
+// profile, learn asks the model to describe the meter instead, so the read
+// can create one (see OCRService.learnProfile). The serial number is asked
+// for in both cases: to learn it, or to check it.
+func (mp meterPrompt) withProfile(p *models.MeterProfile, learn bool) meterPrompt {
+	if p == nil {
+		if learn {
+			mp.profile, mp.learn = ocrPromptDescribeMeter+ocrPromptSerial, true
+		}
+		return mp
+	}
+	var b strings.Builder
+	switch p.Display {
+	case models.MeterDisplayMechanical:
+		b.WriteString(" Its main register is a row of digit wheels.")
+	case models.MeterDisplayLCD:
+		b.WriteString(" Its main register is a digital (LCD) display.")
+	}
+	if p.DigitCount > 0 {
+		fmt.Fprintf(&b, " The main register has exactly %d digits including leading zeros, so the reading has %d digits.", p.DigitCount, p.DigitCount)
+	}
+	if p.DecimalDrum {
+		b.WriteString(" It is followed by a red or marked fraction digit, which is not part of the reading.")
+	}
+	if p.Model != "" {
+		fmt.Fprintf(&b, " The meter model is %q.", p.Model)
+	}
+	if b.Len() > 0 {
+		mp.profile = "\n\nThis user's meter is known." + b.String()
+	}
+	if p.Serial != "" {
+		mp.profile += ocrPromptSerial
+	}
+	mp.digitCount, mp.serial = p.DigitCount, p.Serial
+	return mp
+}
+
+// meterPrompts maps every supported meter type to its prompt. Empty
+// MeterType means electricity (see lookupMeterPrompt).
+var meterPrompts = map[models.MeterType]meterPrompt{
+	models.MeterTypeElectricity: {base: ocrPromptBase, unit: "kWh", usual: "a few hundred kWh", maxUsual: 2000, version: "electricity-2"},
+	models.MeterTypeWater:       {base: ocrPromptWater, unit: "m3", usual: "a few dozen m3", maxUsual: 200, version: "water-2"},
+	models.MeterTypeGas:         {base: ocrPromptGas, unit: "m3", usual: "a few dozen m3", maxUsual: 200, version: "gas-2"},
+}
+
+func lookupMeterPrompt(t models.MeterType) (models.MeterType, meterPrompt, bool) {
+	if t == "" {
+		t = models.MeterTypeElectricity
+	}
+	mp, ok := meterPrompts[t]
+	return t, mp, ok
+}
+
 // buildOCRPrompt returns the base instructions, plus a sanity-check hint when a
 // meaningful previous reading is available (prev > 0). On first use there is no
 // previous reading (prev == 0), so the hint is omitted to avoid biasing the model.
-func buildOCRPrompt(prev float64) string {
+// A bank prompt asks for every meter instead (see forBank).
+func buildOCRPrompt(mp meterPrompt, prev float64) string {
+	if mp.bank {
+		return mp.base + ocrPromptBank
+	}
 	if prev <= 0 {
-		return ocrPromptBase
+		return mp.base + ocrPromptDigits + mp.profile
 	}
 	p := strconv.FormatFloat(prev, 'f', -1, 64)
-	return ocrPromptBase +
+	return mp.base + ocrPromptDigits + mp.profile +
 		"\n\nContext: the previous reading was " + p +
 		". This meter only counts up, so the new reading must be greater than or equal to " + p +
-		", and is usually within a few hundred kWh of it. If the value you read is below " + p +
+		", and is usually within " + mp.usual + " of it. If the value you read is below " + p +
 		" or far larger than that, re-check the digits and lower your confidence."
 }
 
@@ -76,14 +307,71 @@ var ocrResponseSchema = &genai.Schema{
 		"reading":    {Type: genai.TypeNumber},
 		"confidence": {Type: genai.TypeNumber},
 		"notes":      {Type: genai.TypeString},
+		"digits": {
+			Type: genai.TypeArray,
+			Items: &genai.Schema{
+				Type: genai.TypeObject,
+				Properties: map[string]*genai.Schema{
+					"value":      {Type: genai.TypeInteger},
+					"confidence": {Type: genai.TypeNumber},
+					"box_2d":     ocrBoxSchema,
+				},
+				Required: []string{"value", "confidence"},
+			},
+		},
+		"register_box": ocrBoxSchema,
+		"serial":       {Type: genai.TypeString},
+		"meters": {
+			Type: genai.TypeArray,
+			Items: &genai.Schema{
+				Type: genai.TypeObject,
+				Properties: map[string]*genai.Schema{
+					"reading":    {Type: genai.TypeNumber},
+					"confidence": {Type: genai.TypeNumber},
+					"notes":      {Type: genai.TypeString},
+					"serial":     {Type: genai.TypeString},
+					"box_2d":     ocrBoxSchema,
+				},
+				Required: []string{"reading", "confidence"},
+			},
+		},
+		"meter": {
+			Type: genai.TypeObject,
+			Properties: map[string]*genai.Schema{
+				"display":      {Type: genai.TypeString, Enum: []string{string(models.MeterDisplayMechanical), string(models.MeterDisplayLCD)}},
+				"decimal_drum": {Type: genai.TypeBoolean},
+				"model":        {Type: genai.TypeString},
+			},
+		},
 	},
 	Required: []string{"reading", "confidence"},
 }
 
+var ocrBoxSchema = &genai.Schema{Type: genai.TypeArray, Items: &genai.Schema{Type: genai.TypeInteger}}
+
 type ocrModelOutput struct {
-	Reading    float64 `json:"reading"`
-	Confidence float64 `json:"confidence"`
-	Notes      string  `json:"notes,omitempty"`
+	Reading     float64             `json:"reading"`
+	Confidence  float64             `json:"confidence"`
+	Notes       string              `json:"notes,omitempty"`
+	Digits      []ocrModelDigit     `json:"digits,omitempty"`
+	RegisterBox []float64           `json:"register_box,omitempty"`
+	Meter       *ocrModelMeter      `json:"meter,omitempty"`
+	Serial      string              `json:"serial,omitempty"`
+	Meters      []ocrModelBankMeter `json:"meters,omitempty"`
+}
+
+// ocrModelMeter is the model's description of the meter; see
+// ocrPromptDescribeMeter.
+type ocrModelMeter struct {
+	Display     models.MeterDisplay `json:"display"`
+	DecimalDrum bool                `json:"decimal_drum"`
+	Model       string              `json:"model"`
+}
+
+type ocrModelDigit struct {
+	Value      int       `json:"value"`
+	Confidence float64   `json:"confidence"`
+	Box        []float64 `json:"box_2d,omitempty"`
 }
 
 // Process parses an image and returns an OCRResponse.
@@ -91,66 +379,425 @@ type ocrModelOutput struct {
 // Either req.ImageBase64 or req.ImageURL must be set:
 //   - ImageURL: supports gs:// paths (downloaded via the Storage service)
 //   - ImageBase64: useful for the just-snapped, not-yet-uploaded case
-func (s *OCRService) Process(ctx context.Context, req *models.OCRRequest) (*models.OCRResponse, error) {
-	// fail-late: if the underlying client was never initialised (e.g. staging
-	// has not set GEMINI_API_KEY yet) return 503.
-	if s.client == nil {
+//
+// The attempt is recorded for uid whether it succeeds or not; a failure to
+// record is logged and does not fail the OCR.
+func (s *OCRService) Process(ctx context.Context, uid string, req *models.OCRRequest) (*models.OCRResponse, error) {
+	// fail-late: if no provider could be initialised (e.g. staging has not
+	// set GEMINI_API_KEY yet) return 503.
+	if len(s.providers) == 0 {
 		return nil, &middleware.AppError{HTTPStatus: 503, Key: "errors.ocr.not_configured"}
 	}
 
+	meterType, prompt, ok := lookupMeterPrompt(req.MeterType)
+	if !ok {
+		return nil, &middleware.AppError{HTTPStatus: 400, Key: "errors.ocr.invalid_meter_type"}
+	}
+	prompt = prompt.withTemplate(s.promptTemplate(ctx, uid, meterType))
+	var rooms []*models.Room
+	if req.Bank {
+		// Looked up first, so a failed lookup costs no model call.
+		var err error
+		if rooms, err = s.bankRooms(ctx, uid); err != nil {
+			return nil, err
+		}
+		prompt = prompt.forBank()
+	} else {
+		prompt = prompt.withProfile(s.meterProfile(ctx, uid, meterType))
+		prompt = prompt.withCorrections(s.latestCorrections(ctx, uid, meterType))
+	}
+
+	start := time.Now()
+	attempt := models.OCRAttempt{
+		MeterType:     meterType,
+		ImageURL:      req.ImageURL,
+		PromptVersion: prompt.version,
+		CreatedAt:     start.UTC(),
+	}
+	for _, c := range prompt.corrections {
+		attempt.Examples = append(attempt.Examples, c.AttemptID)
+	}
+	entry, err := s.read(ctx, uid, prompt, req, &attempt)
+	attempt.LatencyMs = time.Since(start).Milliseconds()
+	if err != nil {
+		attempt.Error = attemptErrorKey(err)
+		s.recordAttempt(ctx, uid, &attempt)
+		return nil, err
+	}
+	attempt.Model = entry.Model
+	attempt.Reading = entry.Reading
+	attempt.Confidence = entry.Confidence
+	attempt.RawText = entry.RawText
+	attempt.Notes = entry.Notes
+	attempt.Escalation = entry.Escalation
+	attempt.Consensus = entry.Consensus
+	attempt.Digits = entry.Digits
+	attempt.RegisterBox = entry.RegisterBox
+	attempt.Serial = entry.Serial
+	if !serialMatches(prompt.serial, entry.Serial) {
+		attempt.Warnings = append(attempt.Warnings, models.OCRWarningWrongMeter)
+	}
+	if prompt.bank {
+		attempt.Meters = mapBankRooms(entry.Meters, rooms)
+	}
+
+	return &models.OCRResponse{
+		Reading:       entry.Reading,
+		Confidence:    entry.Confidence,
+		RawText:       entry.RawText,
+		Model:         entry.Model,
+		MeterType:     meterType,
+		Unit:          prompt.unit,
+		PromptVersion: prompt.version,
+		AttemptID:     s.recordAttempt(ctx, uid, &attempt),
+		Escalated:     entry.Escalation != nil,
+		Consensus:     entry.Consensus,
+		Cached:        attempt.Cached,
+		Quality:       attempt.Quality,
+		Digits:        entry.Digits,
+		RegisterBox:   entry.RegisterBox,
+		Serial:        entry.Serial,
+		Warnings:      attempt.Warnings,
+		Meters:        attempt.Meters,
+	}, nil
+}
+
+// read loads the image and returns the answer for it: from the cache when
+// the same image was read with the same models, prompt and previous reading
+// before, else from the providers (and then cached). It notes a cache hit,
+// and the providers that failed, on attempt.
+func (s *OCRService) read(ctx context.Context, uid string, prompt meterPrompt, req *models.OCRRequest, attempt *models.OCRAttempt) (*models.OCRCacheEntry, error) {
+	imgData, imgMIME, err := s.loadImage(ctx, uid, req)
+	if err != nil {
+		return nil, err
+	}
+	if imgData, imgMIME, attempt.Quality, err = s.pipeline.process(imgData, imgMIME); err != nil {
+		return nil, err
+	}
+	if err := s.quality.check(attempt.Quality); err != nil {
+		return nil, err
+	}
+	prev, consensus := req.PreviousReading, req.Consensus
+	if prompt.bank {
+		// A previous reading and a vote are about one meter.
+		prev, consensus = 0, false
+	}
+	key := ocrCacheKey(imgData, s.modelsKey(), prompt.version+prompt.profile+prompt.correctionsKey(), prev, consensus)
+	if entry := s.cached(ctx, uid, key); entry != nil {
+		attempt.Cached = true
+		return entry, nil
+	}
+
+	in := &OCRInput{
+		Prompt:          buildOCRPrompt(prompt, prev),
+		Image:           imgData,
+		MIMEType:        imgMIME,
+		PreviousReading: prev,
+		Bank:            prompt.bank,
+	}
+	if len(prompt.corrections) > 0 {
+		if in.Examples = s.loadExamples(ctx, uid, prompt.corrections); len(in.Examples) > 0 {
+			in.Prompt += fmt.Sprintf(ocrPromptExamples, len(in.Examples))
+		}
+	}
 	var (
-		imgData []byte
-		imgMIME string
+		read       *meterRead
+		escalation *models.OCREscalation
+		votes      *models.OCRConsensus
 	)
+	switch {
+	case prompt.bank:
+		read, err = askProviders(ctx, s.providers, in)
+	case consensus:
+		// The samples already include the stronger model; see readConsensus.
+		read, votes, err = s.readConsensus(ctx, in)
+	default:
+		read, err = askProviders(ctx, s.providers, in)
+		if err == nil {
+			read, escalation = s.escalate(ctx, prompt, req, read)
+		}
+	}
+	attempt.Model = read.provider
+	attempt.Fallbacks = read.failed
+	if err != nil {
+		return nil, err
+	}
+	if !digitCountOK(prompt, read.out) {
+		attempt.Reading, attempt.Confidence, attempt.Notes = read.out.Reading, read.out.Confidence, read.out.Notes
+		return nil, &middleware.AppError{HTTPStatus: 422, Key: "errors.ocr.wrong_digit_count"}
+	}
+	if prompt.learn {
+		s.learnProfile(ctx, uid, attempt.MeterType, read.out)
+	}
+	var meters []models.OCRBankMeter
+	if prompt.bank {
+		if meters, err = bankMeters(read.out); err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now().UTC()
+	entry := &models.OCRCacheEntry{
+		Reading:     read.out.Reading,
+		Confidence:  read.out.Confidence,
+		RawText:     read.rawText,
+		Notes:       read.out.Notes,
+		Model:       read.provider,
+		Escalation:  escalation,
+		Consensus:   votes,
+		Digits:      readDigits(read.out),
+		RegisterBox: modelBox(read.out.RegisterBox),
+		Serial:      strings.TrimSpace(read.out.Serial),
+		Meters:      meters,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(s.cacheTTL),
+	}
+	s.cache(ctx, uid, key, entry)
+	return entry, nil
+}
+
+// meterRead is askProviders' result.
+type meterRead struct {
+	in       *OCRInput
+	out      *ocrModelOutput
+	rawText  string
+	provider string   // Name of the provider that answered
+	failed   []string // providers that failed before it
+}
+
+// escalate retries read with the stronger model when escalationReason finds
+// it doubtful, and returns the better of the two answers (see betterRead)
+// with the record of the escalation. read is returned as is, with a nil
+// record, when it is not doubtful or escalation is disabled; a failed retry
+// keeps read.
+func (s *OCRService) escalate(ctx context.Context, prompt meterPrompt, req *models.OCRRequest, read *meterRead) (*meterRead, *models.OCREscalation) {
+	reason := escalationReason(prompt, req.PreviousReading, read.out)
+	if reason == "" || len(s.escalation) == 0 {
+		return read, nil
+	}
+	esc := &models.OCREscalation{
+		Reason:  reason,
+		Initial: models.OCRCandidate{Model: read.provider, Reading: read.out.Reading, Confidence: read.out.Confidence},
+	}
+	retry, err := askProviders(ctx, s.escalation, read.in)
+	if err != nil {
+		slog.Warn("ocr: escalation failed", "reason", reason, "err", err)
+		esc.Error = attemptErrorKey(err)
+		return read, esc
+	}
+	esc.Retry = &models.OCRCandidate{Model: retry.provider, Reading: retry.out.Reading, Confidence: retry.out.Confidence}
+	if betterRead(prompt, req.PreviousReading, read.out, retry.out) == retry.out {
+		esc.UsedRetry = true
+		retry.failed = read.failed
+		return retry, esc
+	}
+	return read, esc
+}
+
+// askProviders asks providers for the reading, in order, until one gives a
+// usable answer. An upstream error, an empty answer or one that does not
+// parse moves on to the next provider; when all fail, the last error is
+// returned. The result is never nil.
+func askProviders(ctx context.Context, providers []OCRProvider, in *OCRInput) (*meterRead, error) {
+	read := &meterRead{in: in}
+	var lastErr error
+	for _, p := range providers {
+		rawText, err := p.ReadMeter(ctx, in)
+		var out *ocrModelOutput
+		if err == nil {
+			out, err = parseOCROutput(rawText)
+		}
+		if err != nil {
+			slog.Warn("ocr: provider failed", "provider", p.Name(), "err", err)
+			read.failed = append(read.failed, p.Name())
+			lastErr = err
+			continue
+		}
+		read.out, read.rawText, read.provider = out, rawText, p.Name()
+		return read, nil
+	}
+	return read, lastErr
+}
+
+// loadImage returns the bytes and MIME type of the request's image. The type
+// comes from the bytes themselves (see checkImageType). HEIC is converted to
+// JPEG, and refused when no converter is configured: unconverted, it would
+// skip the pixel caps, the quality gate and the LCD reader, and only Gemini
+// reads it.
+func (s *OCRService) loadImage(ctx context.Context, uid string, req *models.OCRRequest) ([]byte, string, error) {
+	data, claimed, err := s.fetchImage(ctx, uid, req)
+	if err != nil {
+		return nil, "", err
+	}
+	mime, err := checkImageType(data, claimed)
+	if err != nil {
+		return nil, "", err
+	}
+	if mime != "image/heic" {
+		return data, mime, nil
+	}
+	if s.heic == nil {
+		return nil, "", &middleware.AppError{HTTPStatus: 415, Key: "errors.ocr.heic_not_supported"}
+	}
+	jpg, err := s.heic.ToJPEG(ctx, data)
+	if err != nil {
+		return nil, "", &middleware.AppError{HTTPStatus: 422, Key: "errors.ocr.heic_conversion_failed", Cause: err}
+	}
+	if sniffImageType(jpg) != "image/jpeg" {
+		return nil, "", &middleware.AppError{HTTPStatus: 422, Key: "errors.ocr.heic_conversion_failed",
+			Cause: errors.New("converter output is not a JPEG")}
+	}
+	return jpg, "image/jpeg", nil
+}
 
+// fetchImage returns the request's image bytes and the MIME type they are
+// labelled with ("" when nothing says). A gs:// photo must be one of uid's:
+// the attempt keeps its path, and a correction may reuse it as an example.
+func (s *OCRService) fetchImage(ctx context.Context, uid string, req *models.OCRRequest) ([]byte, string, error) {
 	switch {
 	case req.ImageURL != "":
-		if !strings.HasPrefix(req.ImageURL, "gs://") {
-			return nil, &middleware.AppError{HTTPStatus: 400, Key: "errors.ocr.invalid_image_url"}
+		if _, err := userObject(uid, req.ImageURL); err != nil {
+			return nil, "", &middleware.AppError{HTTPStatus: 400, Key: "errors.ocr.invalid_image_url", Cause: err}
+		}
+		data, ct, err := s.storage.DownloadObject(ctx, req.ImageURL, maxOCRImageBytes)
+		if errors.Is(err, ErrObjectTooLarge) {
+			return nil, "", &middleware.AppError{HTTPStatus: 413, Key: "errors.ocr.image_too_large", Cause: err}
 		}
-		data, ct, err := s.storage.DownloadObject(ctx, req.ImageURL)
 		if err != nil {
-			return nil, &middleware.AppError{HTTPStatus: 502, Key: "errors.ocr.download_failed", Cause: err}
+			return nil, "", &middleware.AppError{HTTPStatus: 502, Key: "errors.ocr.download_failed", Cause: err}
 		}
 		if ct == "application/octet-stream" {
 			// If GCS did not store a ContentType, fall back to guessing from the URL extension
-			if guessed, gerr := guessMimeFromURL(req.ImageURL); gerr == nil {
-				ct = guessed
-			}
+			ct, _ = guessMimeFromURL(req.ImageURL)
 		}
-		imgData, imgMIME = data, ct
+		return data, ct, nil
 	case req.ImageBase64 != "":
+		// Checked on the encoded length (4 chars per 3 bytes, plus a data-URI
+		// header) so an oversized body is never decoded.
+		if len(req.ImageBase64) > maxOCRImageBytes/3*4+256 {
+			return nil, "", &middleware.AppError{HTTPStatus: 413, Key: "errors.ocr.image_too_large"}
+		}
 		data, mime, err := decodeBase64Image(req.ImageBase64)
 		if err != nil {
-			return nil, &middleware.AppError{HTTPStatus: 400, Key: "errors.ocr.invalid_image", Cause: err}
+			return nil, "", &middleware.AppError{HTTPStatus: 400, Key: "errors.ocr.invalid_base64", Cause: err}
+		}
+		if len(data) > maxOCRImageBytes {
+			return nil, "", &middleware.AppError{HTTPStatus: 413, Key: "errors.ocr.image_too_large"}
 		}
-		imgData, imgMIME = data, mime
+		return data, mime, nil
 	default:
-		return nil, &middleware.AppError{HTTPStatus: 400, Key: "errors.ocr.image_required"}
+		return nil, "", &middleware.AppError{HTTPStatus: 400, Key: "errors.ocr.image_required"}
 	}
+}
 
-	contents := []*genai.Content{{
-		Role: "user",
-		Parts: []*genai.Part{
-			{Text: buildOCRPrompt(req.PreviousReading)},
-			{InlineData: &genai.Blob{MIMEType: imgMIME, Data: imgData}},
-		},
-	}}
+// recordAttempt stores a and returns its ID, or "" when it could not be
+// stored.
+func (s *OCRService) recordAttempt(ctx context.Context, uid string, a *models.OCRAttempt) string {
+	if s.fs == nil || uid == "" {
+		return ""
+	}
+	ref := ocrAttemptsCol(s.fs, uid).NewDoc()
+	if _, err := ref.Set(ctx, a); err != nil {
+		slog.Warn("ocr: record attempt failed", "uid", uid, "err", err)
+		return ""
+	}
+	return ref.ID
+}
 
-	resp, err := s.client.Models.GenerateContent(ctx, s.model, contents, &genai.GenerateContentConfig{
-		ResponseMIMEType: "application/json",
-		ResponseSchema:   ocrResponseSchema,
-		Temperature:      genai.Ptr(float32(0.0)), // Reading recognition needs to be deterministic
-	})
-	if err != nil {
-		return nil, &middleware.AppError{HTTPStatus: 502, Key: "errors.ocr.upstream_failed", Cause: err}
+// --------------- helpers ---------------
+
+// escalationReason says why out should be retried with the stronger model:
+// low confidence, or a reading the sanity hint in buildOCRPrompt rules out.
+// Empty when out looks fine.
+func escalationReason(prompt meterPrompt, prev float64, out *ocrModelOutput) models.OCREscalationReason {
+	if !plausibleReading(prompt, prev, out.Reading) {
+		return models.OCREscalationImplausible
+	}
+	if !digitCountOK(prompt, out) {
+		return models.OCREscalationDigitCount
+	}
+	if out.Confidence < escalationConfidenceThreshold {
+		return models.OCREscalationLowConfidence
+	}
+	return ""
+}
+
+// escalationConfidenceThreshold matches the bar below which a bill created
+// from a photo becomes a draft: a retry is cheaper than asking the user.
+const escalationConfidenceThreshold = draftConfidenceThreshold
+
+// plausibleReading applies the previous-reading hint: a meter only counts
+// up, and rarely by more than prompt.maxUsual. 0 means "unreadable" and is
+// never plausible. Without a previous reading anything positive goes.
+func plausibleReading(prompt meterPrompt, prev, reading float64) bool {
+	if reading <= 0 {
+		return false
+	}
+	if prev <= 0 {
+		return true
+	}
+	return reading >= prev && reading-prev <= prompt.maxUsual
+}
+
+// digitCountOK checks out against the digit count of the meter's profile:
+// the digits it spelled out (see spelledDigits) must be exactly as many, or,
+// when it spelled none, its reading no longer. Without a known digit count
+// anything goes.
+func digitCountOK(prompt meterPrompt, out *ocrModelOutput) bool {
+	if prompt.digitCount == 0 || out.Reading <= 0 {
+		return true
+	}
+	if digits := spelledDigits(out); digits != "" {
+		return len(digits) == prompt.digitCount
+	}
+	return len(strconv.FormatFloat(math.Floor(out.Reading), 'f', 0, 64)) <= prompt.digitCount
+}
+
+// serialMatches says whether the serial number read off the photo is the
+// profile's. Case, spaces, dashes and a "No." label do not count; an
+// unreadable serial, or a meter without a registered one, matches.
+func serialMatches(registered, read string) bool {
+	if registered == "" || read == "" {
+		return true
+	}
+	return normalizeSerial(registered) == normalizeSerial(read)
+}
+
+func normalizeSerial(s string) string {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "NO."), "NO ")
+	return strings.Map(func(r rune) rune {
+		if (r >= '0' && r <= '9') || (r >= 'A' && r <= 'Z') {
+			return r
+		}
+		return -1
+	}, s)
+}
+
+// betterRead picks between the first answer and the stronger model's: a
+// plausible reading with the profile's digit count beats one without,
+// otherwise the higher confidence wins, and a tie goes to the stronger
+// model.
+func betterRead(prompt meterPrompt, prev float64, first, retry *ocrModelOutput) *ocrModelOutput {
+	firstOK := plausibleReading(prompt, prev, first.Reading) && digitCountOK(prompt, first)
+	retryOK := plausibleReading(prompt, prev, retry.Reading) && digitCountOK(prompt, retry)
+	if firstOK != retryOK {
+		if retryOK {
+			return retry
+		}
+		return first
 	}
+	if retry.Confidence >= first.Confidence {
+		return retry
+	}
+	return first
+}
 
-	rawText := resp.Text()
+// parseOCROutput parses a provider's raw answer.
+func parseOCROutput(rawText string) (*ocrModelOutput, error) {
 	if rawText == "" {
 		return nil, &middleware.AppError{HTTPStatus: 502, Key: "errors.ocr.no_response"}
 	}
-
 	var parsed ocrModelOutput
 	if err := json.Unmarshal([]byte(rawText), &parsed); err != nil {
 		return nil, &middleware.AppError{
@@ -159,20 +806,61 @@ func (s *OCRService) Process(ctx context.Context, req *models.OCRRequest) (*mode
 			Cause:      fmt.Errorf("parse %q: %w", rawText, err),
 		}
 	}
+	return &parsed, nil
+}
 
-	return &models.OCRResponse{
-		Reading:    parsed.Reading,
-		Confidence: parsed.Confidence,
-		RawText:    rawText,
-		Model:      s.model,
-	}, nil
+// readDigits returns out's per-digit answer, or nil when it does not spell
+// out's reading (a model that miscounted its own digits is not pointing at
+// the right wheel either).
+func readDigits(out *ocrModelOutput) []models.OCRDigit {
+	if len(out.Digits) == 0 || out.Reading <= 0 {
+		return nil
+	}
+	digits := make([]models.OCRDigit, len(out.Digits))
+	var spelled strings.Builder
+	for i, d := range out.Digits {
+		if d.Value < 0 || d.Value > 9 {
+			return nil
+		}
+		spelled.WriteByte(byte('0' + d.Value))
+		digits[i] = models.OCRDigit{
+			Value:      d.Value,
+			Confidence: roundHundredth(math.Min(math.Max(d.Confidence, 0), 1)),
+			Box:        modelBox(d.Box),
+		}
+	}
+	if v, _ := strconv.ParseFloat(spelled.String(), 64); v != math.Floor(out.Reading) {
+		return nil
+	}
+	return digits
 }
 
-// --------------- helpers ---------------
+// modelBox converts a [ymin, xmin, ymax, xmax] box on the 0-1000 scale to
+// fractions of the photo; nil when it is missing or malformed.
+func modelBox(b []float64) *models.OCRBox {
+	if len(b) != 4 {
+		return nil
+	}
+	ymin, xmin, ymax, xmax := b[0], b[1], b[2], b[3]
+	if ymin < 0 || xmin < 0 || ymax > 1000 || xmax > 1000 || ymin >= ymax || xmin >= xmax {
+		return nil
+	}
+	return &models.OCRBox{Left: xmin / 1000, Top: ymin / 1000, Right: xmax / 1000, Bottom: ymax / 1000}
+}
 
-func decodeBase64Image(s string) (data []byte, mime string, err error) {
-	mime = "image/jpeg"
+// attemptErrorKey is what a failed attempt records: the i18n key when there
+// is one, so attempts can be grouped by failure.
+func attemptErrorKey(err error) string {
+	var appErr *middleware.AppError
+	if errors.As(err, &appErr) {
+		return appErr.Key
+	}
+	return err.Error()
+}
 
+// decodeBase64Image decodes raw base64 or a data URI. mime is the type the
+// data URI claims, "" for raw base64; it is only a claim (see checkImageType).
+func decodeBase64Image(s string) (data []byte, mime string, err error) {
 	// data URI: data:image/png;base64,xxx
 	if strings.HasPrefix(s, "data:") {
 		commaIdx := strings.Index(s, ",")
@@ -204,6 +892,8 @@ func guessMimeFromURL(url string) (string, error) {
 		return "image/png", nil
 	case strings.HasSuffix(url, ".webp"):
 		return "image/webp", nil
+	case strings.HasSuffix(url, ".heic"), strings.HasSuffix(url, ".heif"):
+		return "image/heic", nil
 	default:
 		return "", fmt.Errorf("cannot guess MIME from URL: %s", url)
 	}
@@ -0,0 +1,114 @@
+package services
+
+import (
+	"context"
+	"math"
+	"strings"
+
+	"wattrent/internal/middleware"
+	"wattrent/internal/models"
+)
+
+// ocrPromptBank replaces the single-register answer when the photo is of a
+// meter bank (OCRRequest.Bank): the base prompt still says how to read one
+// register.
+const ocrPromptBank = `
+
+This photo may show a BANK of several meters side by side (e.g. the wall of meters of an apartment building). Read EVERY meter whose main register you can see, each one on its own, with the rules above. Return them in "meters", in reading order: top row first, left to right within a row. For each give "reading", "confidence", "notes" (its digits, e.g. "3 6 0 3 4"), "serial" (its serial number as printed without the "No." label, or "" when you cannot read it; here it tells the meters apart and is never part of a reading) and "box_2d", the bounding box of the whole meter as [ymin, xmin, ymax, xmax] scaled to 0-1000. Set the top-level "reading" and "confidence" to 0.`
+
+// ocrBankPromptVersion is appended to the meter prompt's version on bank
+// reads; bump it when ocrPromptBank changes.
+const ocrBankPromptVersion = "bank-1"
+
+// maxBankMeters caps the meters kept from one photo.
+const maxBankMeters = 48
+
+// ocrModelBankMeter is one entry of the model's "meters".
+type ocrModelBankMeter struct {
+	Reading    float64   `json:"reading"`
+	Confidence float64   `json:"confidence"`
+	Notes      string    `json:"notes,omitempty"`
+	Serial     string    `json:"serial,omitempty"`
+	Box        []float64 `json:"box_2d,omitempty"`
+}
+
+// forBank returns mp for reading a meter bank. The meter profile, the
+// previous reading, escalation and consensus are all about one meter, so
+// none of them apply.
+func (mp meterPrompt) forBank() meterPrompt {
+	return meterPrompt{
+		base:    mp.base,
+		unit:    mp.unit,
+		version: mp.version + "+" + ocrBankPromptVersion,
+		bank:    true,
+	}
+}
+
+// bankMeters returns the meters of out, numbered in the model's order, or
+// errors.ocr.no_meters_found when it found none.
+func bankMeters(out *ocrModelOutput) ([]models.OCRBankMeter, error) {
+	var meters []models.OCRBankMeter
+	for _, m := range out.Meters {
+		if len(meters) == maxBankMeters {
+			break
+		}
+		meters = append(meters, models.OCRBankMeter{
+			Position:   len(meters) + 1,
+			Serial:     strings.TrimSpace(m.Serial),
+			Reading:    math.Max(m.Reading, 0),
+			Confidence: roundHundredth(math.Min(math.Max(m.Confidence, 0), 1)),
+			Notes:      m.Notes,
+			Box:        modelBox(m.Box),
+		})
+	}
+	if len(meters) == 0 {
+		return nil, &middleware.AppError{HTTPStatus: 422, Key: "errors.ocr.no_meters_found"}
+	}
+	return meters, nil
+}
+
+// mapBankRooms returns meters with each of the user's rooms on its meter: the
+// one with the room's serial number (see normalizeSerial), or else the one at
+// its position. A meter goes to one room at most, serial matches first, and a
+// position never maps a meter whose serial was read as another one.
+func mapBankRooms(meters []models.OCRBankMeter, rooms []*models.Room) []models.OCRBankMeter {
+	out := make([]models.OCRBankMeter, len(meters))
+	copy(out, meters)
+	mapped := make([]bool, len(rooms))
+	for i, r := range rooms {
+		if strings.TrimSpace(r.Serial) == "" {
+			continue
+		}
+		for j := range out {
+			if out[j].Room == "" && out[j].Serial != "" && normalizeSerial(out[j].Serial) == normalizeSerial(r.Serial) {
+				out[j].Room, out[j].RoomLabel, mapped[i] = r.ID, r.Label, true
+				break
+			}
+		}
+	}
+	for i, r := range rooms {
+		if mapped[i] || r.Position <= 0 || r.Position > len(out) {
+			continue
+		}
+		m := &out[r.Position-1]
+		if m.Room == "" && (r.Serial == "" || m.Serial == "" || normalizeSerial(m.Serial) == normalizeSerial(r.Serial)) {
+			m.Room, m.RoomLabel = r.ID, r.Label
+		}
+	}
+	return out
+}
+
+// Rooms is what OCRService needs of the room registry: the rooms a bank
+// read maps its meters to.
+type Rooms interface {
+	List(ctx context.Context, uid string) ([]*models.Room, error)
+}
+
+// bankRooms returns the user's rooms for a bank read, or none without a
+// registry.
+func (s *OCRService) bankRooms(ctx context.Context, uid string) ([]*models.Room, error) {
+	if s.rooms == nil || uid == "" {
+		return nil, nil
+	}
+	return s.rooms.List(ctx, uid)
+}
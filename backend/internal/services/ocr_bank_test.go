@@ -0,0 +1,155 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"wattrent/internal/middleware"
+	"wattrent/internal/models"
+)
+
+// stubRooms hands every user the same rooms, or fails.
+type stubRooms struct {
+	rooms []*models.Room
+	err   error
+}
+
+func (s stubRooms) List(context.Context, string) ([]*models.Room, error) {
+	return s.rooms, s.err
+}
+
+func TestBankMeters(t *testing.T) {
+	t.Parallel()
+
+	out := &ocrModelOutput{Meters: []ocrModelBankMeter{
+		{Reading: 1204, Confidence: 0.914, Serial: " 5208811 ", Box: []float64{100, 50, 400, 300}},
+		{Reading: -3, Confidence: 1.4},
+	}}
+	meters, err := bankMeters(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(meters) != 2 || meters[0].Position != 1 || meters[1].Position != 2 {
+		t.Fatalf("meters = %+v, want two numbered from 1", meters)
+	}
+	if meters[0].Serial != "5208811" || meters[0].Confidence != 0.91 || meters[0].Box == nil {
+		t.Errorf("meters[0] = %+v, want trimmed serial, rounded confidence and a box", meters[0])
+	}
+	if meters[1].Reading != 0 || meters[1].Confidence != 1 {
+		t.Errorf("meters[1] = %+v, want reading and confidence clamped", meters[1])
+	}
+
+	many := &ocrModelOutput{Meters: make([]ocrModelBankMeter, maxBankMeters+5)}
+	if meters, _ := bankMeters(many); len(meters) != maxBankMeters {
+		t.Errorf("kept %d meters, want %d", len(meters), maxBankMeters)
+	}
+
+	_, err = bankMeters(&ocrModelOutput{Reading: 36034})
+	var appErr *middleware.AppError
+	if !errors.As(err, &appErr) || appErr.HTTPStatus != 422 || appErr.Key != "errors.ocr.no_meters_found" {
+		t.Errorf("err = %v, want 422 errors.ocr.no_meters_found", err)
+	}
+}
+
+func TestMapBankRooms(t *testing.T) {
+	t.Parallel()
+
+	meters := []models.OCRBankMeter{
+		{Position: 1, Serial: "No. 5208811"},
+		{Position: 2, Serial: "7730042"},
+		{Position: 3},
+	}
+	room := func(id, serial string, position int) *models.Room {
+		return &models.Room{ID: id, Label: "Room " + id, Serial: serial, Position: position}
+	}
+	tests := []struct {
+		name  string
+		rooms []*models.Room
+		want  []string // room per meter
+	}{
+		{name: "no rooms", want: []string{"", "", ""}},
+		{name: "by serial", rooms: []*models.Room{room("3F", "7730042", 0), room("2F", "5208811", 0)}, want: []string{"2F", "3F", ""}},
+		{name: "by position", rooms: []*models.Room{room("4F", "", 3)}, want: []string{"", "", "4F"}},
+		{name: "serial wins over position", rooms: []*models.Room{room("A", "", 2), room("B", "7730042", 0)}, want: []string{"", "B", ""}},
+		{name: "position with another serial", rooms: []*models.Room{room("2F", "9999999", 1)}, want: []string{"", "", ""}},
+		{name: "position with the serial unread", rooms: []*models.Room{room("4F", "1111111", 3)}, want: []string{"", "", "4F"}},
+		{name: "position out of range", rooms: []*models.Room{room("9F", "", 9)}, want: []string{"", "", ""}},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			got := mapBankRooms(meters, tc.rooms)
+			for i, m := range got {
+				if m.Room != tc.want[i] {
+					t.Errorf("meter %d room = %q, want %q", m.Position, m.Room, tc.want[i])
+				}
+				if wantLabel := "Room " + tc.want[i]; m.Room != "" && m.RoomLabel != wantLabel {
+					t.Errorf("meter %d room label = %q, want %q", m.Position, m.RoomLabel, wantLabel)
+				}
+			}
+			if meters[0].Room != "" || meters[1].Room != "" || meters[2].Room != "" {
+				t.Error("mapBankRooms changed its input")
+			}
+		})
+	}
+}
+
+func TestOCRService_Bank(t *testing.T) {
+	t.Parallel()
+
+	raw := `{"reading":0,"confidence":0,"meters":[` +
+		`{"reading":1204,"confidence":0.93,"notes":"0 1 2 0 4","serial":"5208811","box_2d":[100,50,400,300]},` +
+		`{"reading":877,"confidence":0.88,"notes":"0 0 8 7 7","serial":"7730042","box_2d":[100,350,400,600]}]}`
+	p := &stubOCRProvider{name: "gemini/flash-lite", raw: raw}
+	profiles := &memoryMeterProfiles{profiles: map[string]*models.MeterProfile{
+		"u/electricity": {MeterType: models.MeterTypeElectricity, DigitCount: 6, Serial: "3146159", Source: models.MeterProfileSourceUser},
+	}}
+	rooms := stubRooms{rooms: []*models.Room{{ID: "room-3f", Label: "3F", Serial: "7730042"}}}
+	svc := NewOCRService(nil, nil, []OCRProvider{p}, OCROptions{Profiles: profiles, Rooms: rooms})
+
+	resp, err := svc.Process(t.Context(), "u", &models.OCRRequest{
+		ImageBase64:     testPhotoBase64,
+		PreviousReading: 36000,
+		Bank:            true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(p.prompt, `"meters"`) || strings.Contains(p.prompt, "36000") {
+		t.Errorf("prompt = %q, want the bank prompt without the previous reading", p.prompt)
+	}
+	if !strings.HasSuffix(resp.PromptVersion, "+"+ocrBankPromptVersion) {
+		t.Errorf("prompt version = %q, want the bank version", resp.PromptVersion)
+	}
+	if len(resp.Meters) != 2 || resp.Meters[0].Reading != 1204 || resp.Meters[1].Room != "room-3f" || resp.Meters[1].RoomLabel != "3F" {
+		t.Errorf("meters = %+v, want both, the second mapped to 3F", resp.Meters)
+	}
+	if len(resp.Warnings) != 0 {
+		t.Errorf("warnings = %v, want the profile's serial not checked on a bank", resp.Warnings)
+	}
+
+	t.Run("no meters", func(t *testing.T) {
+		t.Parallel()
+		p := &stubOCRProvider{name: "gemini/flash-lite", raw: `{"reading":36034,"confidence":0.9}`}
+		svc := NewOCRService(nil, nil, []OCRProvider{p})
+		_, err := svc.Process(t.Context(), "u", &models.OCRRequest{ImageBase64: testPhotoBase64, Bank: true})
+		var appErr *middleware.AppError
+		if !errors.As(err, &appErr) || appErr.Key != "errors.ocr.no_meters_found" {
+			t.Errorf("err = %v, want errors.ocr.no_meters_found", err)
+		}
+	})
+
+	t.Run("room lookup failure costs no model call", func(t *testing.T) {
+		t.Parallel()
+		p := &stubOCRProvider{name: "gemini/flash-lite", raw: raw}
+		svc := NewOCRService(nil, nil, []OCRProvider{p}, OCROptions{Rooms: stubRooms{err: errors.New("unavailable")}})
+		_, err := svc.Process(t.Context(), "u", &models.OCRRequest{ImageBase64: testPhotoBase64, Bank: true})
+		if err == nil || p.calls != 0 {
+			t.Errorf("err = %v after %d calls, want the lookup's error and no call", err, p.calls)
+		}
+	})
+}
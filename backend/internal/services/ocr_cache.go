@@ -0,0 +1,117 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"wattrent/internal/models"
+)
+
+// OCRCache stores OCR answers so a photo submitted again (a client retry, the
+// user going back a screen) costs no model call. Keys come from ocrCacheKey.
+type OCRCache interface {
+	// Get returns the entry under key, or nil when there is none or it has
+	// expired.
+	Get(ctx context.Context, uid, key string) (*models.OCRCacheEntry, error)
+	Put(ctx context.Context, uid, key string, e *models.OCRCacheEntry) error
+}
+
+type firestoreOCRCache struct {
+	fs *firestore.Client
+}
+
+// NewFirestoreOCRCache keeps entries under /users/{uid}/ocrCache, so they
+// are deleted with the account like everything else of the user's.
+func NewFirestoreOCRCache(fs *firestore.Client) OCRCache {
+	return &firestoreOCRCache{fs: fs}
+}
+
+func (c *firestoreOCRCache) doc(uid, key string) *firestore.DocumentRef {
+	return c.fs.Collection("users").Doc(uid).Collection("ocrCache").Doc(key)
+}
+
+func (c *firestoreOCRCache) Get(ctx context.Context, uid, key string) (*models.OCRCacheEntry, error) {
+	snap, err := c.doc(uid, key).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var e models.OCRCacheEntry
+	if err := snap.DataTo(&e); err != nil {
+		return nil, err
+	}
+	// The TTL policy deletes expired entries within a day or so, not on the
+	// dot.
+	if time.Now().After(e.ExpiresAt) {
+		return nil, nil
+	}
+	return &e, nil
+}
+
+func (c *firestoreOCRCache) Put(ctx context.Context, uid, key string, e *models.OCRCacheEntry) error {
+	_, err := c.doc(uid, key).Set(ctx, e)
+	return err
+}
+
+// ocrCacheKey identifies an answer: the same image bytes, read by the same
+// models with the same prompt (version and previous-reading hint) in the
+// same mode, get the same answer.
+func ocrCacheKey(image []byte, models, promptVersion string, prev float64, consensus bool) string {
+	imageSum := sha256.Sum256(image)
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		hex.EncodeToString(imageSum[:]),
+		models,
+		promptVersion,
+		strconv.FormatFloat(prev, 'f', -1, 64),
+		strconv.FormatBool(consensus),
+	}, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// modelsKey names the configured provider chains, for ocrCacheKey: changing
+// a model or the fallback order starts a fresh cache.
+func (s *OCRService) modelsKey() string {
+	names := func(providers []OCRProvider) string {
+		out := make([]string, len(providers))
+		for i, p := range providers {
+			out[i] = p.Name()
+		}
+		return strings.Join(out, ",")
+	}
+	return names(s.providers) + ";" + names(s.escalation)
+}
+
+// cached returns the cached answer under key, or nil. The cache is an
+// optimisation: a failing cache is logged and skipped.
+func (s *OCRService) cached(ctx context.Context, uid, key string) *models.OCRCacheEntry {
+	if s.cacheStore == nil || uid == "" {
+		return nil
+	}
+	e, err := s.cacheStore.Get(ctx, uid, key)
+	if err != nil {
+		slog.Warn("ocr: cache get failed", "uid", uid, "err", err)
+		return nil
+	}
+	return e
+}
+
+// cache stores e under key; see cached.
+func (s *OCRService) cache(ctx context.Context, uid, key string, e *models.OCRCacheEntry) {
+	if s.cacheStore == nil || uid == "" {
+		return
+	}
+	if err := s.cacheStore.Put(ctx, uid, key, e); err != nil {
+		slog.Warn("ocr: cache put failed", "uid", uid, "err", err)
+	}
+}
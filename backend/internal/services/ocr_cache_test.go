@@ -0,0 +1,86 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"wattrent/internal/models"
+)
+
+// memoryOCRCache is an in-memory OCRCache.
+type memoryOCRCache struct {
+	mu      sync.Mutex
+	entries map[string]*models.OCRCacheEntry
+}
+
+func (c *memoryOCRCache) Get(_ context.Context, uid, key string) (*models.OCRCacheEntry, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.entries[uid+"/"+key], nil
+}
+
+func (c *memoryOCRCache) Put(_ context.Context, uid, key string, e *models.OCRCacheEntry) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]*models.OCRCacheEntry)
+	}
+	c.entries[uid+"/"+key] = e
+	return nil
+}
+
+func TestOCRService_Cache(t *testing.T) {
+	t.Parallel()
+
+	provider := &stubOCRProvider{name: "gemini/lite", raw: `{"reading":36200,"confidence":0.95}`}
+	svc := NewOCRService(nil, nil, []OCRProvider{provider}, OCROptions{Cache: &memoryOCRCache{}, CacheTTL: time.Hour})
+	req := &models.OCRRequest{ImageBase64: testPhotoBase64, PreviousReading: 36034}
+
+	first, err := svc.Process(context.Background(), "u", req)
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	if first.Cached {
+		t.Error("first call should not be a cache hit")
+	}
+	again, err := svc.Process(context.Background(), "u", req)
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	if !again.Cached || again.Reading != 36200 || again.Model != "gemini/lite" {
+		t.Errorf("second call = %+v, want a cache hit with the same answer", again)
+	}
+	if provider.calls != 1 {
+		t.Errorf("provider calls = %d, want 1", provider.calls)
+	}
+
+	// Another user, or another previous reading, is a miss.
+	if resp, _ := svc.Process(context.Background(), "other", req); resp.Cached {
+		t.Error("cache should not be shared between users")
+	}
+	if resp, _ := svc.Process(context.Background(), "u", &models.OCRRequest{ImageBase64: testPhotoBase64, PreviousReading: 36100}); resp.Cached {
+		t.Error("a different previous reading should miss")
+	}
+}
+
+func TestOCRCacheKey(t *testing.T) {
+	t.Parallel()
+
+	base := ocrCacheKey([]byte("photo"), "gemini/lite;", "electricity-1", 36034, false)
+	if base != ocrCacheKey([]byte("photo"), "gemini/lite;", "electricity-1", 36034, false) {
+		t.Error("same inputs should give the same key")
+	}
+	for name, key := range map[string]string{
+		"image":     ocrCacheKey([]byte("photo2"), "gemini/lite;", "electricity-1", 36034, false),
+		"models":    ocrCacheKey([]byte("photo"), "openai/gpt-4o-mini;", "electricity-1", 36034, false),
+		"prompt":    ocrCacheKey([]byte("photo"), "gemini/lite;", "electricity-2", 36034, false),
+		"previous":  ocrCacheKey([]byte("photo"), "gemini/lite;", "electricity-1", 36035, false),
+		"consensus": ocrCacheKey([]byte("photo"), "gemini/lite;", "electricity-1", 36034, true),
+	} {
+		if key == base {
+			t.Errorf("changing the %s should change the key", name)
+		}
+	}
+}
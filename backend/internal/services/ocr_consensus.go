@@ -0,0 +1,211 @@
+package services
+
+import (
+	"context"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"wattrent/internal/models"
+)
+
+// consensusSamples is how many times a consensus read asks for the reading.
+// Odd, so two samples agreeing on a digit always outvote the third.
+const consensusSamples = 3
+
+// consensusPromptVariants are appended to the prompt, one per sample, so the
+// samples are not the same question asked three times. Part of the meter
+// prompt's version: bump it when these change.
+var consensusPromptVariants = []string{
+	"",
+	"\n\nBefore answering, read the main register one wheel at a time from left to right.",
+	"\n\nBefore answering, count the digit wheels of the main register, then read each of them from right to left.",
+}
+
+// readConsensus reads the photo consensusSamples times, each with its own
+// prompt variant and, when escalation is configured, alternating between the
+// normal and the stronger model, then votes on each digit (see voteDigits).
+// The samples run concurrently. The result's out holds the agreed reading
+// and the weakest digit's agreement as its confidence.
+func (s *OCRService) readConsensus(ctx context.Context, in *OCRInput) (*meterRead, *models.OCRConsensus, error) {
+	chains := [][]OCRProvider{s.providers}
+	if len(s.escalation) > 0 {
+		chains = append(chains, s.escalation)
+	}
+
+	reads := make([]*meterRead, consensusSamples)
+	errs := make([]error, consensusSamples)
+	var wg sync.WaitGroup
+	for i := 0; i < consensusSamples; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sample := *in
+			sample.Prompt += consensusPromptVariants[i%len(consensusPromptVariants)]
+			reads[i], errs[i] = askProviders(ctx, chains[i%len(chains)], &sample)
+		}(i)
+	}
+	wg.Wait()
+
+	read := &meterRead{}
+	consensus := &models.OCRConsensus{}
+	var (
+		digits, raws, names []string
+		confidences         []float64
+		outs                []*ocrModelOutput
+		lastErr             error
+	)
+	for i, r := range reads {
+		read.failed = append(read.failed, r.failed...)
+		if errs[i] != nil {
+			consensus.Failed++
+			lastErr = errs[i]
+			continue
+		}
+		consensus.Samples = append(consensus.Samples, models.OCRCandidate{
+			Model: r.provider, Reading: r.out.Reading, Confidence: r.out.Confidence,
+		})
+		digits = append(digits, sampleDigits(r.out))
+		outs = append(outs, r.out)
+		confidences = append(confidences, r.out.Confidence)
+		raws = append(raws, r.rawText)
+		if !slices.Contains(names, r.provider) {
+			names = append(names, r.provider)
+		}
+	}
+	if len(consensus.Samples) == 0 {
+		return read, nil, lastErr
+	}
+
+	consensus.Digits, consensus.Agreement = voteDigits(digits, confidences, consensusSamples)
+	reading, _ := strconv.ParseFloat(consensus.Digits, 64) // "" (nothing agreed) reads as 0
+	confidence := 0.0
+	if len(consensus.Agreement) > 0 {
+		confidence = 1
+		for _, a := range consensus.Agreement {
+			confidence = math.Min(confidence, a)
+		}
+	}
+	read.out = &ocrModelOutput{
+		Reading:    reading,
+		Confidence: roundHundredth(confidence),
+		Notes:      strings.Join(strings.Split(consensus.Digits, ""), " "),
+	}
+	consensusDigits(read.out, consensus, outs)
+	read.out.Meter = outs[0].Meter
+	for _, o := range outs {
+		if o.Serial != "" {
+			read.out.Serial = o.Serial
+			break
+		}
+	}
+	read.rawText = strings.Join(raws, "\n")
+	read.provider = strings.Join(names, ",")
+	return read, consensus, nil
+}
+
+// consensusDigits gives out the agreed digits, each with its agreement as
+// its confidence. Boxes come from the first sample that read as many digits,
+// so they line up.
+func consensusDigits(out *ocrModelOutput, consensus *models.OCRConsensus, samples []*ocrModelOutput) {
+	var boxed *ocrModelOutput
+	for _, s := range samples {
+		if len(s.Digits) == len(consensus.Digits) {
+			boxed = s
+			break
+		}
+	}
+	for i, d := range consensus.Digits {
+		digit := ocrModelDigit{Value: int(d - '0'), Confidence: consensus.Agreement[i]}
+		if boxed != nil {
+			digit.Box = boxed.Digits[i].Box
+		}
+		out.Digits = append(out.Digits, digit)
+	}
+	if boxed != nil {
+		out.RegisterBox = boxed.RegisterBox
+	}
+}
+
+// sampleDigits is the digit string a sample votes with: its spelledDigits,
+// else the reading's own digits. An unreadable sample (reading 0) abstains
+// with "".
+func sampleDigits(out *ocrModelOutput) string {
+	if out.Reading <= 0 {
+		return ""
+	}
+	if digits := spelledDigits(out); digits != "" {
+		return digits
+	}
+	return strconv.FormatFloat(math.Floor(out.Reading), 'f', 0, 64)
+}
+
+// spelledDigits is the register as out spelled it digit by digit: its
+// per-digit answer or else the digits of its notes, when they spell its
+// reading (keeping the leading zeros the reading drops). "" when neither
+// does.
+func spelledDigits(out *ocrModelOutput) string {
+	if digits := readDigits(out); digits != nil {
+		var b strings.Builder
+		for _, d := range digits {
+			b.WriteByte(byte('0' + d.Value))
+		}
+		return b.String()
+	}
+	var b strings.Builder
+	for _, r := range out.Notes {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	if notes := b.String(); notes != "" && out.Reading > 0 {
+		if v, err := strconv.ParseFloat(notes, 64); err == nil && v == math.Floor(out.Reading) {
+			return notes
+		}
+	}
+	return ""
+}
+
+// voteDigits aligns the samples' digit strings on their last digit (the
+// units wheel) and votes on each position. A position is kept while a
+// majority of all n samples has a digit there, so one sample that dropped or
+// invented a leading digit is outvoted. The most voted digit wins; a tie goes
+// to the digit whose voters are more confident in total, then to the smaller
+// digit (a wheel between two digits reads as the smaller one).
+//
+// agreement[i] is the share of n that voted for digits[i]; samples that
+// failed or abstained count against every position.
+func voteDigits(samples []string, confidences []float64, n int) (digits string, agreement []float64) {
+	var out []byte // units digit first
+	for pos := 0; ; pos++ {
+		var votes [10]int
+		var weight [10]float64
+		present := 0
+		for i, s := range samples {
+			if pos >= len(s) {
+				continue
+			}
+			d := s[len(s)-1-pos] - '0'
+			votes[d]++
+			weight[d] += confidences[i]
+			present++
+		}
+		if present*2 <= n {
+			break
+		}
+		best := 0
+		for d := 1; d < 10; d++ {
+			if votes[d] > votes[best] || (votes[d] == votes[best] && weight[d] > weight[best]) {
+				best = d
+			}
+		}
+		out = append(out, byte('0'+best))
+		agreement = append(agreement, roundHundredth(float64(votes[best])/float64(n)))
+	}
+
+	slices.Reverse(out)
+	slices.Reverse(agreement)
+	return string(out), agreement
+}
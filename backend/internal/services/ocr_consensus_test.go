@@ -0,0 +1,158 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"wattrent/internal/models"
+)
+
+func TestVoteDigits(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		samples       []string
+		confidences   []float64
+		wantDigits    string
+		wantAgreement []float64
+	}{
+		{
+			name:          "unanimous",
+			samples:       []string{"36034", "36034", "36034"},
+			confidences:   []float64{0.9, 0.9, 0.9},
+			wantDigits:    "36034",
+			wantAgreement: []float64{1, 1, 1, 1, 1},
+		},
+		{
+			name:          "one digit outvoted",
+			samples:       []string{"36034", "36084", "36034"},
+			confidences:   []float64{0.9, 0.99, 0.8},
+			wantDigits:    "36034",
+			wantAgreement: []float64{1, 1, 1, 0.67, 1},
+		},
+		{
+			name:          "dropped leading digit is outvoted",
+			samples:       []string{"36034", "6034", "36034"},
+			confidences:   []float64{0.9, 0.9, 0.9},
+			wantDigits:    "36034",
+			wantAgreement: []float64{0.67, 1, 1, 1, 1},
+		},
+		{
+			name:          "invented leading digit is dropped",
+			samples:       []string{"136034", "36034", "36034"},
+			confidences:   []float64{0.9, 0.9, 0.9},
+			wantDigits:    "36034",
+			wantAgreement: []float64{1, 1, 1, 1, 1},
+		},
+		{
+			name:          "three-way split: most confident wins",
+			samples:       []string{"36034", "36035", "36036"},
+			confidences:   []float64{0.5, 0.9, 0.7},
+			wantDigits:    "36035",
+			wantAgreement: []float64{1, 1, 1, 1, 0.33},
+		},
+		{
+			name:          "failed sample counts against every digit",
+			samples:       []string{"842", "842"},
+			confidences:   []float64{0.9, 0.9},
+			wantDigits:    "842",
+			wantAgreement: []float64{0.67, 0.67, 0.67},
+		},
+		{
+			name:        "nothing readable",
+			samples:     []string{"", "", "7"},
+			confidences: []float64{0, 0, 0.4},
+			wantDigits:  "",
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			digits, agreement := voteDigits(tc.samples, tc.confidences, 3)
+			if digits != tc.wantDigits {
+				t.Errorf("digits = %q, want %q", digits, tc.wantDigits)
+			}
+			if len(agreement) != len(tc.wantAgreement) {
+				t.Fatalf("agreement = %v, want %v", agreement, tc.wantAgreement)
+			}
+			for i := range agreement {
+				if agreement[i] != tc.wantAgreement[i] {
+					t.Errorf("agreement = %v, want %v", agreement, tc.wantAgreement)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestSampleDigits(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		out  ocrModelOutput
+		want string
+	}{
+		{ocrModelOutput{Reading: 842, Notes: "0 0 8 4 2"}, "00842"},
+		{ocrModelOutput{Reading: 842, Notes: "digits: 0 0 8 4 7"}, "842"}, // notes disagree
+		{ocrModelOutput{Reading: 36034.5}, "36034"},
+		{ocrModelOutput{Reading: 0, Notes: "unreadable"}, ""},
+	}
+	for _, tc := range tests {
+		if got := sampleDigits(&tc.out); got != tc.want {
+			t.Errorf("sampleDigits(%+v) = %q, want %q", tc.out, got, tc.want)
+		}
+	}
+}
+
+// promptEchoProvider answers per prompt variant, so each sample can be told
+// apart.
+type promptEchoProvider struct {
+	name    string
+	answers map[string]string // prompt -> raw
+	mu      sync.Mutex
+	prompts []string
+}
+
+func (p *promptEchoProvider) Name() string { return p.name }
+
+func (p *promptEchoProvider) ReadMeter(_ context.Context, in *OCRInput) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.prompts = append(p.prompts, in.Prompt)
+	return p.answers[in.Prompt], nil
+}
+
+func TestOCRService_Consensus(t *testing.T) {
+	t.Parallel()
+
+	_, elec, _ := lookupMeterPrompt(models.MeterTypeElectricity)
+	base := buildOCRPrompt(elec, 0)
+	lite := &promptEchoProvider{name: "gemini/lite", answers: map[string]string{
+		base + consensusPromptVariants[0]: `{"reading":36034,"confidence":0.95,"notes":"3 6 0 3 4"}`,
+		base + consensusPromptVariants[2]: `{"reading":36034,"confidence":0.9,"notes":"3 6 0 3 4"}`,
+	}}
+	flash := &promptEchoProvider{name: "gemini/flash", answers: map[string]string{
+		base + consensusPromptVariants[1]: `{"reading":36084,"confidence":0.99,"notes":"3 6 0 8 4"}`,
+	}}
+	svc := NewOCRService(nil, nil, []OCRProvider{lite}, OCROptions{Escalation: []OCRProvider{flash}})
+
+	resp, err := svc.Process(context.Background(), "u", &models.OCRRequest{ImageBase64: testPhotoBase64, Consensus: true})
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	if resp.Reading != 36034 || resp.Confidence != 0.67 {
+		t.Errorf("reading %v confidence %v, want 36034 at 0.67", resp.Reading, resp.Confidence)
+	}
+	if resp.Model != "gemini/lite,gemini/flash" && resp.Model != "gemini/flash,gemini/lite" {
+		t.Errorf("Model = %q, want both models", resp.Model)
+	}
+	if resp.Consensus == nil || len(resp.Consensus.Samples) != 3 || resp.Consensus.Digits != "36034" {
+		t.Fatalf("Consensus = %+v", resp.Consensus)
+	}
+	if len(lite.prompts) != 2 || len(flash.prompts) != 1 {
+		t.Errorf("samples per model = %d / %d, want 2 / 1", len(lite.prompts), len(flash.prompts))
+	}
+}
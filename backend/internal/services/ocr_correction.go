@@ -0,0 +1,295 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+
+	"wattrent/internal/models"
+)
+
+// OCRCorrections is what OCRService needs of the users' corrected readings:
+// the latest ones of a meter, and their photos, to show the model as
+// labelled examples.
+type OCRCorrections interface {
+	// Latest returns up to n of the newest unexpired corrections of the
+	// user's meter of type t, newest first.
+	Latest(ctx context.Context, uid string, t models.MeterType, n int) ([]*models.OCRCorrection, error)
+	// Photo returns the image bytes of c's photo.
+	Photo(ctx context.Context, c *models.OCRCorrection) ([]byte, error)
+}
+
+// OCRCorrectionService operates on /users/{uid}/ocrCorrections. BillService
+// writes the corrections (see correctionFor); this lists and deletes them
+// and hands them to OCRService.
+type OCRCorrectionService struct {
+	fs      *firestore.Client
+	storage *StorageService
+}
+
+func NewOCRCorrectionService(fs *firestore.Client, storage *StorageService) *OCRCorrectionService {
+	return &OCRCorrectionService{fs: fs, storage: storage}
+}
+
+// maxOCRCorrections caps the corrections kept per meter; older ones are
+// dropped by trimOCRCorrections. maxOCRExamples caps the ones sent with a
+// read: each is another image to pay for.
+const (
+	maxOCRCorrections = 20
+	maxOCRExamples    = 5
+)
+
+// ocrCorrectionRetention is how long a correction is kept. A meter does not
+// change, but its photos are the user's; a year is enough to learn from.
+const ocrCorrectionRetention = 365 * 24 * time.Hour
+
+func ocrCorrectionsCol(fs *firestore.Client, uid string) *firestore.CollectionRef {
+	return fs.Collection("users").Doc(uid).Collection("ocrCorrections")
+}
+
+// List returns every correction of the user, newest first.
+func (s *OCRCorrectionService) List(ctx context.Context, uid string) ([]*models.OCRCorrection, error) {
+	snaps, err := ocrCorrectionsCol(s.fs, uid).OrderBy("createdAt", firestore.Desc).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*models.OCRCorrection, 0, len(snaps))
+	for _, snap := range snaps {
+		c, err := docToCorrection(snap)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, nil
+}
+
+func (s *OCRCorrectionService) Latest(ctx context.Context, uid string, t models.MeterType, n int) ([]*models.OCRCorrection, error) {
+	snaps, err := ocrCorrectionsCol(s.fs, uid).
+		Where("meterType", "==", string(t)).
+		OrderBy("createdAt", firestore.Desc).
+		Limit(n).
+		Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	var out []*models.OCRCorrection
+	for _, snap := range snaps {
+		c, err := docToCorrection(snap)
+		if err != nil {
+			return nil, err
+		}
+		// The TTL policy deletes expired corrections within a day or so.
+		if now.Before(c.ExpiresAt) {
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+
+func (s *OCRCorrectionService) Photo(ctx context.Context, c *models.OCRCorrection) ([]byte, error) {
+	data, _, err := s.storage.DownloadObject(ctx, c.ImageURL, maxOCRImageBytes)
+	return data, err
+}
+
+// Delete forgets one correction; deleting one that does not exist is fine.
+func (s *OCRCorrectionService) Delete(ctx context.Context, uid, attemptID string) error {
+	_, err := ocrCorrectionsCol(s.fs, uid).Doc(attemptID).Delete(ctx)
+	return err
+}
+
+// DeleteAll forgets every correction of the user.
+func (s *OCRCorrectionService) DeleteAll(ctx context.Context, uid string) error {
+	return deleteOCRCorrections(ctx, s.fs, uid)
+}
+
+func deleteOCRCorrections(ctx context.Context, fs *firestore.Client, uid string) error {
+	refs := ocrCorrectionsCol(fs, uid).DocumentRefs(ctx)
+	for {
+		ref, err := refs.Next()
+		if errors.Is(err, iterator.Done) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := ref.Delete(ctx); err != nil {
+			return err
+		}
+	}
+}
+
+// trimOCRCorrections drops the corrections of the user's meter of type t
+// beyond the newest maxOCRCorrections.
+func trimOCRCorrections(ctx context.Context, fs *firestore.Client, uid string, t models.MeterType) error {
+	snaps, err := ocrCorrectionsCol(fs, uid).
+		Where("meterType", "==", string(t)).
+		OrderBy("createdAt", firestore.Desc).
+		Offset(maxOCRCorrections).
+		Documents(ctx).GetAll()
+	if err != nil {
+		return err
+	}
+	for _, snap := range snaps {
+		if _, err := snap.Ref.Delete(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// correctionFor returns the correction uid keeps when they settled attempt a
+// on finalReading, or nil: only a corrected single-meter reading of one of
+// their own photos in Cloud Storage makes an example (a base64 photo was
+// never stored), and never one of a photo flagged as another meter.
+func correctionFor(uid string, a *models.OCRAttempt, finalReading float64, now time.Time) *models.OCRCorrection {
+	if attemptOutcome(a.Reading, finalReading) != models.OCRAttemptCorrected ||
+		a.Error != "" || len(a.Meters) > 0 ||
+		slices.Contains(a.Warnings, models.OCRWarningWrongMeter) {
+		return nil
+	}
+	if _, err := userObject(uid, a.ImageURL); err != nil {
+		return nil
+	}
+	return &models.OCRCorrection{
+		MeterType:        a.MeterType,
+		ImageURL:         a.ImageURL,
+		ModelReading:     a.Reading,
+		CorrectedReading: finalReading,
+		PromptVersion:    a.PromptVersion,
+		CreatedAt:        now,
+		ExpiresAt:        now.Add(ocrCorrectionRetention),
+	}
+}
+
+// txRecordCorrection keeps attempt id's correction, if it makes one and the
+// user lets us. It returns whether it did, so the caller can trim the meter's
+// corrections once the transaction is through.
+func txRecordCorrection(tx *firestore.Transaction, fs *firestore.Client, uid, id string, a *models.OCRAttempt, finalReading float64, settings *models.UserSettings) (bool, error) {
+	if !settings.OCRLearnFromCorrections {
+		return false, nil
+	}
+	c := correctionFor(uid, a, finalReading, time.Now().UTC())
+	if c == nil {
+		return false, nil
+	}
+	return true, tx.Set(ocrCorrectionsCol(fs, uid).Doc(id), c)
+}
+
+// trimOCRCorrectionsQuietly runs trimOCRCorrections after a bill is saved; a
+// failure leaves a few corrections over the cap until the next one.
+func trimOCRCorrectionsQuietly(ctx context.Context, fs *firestore.Client, uid string, t models.MeterType) {
+	if err := trimOCRCorrections(ctx, fs, uid, t); err != nil {
+		slog.Warn("ocr corrections trim failed", "uid", uid, "meterType", t, "err", err)
+	}
+}
+
+func docToCorrection(snap *firestore.DocumentSnapshot) (*models.OCRCorrection, error) {
+	var c models.OCRCorrection
+	if err := snap.DataTo(&c); err != nil {
+		return nil, err
+	}
+	c.AttemptID = snap.Ref.ID
+	return &c, nil
+}
+
+// ocrPromptExamples tells the model about the example photos sent ahead of
+// the one to read (OCRInput.Examples); %d is their number.
+const ocrPromptExamples = `
+
+Before the photo to read you get %d earlier photo(s) of this same meter, each labelled with its correct reading; an earlier read of each one got it wrong. Use them to learn how this meter's register and digits look and where they are. Never copy their readings: the reading is only ever from the LAST photo.`
+
+// ocrExamplesPromptVersion is appended to the prompt version of a read sent
+// with examples; bump it when ocrPromptExamples changes.
+const ocrExamplesPromptVersion = "examples-1"
+
+// withCorrections returns mp with the user's corrections of this meter to
+// show the model as examples (see OCRService.loadExamples).
+func (mp meterPrompt) withCorrections(cs []*models.OCRCorrection) meterPrompt {
+	if len(cs) > 0 {
+		mp.corrections = cs
+		mp.version += "+" + ocrExamplesPromptVersion
+	}
+	return mp
+}
+
+// correctionsKey identifies mp's examples in the cache key: a read with
+// other examples may well read otherwise.
+func (mp meterPrompt) correctionsKey() string {
+	ids := make([]string, len(mp.corrections))
+	for i, c := range mp.corrections {
+		ids[i] = c.AttemptID
+	}
+	return strings.Join(ids, ",")
+}
+
+// latestCorrections returns the corrections of the user's meter to send as
+// examples, or none: a failure here only costs the read its examples.
+func (s *OCRService) latestCorrections(ctx context.Context, uid string, t models.MeterType) []*models.OCRCorrection {
+	if s.corrections == nil || s.fewShot <= 0 || uid == "" {
+		return nil
+	}
+	cs, err := s.corrections.Latest(ctx, uid, t, s.fewShot)
+	if err != nil {
+		slog.Warn("ocr corrections lookup failed", "uid", uid, "meterType", t, "err", err)
+		return nil
+	}
+	return cs
+}
+
+// loadExamples fetches and preprocesses the photos of cs like the photo to
+// read. A photo that is gone (its bill was purged), unreadable or not uid's
+// own is skipped.
+func (s *OCRService) loadExamples(ctx context.Context, uid string, cs []*models.OCRCorrection) []OCRExample {
+	var out []OCRExample
+	for _, c := range cs {
+		e, err := s.loadExample(ctx, uid, c)
+		if err != nil {
+			slog.Warn("ocr example skipped", "attempt", c.AttemptID, "err", err)
+			continue
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+func (s *OCRService) loadExample(ctx context.Context, uid string, c *models.OCRCorrection) (OCRExample, error) {
+	// Corrections stored before correctionFor checked the photo's owner.
+	if _, err := userObject(uid, c.ImageURL); err != nil {
+		return OCRExample{}, err
+	}
+	data, err := s.corrections.Photo(ctx, c)
+	if err != nil {
+		return OCRExample{}, err
+	}
+	mime, err := checkImageType(data, "")
+	if err != nil {
+		return OCRExample{}, err
+	}
+	if mime == "image/heic" {
+		// Not worth a converter run per example.
+		return OCRExample{}, errors.New("HEIC example photo")
+	}
+	if data, mime, _, err = s.pipeline.process(data, mime); err != nil {
+		return OCRExample{}, err
+	}
+	return OCRExample{Image: data, MIMEType: mime, Reading: c.CorrectedReading}, nil
+}
+
+// examplesLabel is the text sent ahead of example i (from 0) of a read.
+func examplesLabel(i int, e OCRExample) string {
+	return "Example " + strconv.Itoa(i+1) + ": an earlier photo of this meter. Its correct reading is " +
+		strconv.FormatFloat(e.Reading, 'f', -1, 64) + "."
+}
+
+// ocrPhotoToReadLabel is the text sent ahead of the photo to read when
+// examples came before it.
+const ocrPhotoToReadLabel = "The photo to read:"
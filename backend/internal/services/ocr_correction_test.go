@@ -0,0 +1,178 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"wattrent/internal/middleware"
+	"wattrent/internal/models"
+)
+
+// memoryOCRCorrections is an in-memory OCRCorrections; a correction without
+// a photo in photos fails to load, like one whose bill was purged.
+type memoryOCRCorrections struct {
+	latest []*models.OCRCorrection
+	photos map[string][]byte
+}
+
+func (m *memoryOCRCorrections) Latest(_ context.Context, _ string, t models.MeterType, n int) ([]*models.OCRCorrection, error) {
+	var out []*models.OCRCorrection
+	for _, c := range m.latest {
+		if c.MeterType == t && len(out) < n {
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+
+func (m *memoryOCRCorrections) Photo(_ context.Context, c *models.OCRCorrection) ([]byte, error) {
+	if data, ok := m.photos[c.ImageURL]; ok {
+		return data, nil
+	}
+	return nil, errors.New("object not found")
+}
+
+func TestCorrectionFor(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	base := func() *models.OCRAttempt {
+		return &models.OCRAttempt{MeterType: models.MeterTypeWater, ImageURL: "gs://b/users/u1/bills/b1.jpg", Reading: 842, PromptVersion: "water-2"}
+	}
+	tests := []struct {
+		name   string
+		modify func(a *models.OCRAttempt)
+		final  float64
+		want   bool
+	}{
+		{name: "corrected", final: 824, want: true},
+		{name: "accepted", final: 842},
+		{name: "inline photo", modify: func(a *models.OCRAttempt) { a.ImageURL = "" }, final: 824},
+		{name: "failed attempt", modify: func(a *models.OCRAttempt) { a.Error = "errors.ocr.upstream_failed" }, final: 824},
+		{name: "bank", modify: func(a *models.OCRAttempt) { a.Meters = []models.OCRBankMeter{{Position: 1}} }, final: 824},
+		{name: "another meter", modify: func(a *models.OCRAttempt) { a.Warnings = []models.OCRWarning{models.OCRWarningWrongMeter} }, final: 824},
+		{name: "another user's photo", modify: func(a *models.OCRAttempt) { a.ImageURL = "gs://b/users/u2/bills/b1.jpg" }, final: 824},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			a := base()
+			if tc.modify != nil {
+				tc.modify(a)
+			}
+			c := correctionFor("u1", a, tc.final, now)
+			if !tc.want {
+				if c != nil {
+					t.Errorf("correction = %+v, want none", c)
+				}
+				return
+			}
+			want := models.OCRCorrection{MeterType: models.MeterTypeWater, ImageURL: a.ImageURL, ModelReading: 842,
+				CorrectedReading: 824, PromptVersion: "water-2", CreatedAt: now, ExpiresAt: now.Add(ocrCorrectionRetention)}
+			if c == nil || *c != want {
+				t.Errorf("correction = %+v, want %+v", c, want)
+			}
+		})
+	}
+}
+
+func TestOCRService_FewShotExamples(t *testing.T) {
+	t.Parallel()
+
+	photo := encodeTestJPEG(checkerboard(64, 64, 8, 40, 200), nil)
+	corrections := func() *memoryOCRCorrections {
+		return &memoryOCRCorrections{
+			latest: []*models.OCRCorrection{
+				{AttemptID: "a2", MeterType: models.MeterTypeElectricity, ImageURL: "gs://b/users/u/bills/2.jpg", CorrectedReading: 36034},
+				{AttemptID: "a1", MeterType: models.MeterTypeElectricity, ImageURL: "gs://b/users/u/bills/1.jpg", CorrectedReading: 35920},
+				{AttemptID: "w1", MeterType: models.MeterTypeWater, ImageURL: "gs://b/users/u/bills/w.jpg", CorrectedReading: 842},
+				{AttemptID: "x1", MeterType: models.MeterTypeElectricity, ImageURL: "gs://b/users/other/bills/x.jpg", CorrectedReading: 41200},
+			},
+			photos: map[string][]byte{"gs://b/users/u/bills/2.jpg": photo, "gs://b/users/u/bills/w.jpg": photo, "gs://b/users/other/bills/x.jpg": photo},
+		}
+	}
+	answer := `{"reading":36120,"confidence":0.9}`
+	req := func() *models.OCRRequest { return &models.OCRRequest{ImageBase64: testPhotoBase64} }
+
+	t.Run("sends the meter's corrections that load", func(t *testing.T) {
+		t.Parallel()
+		p := &stubOCRProvider{name: "gemini/flash-lite", raw: answer}
+		svc := NewOCRService(nil, nil, []OCRProvider{p}, OCROptions{Corrections: corrections(), FewShotExamples: 3})
+		resp, err := svc.Process(t.Context(), "u", req())
+		if err != nil {
+			t.Fatal(err)
+		}
+		// a1's photo is gone, the water correction is another meter and
+		// x1's photo is another user's.
+		if len(p.examples) != 1 || p.examples[0].Reading != 36034 || p.examples[0].MIMEType != "image/jpeg" {
+			t.Errorf("examples = %+v, want a2's photo only", p.examples)
+		}
+		if !strings.Contains(p.prompt, "1 earlier photo(s) of this same meter") {
+			t.Errorf("prompt does not introduce the examples: %q", p.prompt)
+		}
+		if !strings.HasSuffix(resp.PromptVersion, "+"+ocrExamplesPromptVersion) {
+			t.Errorf("prompt version = %q, want the examples version", resp.PromptVersion)
+		}
+	})
+
+	t.Run("capped by FewShotExamples", func(t *testing.T) {
+		t.Parallel()
+		cs := corrections()
+		cs.photos["gs://b/users/u/bills/1.jpg"] = photo
+		p := &stubOCRProvider{name: "gemini/flash-lite", raw: answer}
+		svc := NewOCRService(nil, nil, []OCRProvider{p}, OCROptions{Corrections: cs, FewShotExamples: 1})
+		if _, err := svc.Process(t.Context(), "u", req()); err != nil {
+			t.Fatal(err)
+		}
+		if len(p.examples) != 1 || p.examples[0].Reading != 36034 {
+			t.Errorf("examples = %+v, want the newest only", p.examples)
+		}
+	})
+
+	t.Run("off", func(t *testing.T) {
+		t.Parallel()
+		p := &stubOCRProvider{name: "gemini/flash-lite", raw: answer}
+		svc := NewOCRService(nil, nil, []OCRProvider{p}, OCROptions{Corrections: corrections()})
+		resp, err := svc.Process(t.Context(), "u", req())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(p.examples) != 0 || strings.Contains(resp.PromptVersion, ocrExamplesPromptVersion) {
+			t.Errorf("examples = %d, version %q; want none with FewShotExamples 0", len(p.examples), resp.PromptVersion)
+		}
+	})
+
+	t.Run("not for a bank", func(t *testing.T) {
+		t.Parallel()
+		p := &stubOCRProvider{name: "gemini/flash-lite", raw: `{"reading":0,"confidence":0,"meters":[{"reading":1204,"confidence":0.9}]}`}
+		svc := NewOCRService(nil, nil, []OCRProvider{p}, OCROptions{Corrections: corrections(), FewShotExamples: 3})
+		if _, err := svc.Process(t.Context(), "u", &models.OCRRequest{ImageBase64: testPhotoBase64, Bank: true}); err != nil {
+			t.Fatal(err)
+		}
+		if len(p.examples) != 0 {
+			t.Errorf("examples = %d, want none for a bank photo", len(p.examples))
+		}
+	})
+}
+
+func TestOCRService_ForeignPhotoURL(t *testing.T) {
+	t.Parallel()
+
+	p := &stubOCRProvider{name: "gemini/flash-lite", raw: `{"reading":36120,"confidence":0.9}`}
+	svc := NewOCRService(nil, nil, []OCRProvider{p})
+	for _, url := range []string{"gs://b/users/other/bills/b1.jpg", "https://example.com/users/u/bills/b1.jpg"} {
+		_, err := svc.Process(t.Context(), "u", &models.OCRRequest{ImageURL: url})
+		var appErr *middleware.AppError
+		if !errors.As(err, &appErr) || appErr.HTTPStatus != 400 || appErr.Key != "errors.ocr.invalid_image_url" {
+			t.Errorf("%s: err = %v, want 400 errors.ocr.invalid_image_url", url, err)
+		}
+	}
+	if p.calls != 0 {
+		t.Errorf("provider called %d times, want none", p.calls)
+	}
+}
@@ -0,0 +1,288 @@
+package services
+
+import (
+	"context"
+	"hash/fnv"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"wattrent/internal/middleware"
+	"wattrent/internal/models"
+)
+
+// OCRPrompts is what OCRService needs of the prompt experiments: the
+// template a user's reads of a meter type use instead of the built-in one.
+type OCRPrompts interface {
+	// Variant returns the template uid is assigned to for meter type t, or
+	// nil for the built-in prompt.
+	Variant(ctx context.Context, uid string, t models.MeterType) (*models.OCRPromptTemplate, error)
+}
+
+// promptConfigTTL is how long FirestoreOCRPrompts keeps an experiment and its
+// templates before reading them again: a change takes effect within it,
+// without a redeploy, and a read costs no Firestore lookup.
+const promptConfigTTL = time.Minute
+
+// FirestoreOCRPrompts reads the experiments from /ocrExperiments/{meterType}
+// and their templates from /ocrPrompts/{version}.
+type FirestoreOCRPrompts struct {
+	fs *firestore.Client
+
+	mu      sync.Mutex
+	configs map[models.MeterType]*promptConfig
+}
+
+// promptConfig is a meter type's experiment with the templates of its
+// usable variants, as loaded at loadedAt.
+type promptConfig struct {
+	experiment *models.OCRExperiment
+	templates  map[string]*models.OCRPromptTemplate
+	loadedAt   time.Time
+}
+
+func NewFirestoreOCRPrompts(fs *firestore.Client) *FirestoreOCRPrompts {
+	return &FirestoreOCRPrompts{fs: fs, configs: map[models.MeterType]*promptConfig{}}
+}
+
+func (p *FirestoreOCRPrompts) Variant(ctx context.Context, uid string, t models.MeterType) (*models.OCRPromptTemplate, error) {
+	cfg, err := p.config(ctx, t)
+	if err != nil || cfg.experiment == nil {
+		return nil, err
+	}
+	return cfg.templates[assignVariant(cfg.experiment, uid)], nil
+}
+
+func (p *FirestoreOCRPrompts) config(ctx context.Context, t models.MeterType) (*promptConfig, error) {
+	p.mu.Lock()
+	cfg := p.configs[t]
+	p.mu.Unlock()
+	if cfg != nil && time.Since(cfg.loadedAt) < promptConfigTTL {
+		return cfg, nil
+	}
+	cfg, err := p.load(ctx, t)
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	p.configs[t] = cfg
+	p.mu.Unlock()
+	return cfg, nil
+}
+
+func (p *FirestoreOCRPrompts) load(ctx context.Context, t models.MeterType) (*promptConfig, error) {
+	cfg := &promptConfig{templates: map[string]*models.OCRPromptTemplate{}, loadedAt: time.Now()}
+	snap, err := p.fs.Collection("ocrExperiments").Doc(string(t)).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var e models.OCRExperiment
+	if err := snap.DataTo(&e); err != nil {
+		return nil, err
+	}
+	if !validExperiment(&e) {
+		slog.Warn("ocr experiment ignored: variant shares must be 0-100 and add up to 100 at most", "meterType", t, "name", e.Name)
+		return cfg, nil
+	}
+	cfg.experiment = &e
+	for _, v := range e.Variants {
+		snap, err := p.fs.Collection("ocrPrompts").Doc(v.Version).Get(ctx)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return nil, err
+		}
+		var tpl models.OCRPromptTemplate
+		if err == nil {
+			if err := snap.DataTo(&tpl); err != nil {
+				return nil, err
+			}
+		}
+		tpl.Version = v.Version
+		if !validTemplate(&tpl, t) {
+			// Its users keep the built-in prompt.
+			slog.Warn("ocr prompt variant ignored: no template for the meter type", "meterType", t, "version", v.Version)
+			continue
+		}
+		cfg.templates[v.Version] = &tpl
+	}
+	return cfg, nil
+}
+
+// validExperiment reports whether e's shares make sense: each 0-100, and no
+// more than 100 together.
+func validExperiment(e *models.OCRExperiment) bool {
+	total := 0
+	for _, v := range e.Variants {
+		if v.Percent < 0 || v.Percent > 100 || strings.TrimSpace(v.Version) == "" {
+			return false
+		}
+		total += v.Percent
+	}
+	return total <= 100
+}
+
+// validTemplate reports whether tpl can stand in for meter type t's built-in
+// prompt. Its version must not pass for a built-in one, or the report would
+// mix them up.
+func validTemplate(tpl *models.OCRPromptTemplate, t models.MeterType) bool {
+	if tpl.MeterType != t || strings.TrimSpace(tpl.Base) == "" || strings.Contains(tpl.Version, "+") {
+		return false
+	}
+	for _, mp := range meterPrompts {
+		if mp.version == tpl.Version {
+			return false
+		}
+	}
+	return true
+}
+
+// promptBucket puts uid in one of 100 buckets, the same one on every call
+// for the same experiment name.
+func promptBucket(name, uid string) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(name + "\x00" + uid))
+	return int(h.Sum32() % 100)
+}
+
+// assignVariant returns the version of e's variant uid falls in, or "" for
+// the built-in prompt: variants take the buckets in order, Percent each.
+func assignVariant(e *models.OCRExperiment, uid string) string {
+	bucket := promptBucket(e.Name, uid)
+	for _, v := range e.Variants {
+		if bucket < v.Percent {
+			return v.Version
+		}
+		bucket -= v.Percent
+	}
+	return ""
+}
+
+// withTemplate returns mp with tpl's instructions and version, or mp itself
+// for a nil tpl.
+func (mp meterPrompt) withTemplate(tpl *models.OCRPromptTemplate) meterPrompt {
+	if tpl != nil {
+		mp.base, mp.version = tpl.Base, tpl.Version
+	}
+	return mp
+}
+
+// promptTemplate returns the template of the experiment variant uid is in,
+// or nil: a failure here only costs the read its variant.
+func (s *OCRService) promptTemplate(ctx context.Context, uid string, t models.MeterType) *models.OCRPromptTemplate {
+	if s.prompts == nil || uid == "" {
+		return nil
+	}
+	tpl, err := s.prompts.Variant(ctx, uid, t)
+	if err != nil {
+		slog.Warn("ocr prompt experiment lookup failed", "uid", uid, "meterType", t, "err", err)
+		return nil
+	}
+	return tpl
+}
+
+// Report windows, in days, and the most attempts one report reads.
+const (
+	defaultOCRReportDays = 30
+	maxOCRReportDays     = 365
+	maxOCRReportAttempts = 20000
+)
+
+// Report compares acceptance and correction rates of the prompt variants of
+// meter type t over the last days days, across every user. Only the uids in
+// OCROptions.ReportUIDs may see it.
+func (s *OCRService) Report(ctx context.Context, uid string, t models.MeterType, days int) (*models.OCRReport, error) {
+	if !slices.Contains(s.reportUIDs, uid) {
+		return nil, &middleware.AppError{HTTPStatus: 403, Key: "errors.ocr.report_forbidden"}
+	}
+	t, _, ok := lookupMeterPrompt(t)
+	if !ok {
+		return nil, &middleware.AppError{HTTPStatus: 400, Key: "errors.ocr.invalid_meter_type"}
+	}
+	if days <= 0 {
+		days = defaultOCRReportDays
+	}
+	days = min(days, maxOCRReportDays)
+	since := time.Now().UTC().AddDate(0, 0, -days)
+
+	snaps, err := s.fs.CollectionGroup("ocrAttempts").
+		Where("meterType", "==", string(t)).
+		Where("createdAt", ">=", since).
+		OrderBy("createdAt", firestore.Desc).
+		Limit(maxOCRReportAttempts).
+		Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	attempts := make([]*models.OCRAttempt, 0, len(snaps))
+	for _, snap := range snaps {
+		var a models.OCRAttempt
+		if err := snap.DataTo(&a); err != nil {
+			return nil, err
+		}
+		attempts = append(attempts, &a)
+	}
+	return &models.OCRReport{
+		MeterType: t,
+		Since:     since,
+		Truncated: len(snaps) == maxOCRReportAttempts,
+		Variants:  summarizeVariants(attempts),
+	}, nil
+}
+
+// summarizeVariants tallies attempts by prompt variant, in version order.
+func summarizeVariants(attempts []*models.OCRAttempt) []models.OCRVariantStats {
+	type tally struct {
+		stats      models.OCRVariantStats
+		confidence float64
+		latency    int64
+		succeeded  int
+	}
+	byVersion := map[string]*tally{}
+	for _, a := range attempts {
+		version, _, _ := strings.Cut(a.PromptVersion, "+")
+		t := byVersion[version]
+		if t == nil {
+			t = &tally{stats: models.OCRVariantStats{PromptVersion: version}}
+			byVersion[version] = t
+		}
+		t.stats.Attempts++
+		t.latency += a.LatencyMs
+		if a.Error != "" {
+			t.stats.Failed++
+			continue
+		}
+		t.succeeded++
+		t.confidence += a.Confidence
+		switch a.Outcome {
+		case models.OCRAttemptAccepted:
+			t.stats.Accepted++
+		case models.OCRAttemptCorrected:
+			t.stats.Corrected++
+		}
+	}
+
+	out := make([]models.OCRVariantStats, 0, len(byVersion))
+	for _, t := range byVersion {
+		st := t.stats
+		st.Resolved = st.Accepted + st.Corrected
+		if st.Resolved > 0 {
+			st.AcceptanceRate = roundHundredth(float64(st.Accepted) / float64(st.Resolved))
+			st.CorrectionRate = roundHundredth(float64(st.Corrected) / float64(st.Resolved))
+		}
+		if t.succeeded > 0 {
+			st.AvgConfidence = roundHundredth(t.confidence / float64(t.succeeded))
+		}
+		st.AvgLatencyMs = t.latency / int64(st.Attempts)
+		out = append(out, st)
+	}
+	slices.SortFunc(out, func(a, b models.OCRVariantStats) int { return strings.Compare(a.PromptVersion, b.PromptVersion) })
+	return out
+}
@@ -0,0 +1,220 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"wattrent/internal/middleware"
+	"wattrent/internal/models"
+)
+
+// stubOCRPrompts hands every user the same template, or fails.
+type stubOCRPrompts struct {
+	tpl *models.OCRPromptTemplate
+	err error
+}
+
+func (s stubOCRPrompts) Variant(context.Context, string, models.MeterType) (*models.OCRPromptTemplate, error) {
+	return s.tpl, s.err
+}
+
+func TestAssignVariant(t *testing.T) {
+	t.Parallel()
+
+	e := &models.OCRExperiment{Name: "water-wording", Variants: []models.OCRPromptVariant{
+		{Version: "water-3a", Percent: 20},
+		{Version: "water-3b", Percent: 30},
+	}}
+	counts := map[string]int{}
+	for i := range 2000 {
+		uid := fmt.Sprintf("user-%d", i)
+		v := assignVariant(e, uid)
+		if again := assignVariant(e, uid); again != v {
+			t.Fatalf("%s got %q, then %q", uid, v, again)
+		}
+		counts[v]++
+	}
+	// 20%, 30% and the built-in 50% of 2000, give or take.
+	for v, want := range map[string]int{"water-3a": 400, "water-3b": 600, "": 1000} {
+		if got := counts[v]; got < want*3/4 || got > want*5/4 {
+			t.Errorf("variant %q got %d users, want about %d", v, got, want)
+		}
+	}
+
+	if v := assignVariant(&models.OCRExperiment{Name: "off"}, "user-1"); v != "" {
+		t.Errorf("no variants: got %q, want the built-in prompt", v)
+	}
+	all := &models.OCRExperiment{Name: "all", Variants: []models.OCRPromptVariant{{Version: "gas-3", Percent: 100}}}
+	if v := assignVariant(all, "user-1"); v != "gas-3" {
+		t.Errorf("100%%: got %q, want gas-3", v)
+	}
+}
+
+func TestValidExperiment(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		variants []models.OCRPromptVariant
+		want     bool
+	}{
+		{name: "none", want: true},
+		{name: "split", variants: []models.OCRPromptVariant{{Version: "a", Percent: 50}, {Version: "b", Percent: 50}}, want: true},
+		{name: "over 100", variants: []models.OCRPromptVariant{{Version: "a", Percent: 60}, {Version: "b", Percent: 50}}},
+		{name: "negative", variants: []models.OCRPromptVariant{{Version: "a", Percent: -10}}},
+		{name: "no version", variants: []models.OCRPromptVariant{{Version: " ", Percent: 10}}},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			if got := validExperiment(&models.OCRExperiment{Name: "x", Variants: tc.variants}); got != tc.want {
+				t.Errorf("validExperiment = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestValidTemplate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		tpl  models.OCRPromptTemplate
+		want bool
+	}{
+		{name: "ok", tpl: models.OCRPromptTemplate{Version: "water-3a", MeterType: models.MeterTypeWater, Base: "Read the meter."}, want: true},
+		{name: "another meter type", tpl: models.OCRPromptTemplate{Version: "gas-3", MeterType: models.MeterTypeGas, Base: "Read the meter."}},
+		{name: "missing", tpl: models.OCRPromptTemplate{Version: "water-3a"}},
+		{name: "empty base", tpl: models.OCRPromptTemplate{Version: "water-3a", MeterType: models.MeterTypeWater, Base: " "}},
+		{name: "built-in version", tpl: models.OCRPromptTemplate{Version: "water-2", MeterType: models.MeterTypeWater, Base: "Read the meter."}},
+		{name: "suffix", tpl: models.OCRPromptTemplate{Version: "water-3+bank-1", MeterType: models.MeterTypeWater, Base: "Read the meter."}},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			if got := validTemplate(&tc.tpl, models.MeterTypeWater); got != tc.want {
+				t.Errorf("validTemplate = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSummarizeVariants(t *testing.T) {
+	t.Parallel()
+
+	attempts := []*models.OCRAttempt{
+		{PromptVersion: "water-2", Confidence: 0.9, LatencyMs: 1000, Outcome: models.OCRAttemptAccepted},
+		{PromptVersion: "water-2+examples-1", Confidence: 0.7, LatencyMs: 2000, Outcome: models.OCRAttemptCorrected},
+		{PromptVersion: "water-2", Confidence: 0.8, LatencyMs: 1200, Outcome: models.OCRAttemptAccepted},
+		{PromptVersion: "water-2", LatencyMs: 400, Error: "errors.ocr.upstream_failed"},
+		{PromptVersion: "water-3a+bank-1", Confidence: 0.95, LatencyMs: 800},
+	}
+	got := summarizeVariants(attempts)
+	want := []models.OCRVariantStats{
+		{PromptVersion: "water-2", Attempts: 4, Failed: 1, Resolved: 3, Accepted: 2, Corrected: 1,
+			AcceptanceRate: 0.67, CorrectionRate: 0.33, AvgConfidence: 0.8, AvgLatencyMs: 1150},
+		{PromptVersion: "water-3a", Attempts: 1, AvgConfidence: 0.95, AvgLatencyMs: 800},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("variant %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	if got := summarizeVariants(nil); len(got) != 0 {
+		t.Errorf("no attempts: got %+v, want none", got)
+	}
+}
+
+func TestOCRService_PromptVariant(t *testing.T) {
+	t.Parallel()
+
+	answer := `{"reading":842,"confidence":0.9}`
+	req := func() *models.OCRRequest {
+		return &models.OCRRequest{ImageBase64: testPhotoBase64, MeterType: models.MeterTypeWater}
+	}
+	tpl := &models.OCRPromptTemplate{Version: "water-3a", MeterType: models.MeterTypeWater, Base: "Read the odometer of this water meter."}
+
+	t.Run("variant template", func(t *testing.T) {
+		t.Parallel()
+		p := &stubOCRProvider{name: "gemini/flash-lite", raw: answer}
+		svc := NewOCRService(nil, nil, []OCRProvider{p}, OCROptions{Prompts: stubOCRPrompts{tpl: tpl}})
+		resp, err := svc.Process(t.Context(), "u", req())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(p.prompt, tpl.Base) || strings.Contains(p.prompt, ocrPromptWater) {
+			t.Errorf("prompt = %q, want the template's", p.prompt)
+		}
+		if resp.PromptVersion != "water-3a" {
+			t.Errorf("prompt version = %q, want water-3a", resp.PromptVersion)
+		}
+	})
+
+	t.Run("variant with a mode keeps its suffix", func(t *testing.T) {
+		t.Parallel()
+		p := &stubOCRProvider{name: "gemini/flash-lite", raw: `{"reading":0,"confidence":0,"meters":[{"reading":1204,"confidence":0.9}]}`}
+		svc := NewOCRService(nil, nil, []OCRProvider{p}, OCROptions{Prompts: stubOCRPrompts{tpl: tpl}})
+		r := req()
+		r.Bank = true
+		resp, err := svc.Process(t.Context(), "u", r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.PromptVersion != "water-3a+"+ocrBankPromptVersion {
+			t.Errorf("prompt version = %q, want the variant's with the bank suffix", resp.PromptVersion)
+		}
+	})
+
+	t.Run("lookup failure keeps the built-in prompt", func(t *testing.T) {
+		t.Parallel()
+		p := &stubOCRProvider{name: "gemini/flash-lite", raw: answer}
+		svc := NewOCRService(nil, nil, []OCRProvider{p}, OCROptions{Prompts: stubOCRPrompts{err: errors.New("unavailable")}})
+		resp, err := svc.Process(t.Context(), "u", req())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.PromptVersion != "water-2" {
+			t.Errorf("prompt version = %q, want the built-in water-2", resp.PromptVersion)
+		}
+	})
+}
+
+func TestOCRService_ReportForbidden(t *testing.T) {
+	t.Parallel()
+
+	svc := NewOCRService(nil, nil, nil, OCROptions{ReportUIDs: []string{"analyst"}})
+	tests := []struct {
+		name    string
+		uid     string
+		t       models.MeterType
+		status  int
+		wantKey string
+	}{
+		{name: "not allowed", uid: "u", status: 403, wantKey: "errors.ocr.report_forbidden"},
+		{name: "no uid", uid: "", status: 403, wantKey: "errors.ocr.report_forbidden"},
+		{name: "bad meter type", uid: "analyst", t: "steam", status: 400, wantKey: "errors.ocr.invalid_meter_type"},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := svc.Report(t.Context(), tc.uid, tc.t, 0)
+			var appErr *middleware.AppError
+			if !errors.As(err, &appErr) || appErr.HTTPStatus != tc.status || appErr.Key != tc.wantKey {
+				t.Errorf("err = %v, want %d %s", err, tc.status, tc.wantKey)
+			}
+		})
+	}
+}
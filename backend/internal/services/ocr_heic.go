@@ -0,0 +1,53 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// HEICConverter turns a HEIC / HEIF photo (what iPhones shoot by default)
+// into JPEG. The standard library has no HEVC decoder and the image is built
+// without cgo, so conversion is delegated; see NewCommandHEICConverter.
+type HEICConverter interface {
+	ToJPEG(ctx context.Context, heic []byte) ([]byte, error)
+}
+
+// heicConvertTimeout bounds one conversion; a 12-megapixel photo takes well
+// under a second.
+const heicConvertTimeout = 20 * time.Second
+
+type commandHEICConverter struct {
+	argv []string
+}
+
+// NewCommandHEICConverter converts with an external program that reads HEIC
+// on stdin and writes JPEG to stdout, e.g.
+// "magick heic:- -quality 90 jpg:-". command is split on spaces; it is
+// operator configuration, not user input, and runs without a shell. A blank
+// command gives nil: no conversion.
+func NewCommandHEICConverter(command string) HEICConverter {
+	argv := strings.Fields(command)
+	if len(argv) == 0 {
+		return nil
+	}
+	return &commandHEICConverter{argv: argv}
+}
+
+func (c *commandHEICConverter) ToJPEG(ctx context.Context, heic []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, heicConvertTimeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, c.argv[0], c.argv[1:]...)
+	cmd.Stdin = bytes.NewReader(heic)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s: %w: %s", c.argv[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,347 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	_ "image/png" // registers the PNG decoder for image.Decode
+	"strings"
+
+	"wattrent/internal/middleware"
+	"wattrent/internal/models"
+)
+
+// maxOCRImageBytes caps a photo before anything decodes it: the download is
+// cut off past it and a base64 body longer than its encoding is rejected
+// unread. A 12-megapixel phone JPEG is 3-6 MB.
+const maxOCRImageBytes = 15 << 20
+
+// maxOCRImagePixels caps width x height, checked from the header before
+// decoding: a decoded photo takes 4 bytes per pixel.
+const maxOCRImagePixels = 30_000_000
+
+// defaultOCRMaxDimension is the longest side photos are scaled down to when
+// OCROptions does not say. Meter digits stay legible well below it, and the
+// model bills images by size.
+const defaultOCRMaxDimension = 1600
+
+// ocrJPEGQuality is the quality preprocessed photos are re-encoded with.
+const ocrJPEGQuality = 90
+
+// imagePipeline prepares a photo for the model: EXIF orientation applied,
+// scaled down to maxDimension, optionally contrast-stretched, re-encoded as
+// JPEG.
+type imagePipeline struct {
+	maxDimension      int
+	normalizeContrast bool
+}
+
+// process runs the pipeline and scores the upright photo (see scorePhoto)
+// before contrast is touched. Formats the standard library cannot decode
+// (WebP) pass through unchanged and unscored, as does, scored, a JPEG that
+// needs no step at all, so it is not re-compressed for nothing.
+func (p imagePipeline) process(data []byte, mime string) ([]byte, string, *models.OCRPhotoQuality, error) {
+	if mime == "image/webp" {
+		if w, h, ok := webpSize(data); ok && w*h > maxOCRImagePixels {
+			return nil, "", nil, &middleware.AppError{HTTPStatus: 413, Key: "errors.ocr.image_too_many_pixels"}
+		}
+	}
+	if mime != "image/jpeg" && mime != "image/png" {
+		return data, mime, nil, nil
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", nil, &middleware.AppError{HTTPStatus: 400, Key: "errors.ocr.invalid_image", Cause: err}
+	}
+	if cfg.Width*cfg.Height > maxOCRImagePixels {
+		return nil, "", nil, &middleware.AppError{HTTPStatus: 413, Key: "errors.ocr.image_too_many_pixels"}
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", nil, &middleware.AppError{HTTPStatus: 400, Key: "errors.ocr.invalid_image", Cause: err}
+	}
+
+	orientation := 1
+	if mime == "image/jpeg" {
+		orientation = jpegOrientation(data)
+	}
+	rgba := orient(toRGBA(img), orientation)
+	quality := scorePhoto(rgba)
+
+	b := img.Bounds()
+	needsResize := p.maxDimension > 0 && max(b.Dx(), b.Dy()) > p.maxDimension
+	if mime == "image/jpeg" && orientation == 1 && !needsResize && !p.normalizeContrast {
+		return data, mime, quality, nil
+	}
+
+	if needsResize {
+		rgba = downscale(rgba, p.maxDimension)
+	}
+	if p.normalizeContrast {
+		stretchContrast(rgba)
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, rgba, &jpeg.Options{Quality: ocrJPEGQuality}); err != nil {
+		return nil, "", nil, err
+	}
+	return buf.Bytes(), "image/jpeg", quality, nil
+}
+
+// sniffImageType identifies a photo from its magic bytes: image/jpeg,
+// image/png, image/webp or image/heic (HEIC and HEIF); "" for anything else.
+func sniffImageType(data []byte) string {
+	switch {
+	case len(data) >= 3 && data[0] == 0xFF && data[1] == 0xD8 && data[2] == 0xFF:
+		return "image/jpeg"
+	case len(data) >= 8 && string(data[:8]) == "\x89PNG\r\n\x1a\n":
+		return "image/png"
+	case len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		return "image/webp"
+	case len(data) >= 12 && string(data[4:8]) == "ftyp" && heicBrands[string(data[8:12])]:
+		return "image/heic"
+	}
+	return ""
+}
+
+// heicBrands are the ISO-BMFF major brands of HEIC / HEIF stills.
+var heicBrands = map[string]bool{
+	"heic": true, "heix": true, "heim": true, "heis": true,
+	"hevc": true, "hevx": true, "mif1": true, "msf1": true,
+}
+
+// checkImageType sniffs data and checks it against the claimed MIME type (a
+// data-URI header, the stored content type or the file extension; "" when
+// there is none). It returns the sniffed type.
+func checkImageType(data []byte, claimed string) (string, error) {
+	sniffed := sniffImageType(data)
+	if sniffed == "" {
+		return "", &middleware.AppError{HTTPStatus: 415, Key: "errors.ocr.unsupported_image_type"}
+	}
+	switch claimed = strings.ToLower(claimed); claimed {
+	case "":
+	case "image/jpg":
+		claimed = "image/jpeg"
+	case "image/heif":
+		claimed = "image/heic"
+	}
+	if claimed != "" && claimed != sniffed {
+		return "", &middleware.AppError{
+			HTTPStatus: 415,
+			Key:        "errors.ocr.image_type_mismatch",
+			Cause:      fmt.Errorf("labelled %s, content is %s", claimed, sniffed),
+		}
+	}
+	return sniffed, nil
+}
+
+// webpSize reads the canvas size from a WebP header (lossy, lossless or
+// extended).
+func webpSize(data []byte) (w, h int, ok bool) {
+	if len(data) < 30 {
+		return 0, 0, false
+	}
+	switch string(data[12:16]) {
+	case "VP8 ": // frame header: 3-byte tag, start code, 14-bit sizes
+		return int(binary.LittleEndian.Uint16(data[26:]) & 0x3FFF), int(binary.LittleEndian.Uint16(data[28:]) & 0x3FFF), true
+	case "VP8L": // signature byte, then 14-bit width-1 and height-1
+		bits := binary.LittleEndian.Uint32(data[21:])
+		return int(bits&0x3FFF) + 1, int(bits>>14&0x3FFF) + 1, true
+	case "VP8X": // 24-bit canvas width-1 and height-1
+		return int(uint32(data[24])|uint32(data[25])<<8|uint32(data[26])<<16) + 1,
+			int(uint32(data[27])|uint32(data[28])<<8|uint32(data[29])<<16) + 1, true
+	}
+	return 0, 0, false
+}
+
+// jpegOrientation reads the EXIF Orientation tag (1-8) of a JPEG; 1 (as
+// shot) when there is none. Phones store portrait shots as landscape pixels
+// plus this tag, which the model does not honour.
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return 1
+		}
+		marker := data[i+1]
+		if marker == 0xDA || marker == 0xD9 { // start of scan / end of image
+			return 1
+		}
+		size := int(binary.BigEndian.Uint16(data[i+2:]))
+		if size < 2 || i+2+size > len(data) {
+			return 1
+		}
+		seg := data[i+4 : i+2+size]
+		if marker == 0xE1 && len(seg) > 6 && string(seg[:6]) == "Exif\x00\x00" {
+			return exifOrientation(seg[6:])
+		}
+		i += 2 + size
+	}
+	return 1
+}
+
+// exifOrientation finds tag 0x0112 in IFD0 of a TIFF-structured EXIF block.
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd+2 > len(tiff) {
+		return 1
+	}
+	n := int(order.Uint16(tiff[ifd:]))
+	for e := 0; e < n; e++ {
+		off := ifd + 2 + e*12
+		if off+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[off:]) == 0x0112 {
+			if v := int(order.Uint16(tiff[off+8:])); v >= 1 && v <= 8 {
+				return v
+			}
+			return 1
+		}
+	}
+	return 1
+}
+
+func toRGBA(img image.Image) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok && rgba.Rect.Min == (image.Point{}) {
+		return rgba
+	}
+	b := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(rgba, rgba.Rect, img, b.Min, draw.Src)
+	return rgba
+}
+
+// orient turns pixels stored with EXIF orientation o upright.
+func orient(src *image.RGBA, o int) *image.RGBA {
+	if o <= 1 || o > 8 {
+		return src
+	}
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	dw, dh := w, h
+	if o >= 5 { // the 90-degree cases swap width and height
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for sy := 0; sy < h; sy++ {
+		for sx := 0; sx < w; sx++ {
+			var dx, dy int
+			switch o {
+			case 2: // mirrored
+				dx, dy = w-1-sx, sy
+			case 3: // upside down
+				dx, dy = w-1-sx, h-1-sy
+			case 4: // mirrored upside down
+				dx, dy = sx, h-1-sy
+			case 5: // mirrored, rotated 90 CCW
+				dx, dy = sy, sx
+			case 6: // rotated 90 CCW: turn 90 CW
+				dx, dy = h-1-sy, sx
+			case 7: // mirrored, rotated 90 CW
+				dx, dy = h-1-sy, w-1-sx
+			case 8: // rotated 90 CW: turn 90 CCW
+				dx, dy = sy, w-1-sx
+			}
+			copy(dst.Pix[dst.PixOffset(dx, dy):][:4], src.Pix[src.PixOffset(sx, sy):][:4])
+		}
+	}
+	return dst
+}
+
+// downscale shrinks src so its longer side is maxDimension, averaging the
+// source pixels each target pixel covers (a box filter: sharp enough for
+// digits, and no aliasing from skipping pixels).
+func downscale(src *image.RGBA, maxDimension int) *image.RGBA {
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	dw, dh := maxDimension, h*maxDimension/w
+	if h > w {
+		dw, dh = w*maxDimension/h, maxDimension
+	}
+	dw, dh = max(dw, 1), max(dh, 1)
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for dy := 0; dy < dh; dy++ {
+		y0, y1 := dy*h/dh, max((dy+1)*h/dh, dy*h/dh+1)
+		for dx := 0; dx < dw; dx++ {
+			x0, x1 := dx*w/dw, max((dx+1)*w/dw, dx*w/dw+1)
+			var sum [4]int
+			for y := y0; y < y1; y++ {
+				row := src.Pix[src.PixOffset(x0, y):src.PixOffset(x1, y)]
+				for i := 0; i < len(row); i += 4 {
+					sum[0] += int(row[i])
+					sum[1] += int(row[i+1])
+					sum[2] += int(row[i+2])
+					sum[3] += int(row[i+3])
+				}
+			}
+			n := (x1 - x0) * (y1 - y0)
+			p := dst.Pix[dst.PixOffset(dx, dy):]
+			for c := 0; c < 4; c++ {
+				p[c] = uint8(sum[c] / n)
+			}
+		}
+	}
+	return dst
+}
+
+// stretchContrast maps the 1st-99th percentile of luminance onto the full
+// range, in place, which lifts digits out of a dim or hazy photo. A photo
+// that already spans the range, or is one flat tone, is left alone.
+func stretchContrast(img *image.RGBA) {
+	var hist [256]int
+	total := 0
+	for i := 0; i+3 < len(img.Pix); i += 4 {
+		hist[luma(img.Pix[i], img.Pix[i+1], img.Pix[i+2])]++
+		total++
+	}
+	lo, hi := percentile(&hist, total, 0.01), percentile(&hist, total, 0.99)
+	if hi-lo < 16 || (lo == 0 && hi == 255) {
+		return
+	}
+	var lut [256]uint8
+	for v := range lut {
+		s := (v - lo) * 255 / (hi - lo)
+		lut[v] = uint8(min(max(s, 0), 255))
+	}
+	for i := 0; i+3 < len(img.Pix); i += 4 {
+		img.Pix[i] = lut[img.Pix[i]]
+		img.Pix[i+1] = lut[img.Pix[i+1]]
+		img.Pix[i+2] = lut[img.Pix[i+2]]
+	}
+}
+
+// luma is the Rec. 601 luminance of an RGB pixel.
+func luma(r, g, b uint8) int {
+	return (299*int(r) + 587*int(g) + 114*int(b)) / 1000
+}
+
+// percentile is the smallest value at or below which share q of the total
+// falls.
+func percentile(hist *[256]int, total int, q float64) int {
+	target := int(q * float64(total))
+	seen := 0
+	for v, n := range hist {
+		seen += n
+		if seen > target {
+			return v
+		}
+	}
+	return 255
+}
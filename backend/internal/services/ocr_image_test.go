@@ -0,0 +1,296 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"wattrent/internal/middleware"
+	"wattrent/internal/models"
+)
+
+// testPhotoBase64 is a small valid JPEG for tests that go through Process.
+var testPhotoBase64 = base64.StdEncoding.EncodeToString(encodeTestJPEG(gradient(8, 8), nil))
+
+// gradient is a w x h image whose red channel grows left to right and green
+// top to bottom, so orientation changes are visible.
+func gradient(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x * 255 / max(w-1, 1)), G: uint8(y * 255 / max(h-1, 1)), A: 255})
+		}
+	}
+	return img
+}
+
+// encodeTestJPEG encodes img, with an EXIF APP1 segment carrying orientation
+// when it is not nil.
+func encodeTestJPEG(img image.Image, orientation *uint16) []byte {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 95}); err != nil {
+		panic(err)
+	}
+	data := buf.Bytes()
+	if orientation == nil {
+		return data
+	}
+	// Big-endian TIFF header, IFD0 at offset 8 with one entry: 0x0112 SHORT 1.
+	tiff := []byte{'M', 'M', 0, 42, 0, 0, 0, 8, 0, 1, 0x01, 0x12, 0, 3, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0}
+	binary.BigEndian.PutUint16(tiff[18:], *orientation)
+	seg := append([]byte("Exif\x00\x00"), tiff...)
+	app1 := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(app1[2:], uint16(len(seg)+2))
+	out := append([]byte{}, data[:2]...)
+	out = append(out, app1...)
+	out = append(out, seg...)
+	return append(out, data[2:]...)
+}
+
+func TestJPEGOrientation(t *testing.T) {
+	t.Parallel()
+
+	for _, o := range []uint16{1, 3, 6, 8} {
+		o := o
+		if got := jpegOrientation(encodeTestJPEG(gradient(4, 4), &o)); got != int(o) {
+			t.Errorf("orientation %d read as %d", o, got)
+		}
+	}
+	if got := jpegOrientation(encodeTestJPEG(gradient(4, 4), nil)); got != 1 {
+		t.Errorf("no EXIF read as %d, want 1", got)
+	}
+	if got := jpegOrientation([]byte("not a jpeg")); got != 1 {
+		t.Errorf("garbage read as %d, want 1", got)
+	}
+}
+
+func TestOrient(t *testing.T) {
+	t.Parallel()
+
+	// 3 wide, 2 tall; the top-left pixel is marked.
+	src := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	src.Set(0, 0, color.RGBA{R: 255, A: 255})
+
+	tests := []struct {
+		orientation int
+		wantW       int
+		wantMarked  image.Point
+	}{
+		{1, 3, image.Pt(0, 0)},
+		{3, 3, image.Pt(2, 1)},
+		{6, 2, image.Pt(1, 0)}, // turned clockwise: top-left goes top-right
+		{8, 2, image.Pt(0, 2)}, // turned counter-clockwise: top-left goes bottom-left
+	}
+	for _, tc := range tests {
+		got := orient(src, tc.orientation)
+		if got.Rect.Dx() != tc.wantW {
+			t.Errorf("orientation %d: width %d, want %d", tc.orientation, got.Rect.Dx(), tc.wantW)
+			continue
+		}
+		if r, _, _, _ := got.At(tc.wantMarked.X, tc.wantMarked.Y).RGBA(); r == 0 {
+			t.Errorf("orientation %d: marked pixel not at %v", tc.orientation, tc.wantMarked)
+		}
+	}
+}
+
+func TestImagePipeline(t *testing.T) {
+	t.Parallel()
+
+	p := imagePipeline{maxDimension: 100}
+
+	// A small upright JPEG passes through untouched.
+	small := encodeTestJPEG(gradient(40, 30), nil)
+	if out, mime, _, err := p.process(small, "image/jpeg"); err != nil || !bytes.Equal(out, small) || mime != "image/jpeg" {
+		t.Errorf("small JPEG changed: mime %q err %v", mime, err)
+	}
+
+	// A big portrait shot stored sideways is turned upright and scaled down.
+	six := uint16(6)
+	out, mime, _, err := p.process(encodeTestJPEG(gradient(400, 300), &six), "image/jpeg")
+	if err != nil {
+		t.Fatalf("process: %v", err)
+	}
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(out))
+	if err != nil || mime != "image/jpeg" {
+		t.Fatalf("output is not a JPEG: %v", err)
+	}
+	if cfg.Width != 75 || cfg.Height != 100 {
+		t.Errorf("output %dx%d, want 75x100", cfg.Width, cfg.Height)
+	}
+
+	// PNG is re-encoded as JPEG.
+	var pngBuf bytes.Buffer
+	_ = png.Encode(&pngBuf, gradient(20, 20))
+	if _, mime, _, err := p.process(pngBuf.Bytes(), "image/png"); err != nil || mime != "image/jpeg" {
+		t.Errorf("PNG: mime %q err %v", mime, err)
+	}
+
+	// Undecodable bytes labelled as JPEG are rejected.
+	_, _, _, err = p.process([]byte("Hello"), "image/jpeg")
+	var appErr *middleware.AppError
+	if !errors.As(err, &appErr) || appErr.Key != "errors.ocr.invalid_image" {
+		t.Errorf("garbage: err = %v, want errors.ocr.invalid_image", err)
+	}
+}
+
+func TestStretchContrast(t *testing.T) {
+	t.Parallel()
+
+	// A hazy photo: every pixel between 100 and 150.
+	img := image.NewRGBA(image.Rect(0, 0, 51, 1))
+	for x := 0; x <= 50; x++ {
+		v := uint8(100 + x)
+		img.Set(x, 0, color.RGBA{R: v, G: v, B: v, A: 255})
+	}
+	stretchContrast(img)
+	if lo, hi := img.Pix[0], img.Pix[len(img.Pix)-4]; lo > 10 || hi < 245 {
+		t.Errorf("range after stretch = %d-%d, want about 0-255", lo, hi)
+	}
+}
+
+func TestOCRService_RejectsOversizedBase64(t *testing.T) {
+	t.Parallel()
+
+	svc := NewOCRService(nil, nil, []OCRProvider{NewFakeOCRProvider()})
+	huge := make([]byte, maxOCRImageBytes/3*4+1024)
+	for i := range huge {
+		huge[i] = 'A'
+	}
+	_, err := svc.Process(t.Context(), "u", &models.OCRRequest{ImageBase64: string(huge)})
+	var appErr *middleware.AppError
+	if !errors.As(err, &appErr) || appErr.HTTPStatus != 413 || appErr.Key != "errors.ocr.image_too_large" {
+		t.Errorf("err = %v, want 413 errors.ocr.image_too_large", err)
+	}
+}
+
+// testHEIC is the start of a HEIC file: an ftyp box with the "heic" brand.
+var testHEIC = append([]byte("\x00\x00\x00\x18ftypheic\x00\x00\x00\x00mif1heic"), make([]byte, 64)...)
+
+func TestCheckImageType(t *testing.T) {
+	t.Parallel()
+
+	jpg := encodeTestJPEG(gradient(4, 4), nil)
+	var pngBuf bytes.Buffer
+	_ = png.Encode(&pngBuf, gradient(4, 4))
+	webp := []byte("RIFF\x24\x00\x00\x00WEBPVP8 \x18\x00\x00\x00")
+
+	tests := []struct {
+		name    string
+		data    []byte
+		claimed string
+		want    string
+		wantKey string
+	}{
+		{name: "jpeg, no claim", data: jpg, want: "image/jpeg"},
+		{name: "jpeg as image/jpg", data: jpg, claimed: "image/jpg", want: "image/jpeg"},
+		{name: "png", data: pngBuf.Bytes(), claimed: "image/png", want: "image/png"},
+		{name: "webp", data: webp, claimed: "image/webp", want: "image/webp"},
+		{name: "heic as heif", data: testHEIC, claimed: "image/heif", want: "image/heic"},
+		{name: "png labelled jpeg", data: pngBuf.Bytes(), claimed: "image/jpeg", wantKey: "errors.ocr.image_type_mismatch"},
+		{name: "text labelled jpeg", data: []byte("Hello"), claimed: "image/jpeg", wantKey: "errors.ocr.unsupported_image_type"},
+		{name: "gif", data: []byte("GIF89a\x01\x00\x01\x00"), wantKey: "errors.ocr.unsupported_image_type"},
+		{name: "empty", wantKey: "errors.ocr.unsupported_image_type"},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := checkImageType(tc.data, tc.claimed)
+			if tc.wantKey != "" {
+				var appErr *middleware.AppError
+				if !errors.As(err, &appErr) || appErr.HTTPStatus != 415 || appErr.Key != tc.wantKey {
+					t.Errorf("err = %v, want 415 %s", err, tc.wantKey)
+				}
+				return
+			}
+			if err != nil || got != tc.want {
+				t.Errorf("got %q, %v; want %q", got, err, tc.want)
+			}
+		})
+	}
+}
+
+func TestImagePipeline_PixelLimit(t *testing.T) {
+	t.Parallel()
+
+	// A PNG header claiming 10000x10000: rejected before anything is decoded.
+	var buf bytes.Buffer
+	buf.WriteString("\x89PNG\r\n\x1a\n")
+	ihdr := make([]byte, 17)
+	copy(ihdr, "IHDR")
+	binary.BigEndian.PutUint32(ihdr[4:], 10000)
+	binary.BigEndian.PutUint32(ihdr[8:], 10000)
+	ihdr[12], ihdr[13] = 8, 6 // 8-bit RGBA
+	_ = binary.Write(&buf, binary.BigEndian, uint32(13))
+	buf.Write(ihdr)
+	_ = binary.Write(&buf, binary.BigEndian, crc32.ChecksumIEEE(ihdr))
+
+	_, _, _, err := imagePipeline{}.process(buf.Bytes(), "image/png")
+	var appErr *middleware.AppError
+	if !errors.As(err, &appErr) || appErr.HTTPStatus != 413 || appErr.Key != "errors.ocr.image_too_many_pixels" {
+		t.Errorf("err = %v, want 413 errors.ocr.image_too_many_pixels", err)
+	}
+}
+
+type stubHEICConverter struct {
+	out []byte
+	err error
+}
+
+func (c stubHEICConverter) ToJPEG(context.Context, []byte) ([]byte, error) { return c.out, c.err }
+
+func TestOCRService_HEIC(t *testing.T) {
+	t.Parallel()
+
+	req := &models.OCRRequest{ImageBase64: "data:image/heic;base64," + base64.StdEncoding.EncodeToString(testHEIC)}
+	answer := `{"reading":36034,"confidence":0.9}`
+
+	t.Run("converted to jpeg", func(t *testing.T) {
+		t.Parallel()
+		p := &stubOCRProvider{name: "gemini/flash-lite", raw: answer}
+		svc := NewOCRService(nil, nil, []OCRProvider{p}, OCROptions{
+			HEIC: stubHEICConverter{out: encodeTestJPEG(gradient(8, 8), nil)},
+		})
+		if _, err := svc.Process(t.Context(), "", req); err != nil {
+			t.Fatal(err)
+		}
+		if p.mime != "image/jpeg" {
+			t.Errorf("provider got %q, want image/jpeg", p.mime)
+		}
+	})
+
+	t.Run("refused without a converter", func(t *testing.T) {
+		t.Parallel()
+		p := &stubOCRProvider{name: "gemini/flash-lite", raw: answer}
+		svc := NewOCRService(nil, nil, []OCRProvider{p})
+		_, err := svc.Process(t.Context(), "", req)
+		var appErr *middleware.AppError
+		if !errors.As(err, &appErr) || appErr.HTTPStatus != 415 || appErr.Key != "errors.ocr.heic_not_supported" {
+			t.Errorf("err = %v, want 415 errors.ocr.heic_not_supported", err)
+		}
+		if p.calls != 0 {
+			t.Errorf("provider called %d times, want none", p.calls)
+		}
+	})
+
+	t.Run("conversion failure", func(t *testing.T) {
+		t.Parallel()
+		svc := NewOCRService(nil, nil, []OCRProvider{&stubOCRProvider{name: "fake", raw: answer}}, OCROptions{
+			HEIC: stubHEICConverter{err: errors.New("exit status 1")},
+		})
+		_, err := svc.Process(t.Context(), "", req)
+		var appErr *middleware.AppError
+		if !errors.As(err, &appErr) || appErr.Key != "errors.ocr.heic_conversion_failed" {
+			t.Errorf("err = %v, want errors.ocr.heic_conversion_failed", err)
+		}
+	})
+}
@@ -0,0 +1,544 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"image"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// lcdWorkDimension is the longest side photos are searched at: seven-segment
+// digits stay a few dozen pixels tall and the search stays in milliseconds.
+const lcdWorkDimension = 640
+
+// lcdMinDigits is the fewest digits a register can have; a shorter row is
+// more likely a stray label than the display.
+const lcdMinDigits = 3
+
+// errLCDNotFound is returned when no row of seven-segment digits decodes; the
+// chain then falls through to the next provider.
+var errLCDNotFound = errors.New("lcd: no seven-segment register found")
+
+// errLCDBank is returned for bank photos: the reader finds one register.
+var errLCDBank = errors.New("lcd: meter banks are not supported")
+
+type lcdOCRProvider struct{}
+
+// NewLCDOCRProvider reads the seven-segment LCD of digital meters (Taipower
+// AMI and most electronic meters) locally, in pure Go: no quota, no network.
+// It fails on anything that is not a clean segment display, such as a
+// mechanical register, so put it before a model provider in OCR_PROVIDERS to
+// try it first, or after one as the fallback for when upstream is down.
+func NewLCDOCRProvider() OCRProvider { return lcdOCRProvider{} }
+
+func (lcdOCRProvider) Name() string { return "lcd" }
+
+func (lcdOCRProvider) ReadMeter(_ context.Context, in *OCRInput) (string, error) {
+	if in.Bank {
+		return "", errLCDBank
+	}
+	img, _, err := image.Decode(bytes.NewReader(in.Image))
+	if err != nil {
+		return "", err // WebP: not ours to read
+	}
+	r, err := readLCD(toRGBA(img))
+	if err != nil {
+		return "", err
+	}
+	raw, err := json.Marshal(r.output())
+	return string(raw), err
+}
+
+// lcdRead is what readLCD found: the integer digits of the register, the
+// digits after its decimal point (dropped from the reading, which is whole
+// units like the models'), and the least certain digit's certainty.
+type lcdRead struct {
+	digits     string
+	fraction   string
+	reading    float64
+	confidence float64
+	height     int // digit height in work pixels, to rank candidate rows
+
+	// Per integer digit: its confidence and where it is, in work pixels of
+	// a work image of size.
+	digitConfidence []float64
+	boxes           []image.Rectangle
+	size            image.Point
+
+	// The lit segments of every digit, tenths included.
+	segments []int
+}
+
+// output is r as a model would have answered, boxes included.
+func (r *lcdRead) output() ocrModelOutput {
+	out := ocrModelOutput{
+		Reading:    r.reading,
+		Confidence: r.confidence,
+		Notes:      "lcd " + strings.Join(strings.Split(r.digits, ""), " "),
+	}
+	if r.fraction != "" {
+		out.Notes += ", tenths dropped"
+	}
+	var register image.Rectangle
+	for i, d := range r.digits {
+		out.Digits = append(out.Digits, ocrModelDigit{
+			Value:      int(d - '0'),
+			Confidence: r.digitConfidence[i],
+			Box:        r.box2D(r.boxes[i]),
+		})
+		register = register.Union(r.boxes[i])
+	}
+	out.RegisterBox = r.box2D(register)
+	return out
+}
+
+// box2D is b in the models' [ymin, xmin, ymax, xmax] 0-1000 form.
+func (r *lcdRead) box2D(b image.Rectangle) []float64 {
+	sx, sy := 1000/float64(r.size.X), 1000/float64(r.size.Y)
+	return []float64{
+		math.Round(float64(b.Min.Y) * sy), math.Round(float64(b.Min.X) * sx),
+		math.Round(float64(b.Max.Y) * sy), math.Round(float64(b.Max.X) * sx),
+	}
+}
+
+// lcdDigitConfidence maps a segment decision's certainty (0 at the threshold,
+// 1 clear) to a confidence: a clean decode is 0.95, like a confident model,
+// and a segment at the threshold brings it down to 0.5, which escalation
+// retries.
+func lcdDigitConfidence(certainty float64) float64 {
+	return roundHundredth(0.5 + 0.45*certainty)
+}
+
+// readLCD finds and decodes the register: the photo is binarised with Otsu's
+// threshold (dark segments on a light display, then the other way round for
+// backlit ones), ink blobs of digit proportions are grouped into rows of
+// equal height, and each row is re-binarised locally and decoded segment by
+// segment. The tallest row that decodes entirely wins.
+func readLCD(img *image.RGBA) (*lcdRead, error) {
+	if max(img.Rect.Dx(), img.Rect.Dy()) > lcdWorkDimension {
+		img = downscale(img, lcdWorkDimension)
+	}
+	g := newGrayPlane(img)
+
+	var best *lcdRead
+	for _, darkInk := range []bool{true, false} {
+		mask := g.binarize(g.otsu(g.bounds()), darkInk)
+		for _, row := range lcdRows(g, mask) {
+			for _, slant := range lcdSlants {
+				r, ok := decodeLCDRow(g, row, darkInk, slant)
+				if ok && (best == nil || r.height > best.height ||
+					(r.height == best.height && r.confidence > best.confidence)) {
+					best = r
+				}
+			}
+		}
+	}
+	if best == nil {
+		return nil, errLCDNotFound
+	}
+	return best, nil
+}
+
+// lcdSlants are the italic slants rows are decoded with, as horizontal shift
+// per unit of height: many meter LCDs lean their digits to the right.
+var lcdSlants = []float64{0, 0.06, 0.12, 0.18}
+
+// grayPlane is a photo's luminance, one byte per pixel.
+type grayPlane struct {
+	w, h int
+	pix  []uint8
+}
+
+func newGrayPlane(img *image.RGBA) *grayPlane {
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	g := &grayPlane{w: w, h: h, pix: make([]uint8, w*h)}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			p := img.Pix[img.PixOffset(x, y):]
+			g.pix[y*w+x] = uint8(luma(p[0], p[1], p[2]))
+		}
+	}
+	return g
+}
+
+func (g *grayPlane) bounds() image.Rectangle { return image.Rect(0, 0, g.w, g.h) }
+
+// otsu returns the threshold that best separates the luminance in r into two
+// classes (Otsu's method: maximum between-class variance).
+func (g *grayPlane) otsu(r image.Rectangle) uint8 {
+	var hist [256]int
+	total := 0
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for _, v := range g.pix[y*g.w+r.Min.X : y*g.w+r.Max.X] {
+			hist[v]++
+			total++
+		}
+	}
+	sumAll := 0.0
+	for v, n := range hist {
+		sumAll += float64(v * n)
+	}
+	var best uint8
+	bestVar, sumLow, nLow := -1.0, 0.0, 0
+	for t := 0; t < 256; t++ {
+		nLow += hist[t]
+		sumLow += float64(t * hist[t])
+		nHigh := total - nLow
+		if nLow == 0 || nHigh == 0 {
+			continue
+		}
+		d := sumLow/float64(nLow) - (sumAll-sumLow)/float64(nHigh)
+		if v := float64(nLow) * float64(nHigh) * d * d; v > bestVar {
+			bestVar, best = v, uint8(t)
+		}
+	}
+	return best
+}
+
+// binarize marks ink: pixels at or below t when darkInk, above t otherwise.
+func (g *grayPlane) binarize(t uint8, darkInk bool) []bool {
+	mask := make([]bool, len(g.pix))
+	for i, v := range g.pix {
+		mask[i] = (v <= t) == darkInk
+	}
+	return mask
+}
+
+// lcdBlob is a connected group of ink, in work pixels.
+type lcdBlob struct {
+	box image.Rectangle
+}
+
+// lcdBlobs labels the 8-connected components of mask after dilating it by r
+// pixels, which joins the segments of one digit across their gaps. Boxes are
+// shrunk back by r.
+func lcdBlobs(w, h int, mask []bool, r int) []lcdBlob {
+	dilated := make([]bool, len(mask))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if !mask[y*w+x] {
+				continue
+			}
+			for dy := max(y-r, 0); dy <= min(y+r, h-1); dy++ {
+				for dx := max(x-r, 0); dx <= min(x+r, w-1); dx++ {
+					dilated[dy*w+dx] = true
+				}
+			}
+		}
+	}
+
+	seen := make([]bool, len(mask))
+	var blobs []lcdBlob
+	var stack []int
+	for start := range dilated {
+		if !dilated[start] || seen[start] {
+			continue
+		}
+		box := image.Rect(start%w, start/w, start%w+1, start/w+1)
+		seen[start] = true
+		stack = append(stack[:0], start)
+		for len(stack) > 0 {
+			i := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			x, y := i%w, i/w
+			box = box.Union(image.Rect(x, y, x+1, y+1))
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					nx, ny := x+dx, y+dy
+					if nx < 0 || ny < 0 || nx >= w || ny >= h {
+						continue
+					}
+					if j := ny*w + nx; dilated[j] && !seen[j] {
+						seen[j] = true
+						stack = append(stack, j)
+					}
+				}
+			}
+		}
+		box = image.Rect(box.Min.X+r, box.Min.Y+r, box.Max.X-r, box.Max.Y-r).Intersect(image.Rect(0, 0, w, h))
+		if !box.Empty() {
+			blobs = append(blobs, lcdBlob{box: box})
+		}
+	}
+	return blobs
+}
+
+// lcdRow is a left-to-right run of digit-shaped blobs of about the same
+// height on about the same line, plus the small blobs (decimal points) on
+// its baseline.
+type lcdRow struct {
+	digits []image.Rectangle
+	dots   []image.Rectangle
+}
+
+// lcdRows groups the digit-shaped blobs of mask into candidate rows.
+func lcdRows(g *grayPlane, mask []bool) []lcdRow {
+	r := max(1, max(g.w, g.h)/320)
+	blobs := lcdBlobs(g.w, g.h, mask, r)
+
+	var digits, small []image.Rectangle
+	for _, b := range blobs {
+		parts, dots := splitBlob(g.w, mask, b.box)
+		small = append(small, dots...)
+		for _, box := range parts {
+			bw, bh := box.Dx(), box.Dy()
+			touchesEdge := box.Min.X == 0 || box.Min.Y == 0 || box.Max.X == g.w || box.Max.Y == g.h
+			switch {
+			case touchesEdge:
+			case bh >= 12 && bh <= g.h*4/5 && bh*10 >= bw*12 && bh <= bw*12:
+				digits = append(digits, box)
+			case bh < 12 || bw < 12:
+				small = append(small, box)
+			}
+		}
+	}
+	slices.SortFunc(digits, func(a, b image.Rectangle) int { return a.Min.X - b.Min.X })
+
+	var rows []lcdRow
+	used := make([]bool, len(digits))
+	for i, seed := range digits {
+		if used[i] {
+			continue
+		}
+		sh, cy := seed.Dy(), (seed.Min.Y+seed.Max.Y)/2
+		var row lcdRow
+		for j := i; j < len(digits); j++ {
+			d := digits[j]
+			if used[j] || math.Abs(float64(d.Dy()-sh)) > 0.2*float64(sh) ||
+				math.Abs(float64((d.Min.Y+d.Max.Y)/2-cy)) > 0.25*float64(sh) {
+				continue
+			}
+			if n := len(row.digits); n > 0 && d.Min.X-row.digits[n-1].Max.X > 3*sh/2 {
+				break // a gap wider than a digit ends the register
+			}
+			used[j] = true
+			row.digits = append(row.digits, d)
+		}
+		if len(row.digits) < lcdMinDigits {
+			continue
+		}
+		top, bottom := row.digits[0].Min.Y, row.digits[0].Max.Y
+		for _, s := range small {
+			size := min(s.Dx(), s.Dy())
+			if size >= max(2, sh/12) && max(s.Dx(), s.Dy()) <= 2*size+1 && max(s.Dx(), s.Dy()) < sh/4 &&
+				s.Max.Y > bottom-sh/4 && s.Min.Y > top+sh/2 &&
+				s.Min.X > row.digits[0].Max.X && s.Max.X < row.digits[len(row.digits)-1].Min.X {
+				row.dots = append(row.dots, s)
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// splitBlob cuts a blob at the columns that hold no ink above its bottom
+// quarter. Dilation joins a decimal point to the digits on either side of
+// it, and sometimes two digits; inside a seven-segment digit every column
+// crosses a segment above the bottom quarter. Cut-out runs with ink are
+// decimal points.
+func splitBlob(w int, mask []bool, box image.Rectangle) (parts, dots []image.Rectangle) {
+	low := box.Max.Y - box.Dy()/4
+	var part, dot image.Rectangle
+	flush := func() {
+		if !part.Empty() {
+			parts = append(parts, part)
+		}
+		if !dot.Empty() {
+			dots = append(dots, dot)
+		}
+		part, dot = image.Rectangle{}, image.Rectangle{}
+	}
+	for x := box.Min.X; x < box.Max.X; x++ {
+		high, bottom := false, image.Rectangle{}
+		for y := box.Min.Y; y < box.Max.Y; y++ {
+			if mask[y*w+x] {
+				if y < low {
+					high = true
+				} else {
+					bottom = bottom.Union(image.Rect(x, y, x+1, y+1))
+				}
+			}
+		}
+		col := image.Rect(x, box.Min.Y, x+1, box.Max.Y)
+		switch {
+		case high:
+			if !dot.Empty() {
+				flush()
+			}
+			part = part.Union(col)
+		case !bottom.Empty():
+			if !part.Empty() {
+				flush()
+			}
+			dot = dot.Union(bottom)
+		default:
+			flush()
+		}
+	}
+	flush()
+	return parts, dots
+}
+
+// Seven-segment bits: a top, b top right, c bottom right, d bottom, e bottom
+// left, f top left, g middle.
+const (
+	segA = 1 << iota
+	segB
+	segC
+	segD
+	segE
+	segF
+	segG
+)
+
+// lcdPatterns maps lit segments to digits, with the variants meters use for
+// 6 (no top), 7 (with top left) and 9 (no bottom).
+var lcdPatterns = map[int]byte{
+	segA | segB | segC | segD | segE | segF:        '0',
+	segB | segC:                                    '1',
+	segA | segB | segD | segE | segG:               '2',
+	segA | segB | segC | segD | segG:               '3',
+	segB | segC | segF | segG:                      '4',
+	segA | segC | segD | segF | segG:               '5',
+	segA | segC | segD | segE | segF | segG:        '6',
+	segC | segD | segE | segF | segG:               '6',
+	segA | segB | segC:                             '7',
+	segA | segB | segC | segF:                      '7',
+	segA | segB | segC | segD | segE | segF | segG: '8',
+	segA | segB | segC | segD | segF | segG:        '9',
+	segA | segB | segC | segF | segG:               '9',
+}
+
+// lcdSegmentZones are where each segment lies in a digit cell, as fractions
+// of its width and height: x0, y0, x1, y1.
+var lcdSegmentZones = [7][4]float64{
+	{0.25, 0, 0.75, 0.18},    // a
+	{0.7, 0.12, 1, 0.42},     // b
+	{0.7, 0.58, 1, 0.88},     // c
+	{0.25, 0.82, 0.75, 1},    // d
+	{0, 0.58, 0.3, 0.88},     // e
+	{0, 0.12, 0.3, 0.42},     // f
+	{0.25, 0.41, 0.75, 0.59}, // g
+}
+
+// lcdHoles are the two counters of an 8, which no digit fills: a blob with
+// ink there is a solid shape (a wheel window, a sticker), not segments.
+var lcdHoles = [2][4]float64{
+	{0.3, 0.22, 0.7, 0.36},
+	{0.3, 0.64, 0.7, 0.78},
+}
+
+// lcdSegmentOn is the share of a zone in ink from which its segment counts as
+// lit. A lit segment fills about half its zone, an unlit one next to none.
+const lcdSegmentOn = 0.25
+
+// decodeLCDRow re-binarises the row with a threshold of its own (the display
+// is usually lit differently from the rest of the photo) and decodes every
+// digit; ok is false when any digit is not a seven-segment pattern.
+func decodeLCDRow(g *grayPlane, row lcdRow, darkInk bool, slant float64) (*lcdRead, bool) {
+	var heights, tops, bottoms []int
+	cellW := 0
+	for _, d := range row.digits {
+		heights = append(heights, d.Dy())
+		tops = append(tops, d.Min.Y)
+		bottoms = append(bottoms, d.Max.Y)
+		cellW = max(cellW, d.Dx())
+	}
+	top, bottom := median(tops), median(bottoms)
+	h := bottom - top
+	// An italic digit's blob is wider than the digit by its lean.
+	lean := int(slant * float64(h))
+	if cellW-lean < h/4 {
+		return nil, false
+	}
+	area := image.Rect(row.digits[0].Max.X-cellW, top, row.digits[len(row.digits)-1].Max.X, bottom).
+		Inset(-h / 4).Intersect(g.bounds())
+	t := g.otsu(area)
+
+	read := &lcdRead{height: median(heights), confidence: 1, size: image.Pt(g.w, g.h)}
+	var digits strings.Builder
+	decimalAt := -1
+	for i, d := range row.digits {
+		for _, dot := range row.dots {
+			if i > 0 && dot.Min.X >= row.digits[i-1].Max.X && dot.Max.X <= d.Min.X {
+				decimalAt = i
+			}
+		}
+		// Digits are right-aligned in their cells: a 1 is only the right
+		// column of segments. The cell is where the digit stands upright.
+		cell := image.Rect(d.Max.X-cellW, top, d.Max.X-lean, bottom)
+		bits, certainty := 0, 1.0
+		for s, z := range lcdSegmentZones {
+			share := g.inkShare(t, darkInk, cellZone(cell, z, slant))
+			if share >= lcdSegmentOn {
+				bits |= 1 << s
+			}
+			certainty = math.Min(certainty, math.Min(math.Abs(share-lcdSegmentOn)/lcdSegmentOn, 1))
+		}
+		for _, z := range lcdHoles {
+			if g.inkShare(t, darkInk, cellZone(cell, z, slant)) >= lcdSegmentOn {
+				return nil, false
+			}
+		}
+		digit, ok := lcdPatterns[bits]
+		if !ok {
+			return nil, false
+		}
+		digits.WriteByte(digit)
+		read.segments = append(read.segments, bits)
+		read.confidence = math.Min(read.confidence, certainty)
+		read.digitConfidence = append(read.digitConfidence, lcdDigitConfidence(certainty))
+		read.boxes = append(read.boxes, d)
+	}
+
+	read.digits = digits.String()
+	if decimalAt > 0 {
+		read.digits, read.fraction = read.digits[:decimalAt], read.digits[decimalAt:]
+		read.digitConfidence, read.boxes = read.digitConfidence[:decimalAt], read.boxes[:decimalAt]
+	}
+	if len(read.digits) < lcdMinDigits {
+		return nil, false
+	}
+	read.reading, _ = strconv.ParseFloat(read.digits, 64)
+	read.confidence = lcdDigitConfidence(read.confidence)
+	return read, true
+}
+
+// cellZone is the part of cell that z gives as fractions of its size,
+// shifted right by slant for its height above the baseline.
+func cellZone(cell image.Rectangle, z [4]float64, slant float64) image.Rectangle {
+	w, h := float64(cell.Dx()), float64(cell.Dy())
+	shift := int(slant * h * (1 - (z[1]+z[3])/2))
+	return image.Rect(
+		cell.Min.X+shift+int(z[0]*w), cell.Min.Y+int(z[1]*h),
+		cell.Min.X+shift+int(math.Ceil(z[2]*w)), cell.Min.Y+int(math.Ceil(z[3]*h)),
+	)
+}
+
+// inkShare is the share of r that is ink at threshold t.
+func (g *grayPlane) inkShare(t uint8, darkInk bool, r image.Rectangle) float64 {
+	r = r.Intersect(g.bounds())
+	if r.Empty() {
+		return 0
+	}
+	ink := 0
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for _, v := range g.pix[y*g.w+r.Min.X : y*g.w+r.Max.X] {
+			if (v <= t) == darkInk {
+				ink++
+			}
+		}
+	}
+	return float64(ink) / float64(r.Dx()*r.Dy())
+}
+
+func median(v []int) int {
+	s := slices.Clone(v)
+	slices.Sort(s)
+	return s[len(s)/2]
+}
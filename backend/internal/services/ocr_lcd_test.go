@@ -0,0 +1,210 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestLCDOCRProvider_Corpus reads every photo in testdata/lcd. A file is
+// named after the reading it shows, "{reading}_{what it exercises}", or
+// "none_..." for photos the reader must turn down.
+//
+// The photos there are rendered by testdata/lcdgen, whose segment table is
+// a copy of lcdPatterns, so each comes with a golden {name}.txt: the
+// segments the reader must see, drawn as text (see lcdArt). Photos of real
+// meters go in testdata/lcd/real, named the same way, and need no drawing.
+func TestLCDOCRProvider_Corpus(t *testing.T) {
+	t.Parallel()
+
+	files, err := filepath.Glob(filepath.Join("testdata", "lcd", "*.jpg"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no corpus: %v", err)
+	}
+	photos, err := filepath.Glob(filepath.Join("testdata", "lcd", "real", "*.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files = append(files, photos...)
+	p := NewLCDOCRProvider()
+
+	for _, file := range files {
+		file := file
+		name := filepath.Base(file)
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			data, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			raw, err := p.ReadMeter(t.Context(), &OCRInput{Image: data})
+
+			want, _, _ := strings.Cut(name, "_")
+			if want == "none" {
+				if err == nil {
+					t.Errorf("read %s, want no reading", raw)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ReadMeter: %v", err)
+			}
+			var out ocrModelOutput
+			if err := json.Unmarshal([]byte(raw), &out); err != nil {
+				t.Fatalf("bad output %q: %v", raw, err)
+			}
+			if got := strconv.FormatFloat(out.Reading, 'f', -1, 64); got != want {
+				t.Errorf("reading = %s, want %s (notes %q)", got, want, out.Notes)
+			}
+			if out.Confidence < draftConfidenceThreshold {
+				t.Errorf("confidence = %v, want a clean photo read with confidence", out.Confidence)
+			}
+			if out.Reading != sampleReading(out) {
+				t.Errorf("notes %q do not spell the reading %v", out.Notes, out.Reading)
+			}
+			digits := readDigits(&out)
+			if len(digits) == 0 {
+				t.Fatalf("digits %+v do not spell the reading %v", out.Digits, out.Reading)
+			}
+			register := modelBox(out.RegisterBox)
+			for i, d := range digits {
+				if d.Box == nil || register == nil || d.Box.Left < register.Left || d.Box.Right > register.Right {
+					t.Errorf("digit %d box %+v, want it inside the register %+v", i, d.Box, register)
+				}
+			}
+
+			if filepath.Base(filepath.Dir(file)) == "real" {
+				return
+			}
+			golden, err := os.ReadFile(strings.TrimSuffix(file, ".jpg") + ".txt")
+			if err != nil {
+				t.Fatalf("no golden drawing: %v", err)
+			}
+			img, _, err := image.Decode(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			r, err := readLCD(toRGBA(img))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := lcdArt(r.segments), trimArt(string(golden)); got != want {
+				t.Errorf("segments seen:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
+
+// lcdGlyphs draws every digit lcdPatterns knows, variants included, apart
+// from both it and lcdgen's table: a mistake the two share would still read
+// back the corpus it renders.
+const lcdGlyphs = `
+ 0   1   2   3   4   5   6   6   7   7   8   9   9
+ _       _   _       _   _       _   _   _   _   _
+| |   |  _|  _| |_| |_  |_  |_    | | | |_| |_| |_|
+|_|   | |_   _|   |  _| |_| |_|   |   | |_|  _|   |
+`
+
+func TestLCDPatterns(t *testing.T) {
+	t.Parallel()
+
+	lines := strings.Split(strings.Trim(lcdGlyphs, "\n"), "\n")
+	labels := strings.Fields(lines[0])
+	glyphs := parseLCDArt(lines[1:])
+	if len(glyphs) != len(labels) {
+		t.Fatalf("%d glyphs for %d labels", len(glyphs), len(labels))
+	}
+	for i, bits := range glyphs {
+		if got, ok := lcdPatterns[bits]; !ok || string(got) != labels[i] {
+			t.Errorf("glyph %d (%s) reads as %q, ok %v", i, labels[i], got, ok)
+		}
+	}
+	if len(glyphs) != len(lcdPatterns) {
+		t.Errorf("%d glyphs drawn for %d patterns", len(glyphs), len(lcdPatterns))
+	}
+}
+
+// lcdArtCells are where each segment (a-g, bit order) is drawn in a digit's
+// three rows of three characters.
+var lcdArtCells = [7]struct {
+	row, col int
+	mark     byte
+}{
+	{0, 1, '_'}, {1, 2, '|'}, {2, 2, '|'}, {2, 1, '_'}, {2, 0, '|'}, {1, 0, '|'}, {1, 1, '_'},
+}
+
+// lcdArt draws digits' segments as text, a space between digits.
+func lcdArt(segments []int) string {
+	var rows [3]strings.Builder
+	for i, bits := range segments {
+		var cell [3][3]byte
+		for r := range cell {
+			cell[r] = [3]byte{' ', ' ', ' '}
+		}
+		for s, c := range lcdArtCells {
+			if bits&(1<<s) != 0 {
+				cell[c.row][c.col] = c.mark
+			}
+		}
+		for r := range rows {
+			if i > 0 {
+				rows[r].WriteByte(' ')
+			}
+			rows[r].Write(cell[r][:])
+		}
+	}
+	return trimArt(rows[0].String() + "\n" + rows[1].String() + "\n" + rows[2].String())
+}
+
+// parseLCDArt reads lcdArt's drawing back into segments.
+func parseLCDArt(lines []string) []int {
+	at := func(row, col int) byte {
+		if col < len(lines[row]) {
+			return lines[row][col]
+		}
+		return ' '
+	}
+	width := 0
+	for _, l := range lines {
+		width = max(width, len(l))
+	}
+	var segments []int
+	for x := 0; x < width; x += 4 {
+		bits := 0
+		for s, c := range lcdArtCells {
+			if at(c.row, x+c.col) == c.mark {
+				bits |= 1 << s
+			}
+		}
+		segments = append(segments, bits)
+	}
+	return segments
+}
+
+// trimArt drops trailing spaces and blank lines, which editors do not keep.
+func trimArt(art string) string {
+	lines := strings.Split(strings.Trim(art, "\n"), "\n")
+	for i, l := range lines {
+		lines[i] = strings.TrimRight(l, " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// sampleReading is the reading consensus voting would see in out's notes.
+func sampleReading(out ocrModelOutput) float64 {
+	v, _ := strconv.ParseFloat(sampleDigits(&out), 64)
+	return v
+}
+
+func TestLCDOCRProvider_NotAnImage(t *testing.T) {
+	t.Parallel()
+
+	if _, err := NewLCDOCRProvider().ReadMeter(t.Context(), &OCRInput{Image: []byte("RIFF....WEBP")}); err == nil {
+		t.Error("expected an error for bytes the reader cannot decode")
+	}
+}
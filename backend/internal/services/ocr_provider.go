@@ -0,0 +1,239 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"google.golang.org/genai"
+
+	"wattrent/internal/middleware"
+)
+
+// OCRProvider is one backend that can read a meter photo. OCRService asks its
+// providers in order and uses the first usable answer; see Process.
+type OCRProvider interface {
+	// Name identifies the provider and model, e.g. "gemini/gemini-2.5-flash-lite".
+	// It is what OCRResponse.Model and OCRAttempt.Model report.
+	Name() string
+	// ReadMeter returns the model's raw answer: the JSON object described by
+	// ocrResponseSchema. Parsing is left to OCRService so every provider is
+	// held to the same shape.
+	ReadMeter(ctx context.Context, in *OCRInput) (string, error)
+}
+
+// OCRInput is what a provider gets for one photo.
+type OCRInput struct {
+	Prompt          string
+	Image           []byte
+	MIMEType        string
+	PreviousReading float64
+	// Bank asks for every meter of a meter bank (see ocrPromptBank); a
+	// provider that reads one register only fails.
+	Bank bool
+	// Examples are earlier photos of the same meter with their correct
+	// readings, sent ahead of Image (see ocrPromptExamples). Providers that
+	// take one image only ignore them.
+	Examples []OCRExample
+}
+
+// OCRExample is a labelled photo: a corrected earlier read of the meter.
+type OCRExample struct {
+	Image    []byte
+	MIMEType string
+	Reading  float64
+}
+
+// ----------------------- Gemini (AI Studio / Vertex) -----------------------
+
+type genaiOCRProvider struct {
+	backend string
+	client  *genai.Client
+	model   string
+}
+
+// NewGenAIOCRProvider reads meters with a Gemini model through the genai SDK.
+// backend is "gemini" (AI Studio) or "vertex" and only labels the provider;
+// the client already knows where it talks to.
+func NewGenAIOCRProvider(backend string, client *genai.Client, model string) OCRProvider {
+	return &genaiOCRProvider{backend: backend, client: client, model: model}
+}
+
+func (p *genaiOCRProvider) Name() string { return p.backend + "/" + p.model }
+
+func (p *genaiOCRProvider) ReadMeter(ctx context.Context, in *OCRInput) (string, error) {
+	parts := []*genai.Part{{Text: in.Prompt}}
+	for i, e := range in.Examples {
+		parts = append(parts, &genai.Part{Text: examplesLabel(i, e)}, &genai.Part{InlineData: &genai.Blob{MIMEType: e.MIMEType, Data: e.Image}})
+	}
+	if len(in.Examples) > 0 {
+		parts = append(parts, &genai.Part{Text: ocrPhotoToReadLabel})
+	}
+	parts = append(parts, &genai.Part{InlineData: &genai.Blob{MIMEType: in.MIMEType, Data: in.Image}})
+	contents := []*genai.Content{{Role: "user", Parts: parts}}
+
+	resp, err := p.client.Models.GenerateContent(ctx, p.model, contents, &genai.GenerateContentConfig{
+		ResponseMIMEType: "application/json",
+		ResponseSchema:   ocrResponseSchema,
+		Temperature:      genai.Ptr(float32(0.0)), // Reading recognition needs to be deterministic
+	})
+	if err != nil {
+		return "", &middleware.AppError{HTTPStatus: 502, Key: "errors.ocr.upstream_failed", Cause: err}
+	}
+	return resp.Text(), nil
+}
+
+// ----------------------- OpenAI-compatible -----------------------
+
+type openAIOCRProvider struct {
+	baseURL    string
+	apiKey     string
+	model      string
+	httpClient *http.Client
+}
+
+// NewOpenAIOCRProvider reads meters through an OpenAI-compatible
+// /chat/completions endpoint with image input (OpenAI itself, or a
+// self-hosted gateway such as Ollama or vLLM). httpClient may be nil.
+func NewOpenAIOCRProvider(baseURL, apiKey, model string, httpClient *http.Client) OCRProvider {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 60 * time.Second}
+	}
+	return &openAIOCRProvider{baseURL: baseURL, apiKey: apiKey, model: model, httpClient: httpClient}
+}
+
+func (p *openAIOCRProvider) Name() string { return "openai/" + p.model }
+
+type openAIChatRequest struct {
+	Model          string              `json:"model"`
+	Messages       []openAIChatMessage `json:"messages"`
+	Temperature    float64             `json:"temperature"`
+	ResponseFormat map[string]string   `json:"response_format"`
+}
+
+type openAIChatMessage struct {
+	Role    string              `json:"role"`
+	Content []openAIContentPart `json:"content"`
+}
+
+type openAIContentPart struct {
+	Type     string          `json:"type"`
+	Text     string          `json:"text,omitempty"`
+	ImageURL *openAIImageURL `json:"image_url,omitempty"`
+}
+
+type openAIImageURL struct {
+	URL string `json:"url"`
+}
+
+func (p *openAIOCRProvider) ReadMeter(ctx context.Context, in *OCRInput) (string, error) {
+	// JSON mode does not take a schema, so the prompt spells out the shape.
+	prompt := in.Prompt + "\n\nAnswer with a JSON object with the keys \"reading\" (number), \"confidence\" (number), \"notes\" (string)," +
+		" \"digits\" (array of objects with \"value\", \"confidence\" and \"box_2d\"), \"register_box\" (array of 4 numbers)" +
+		" and, when asked for, \"serial\" (string), \"meter\" (object with \"display\", \"decimal_drum\" and \"model\")" +
+		" and \"meters\" (array of objects with \"reading\", \"confidence\", \"notes\", \"serial\" and \"box_2d\")."
+	content := []openAIContentPart{{Type: "text", Text: prompt}}
+	for i, e := range in.Examples {
+		content = append(content, openAIContentPart{Type: "text", Text: examplesLabel(i, e)}, openAIImagePart(e.MIMEType, e.Image))
+	}
+	if len(in.Examples) > 0 {
+		content = append(content, openAIContentPart{Type: "text", Text: ocrPhotoToReadLabel})
+	}
+	body, err := json.Marshal(openAIChatRequest{
+		Model:          p.model,
+		Messages:       []openAIChatMessage{{Role: "user", Content: append(content, openAIImagePart(in.MIMEType, in.Image))}},
+		ResponseFormat: map[string]string{"type": "json_object"},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+p.apiKey)
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return "", &middleware.AppError{HTTPStatus: 502, Key: "errors.ocr.upstream_failed", Cause: err}
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 256*1024))
+	if resp.StatusCode/100 != 2 {
+		return "", &middleware.AppError{
+			HTTPStatus: 502,
+			Key:        "errors.ocr.upstream_failed",
+			Cause:      fmt.Errorf("openai http %d: %s", resp.StatusCode, respBody),
+		}
+	}
+
+	var cr struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal(respBody, &cr); err != nil {
+		return "", &middleware.AppError{HTTPStatus: 502, Key: "errors.ocr.upstream_failed", Cause: fmt.Errorf("parse openai response: %w", err)}
+	}
+	if len(cr.Choices) == 0 {
+		return "", nil
+	}
+	return cr.Choices[0].Message.Content, nil
+}
+
+// openAIImagePart inlines an image as a data URL.
+func openAIImagePart(mime string, data []byte) openAIContentPart {
+	return openAIContentPart{Type: "image_url", ImageURL: &openAIImageURL{
+		URL: "data:" + mime + ";base64," + base64.StdEncoding.EncodeToString(data),
+	}}
+}
+
+// ----------------------- fake -----------------------
+
+type fakeOCRProvider struct{}
+
+// NewFakeOCRProvider reads no photo at all: it answers with a reading derived
+// from the image bytes and the previous reading, so the same photo always
+// gives the same result. For local dev and tests; config.Load keeps it out of
+// production.
+func NewFakeOCRProvider() OCRProvider { return fakeOCRProvider{} }
+
+func (fakeOCRProvider) Name() string { return "fake" }
+
+func (fakeOCRProvider) ReadMeter(_ context.Context, in *OCRInput) (string, error) {
+	h := fnv.New32a()
+	_, _ = h.Write(in.Image)
+	// 50-349 units since the previous reading: a plausible month.
+	reading := math.Floor(in.PreviousReading) + 50 + float64(h.Sum32()%300)
+	out := ocrModelOutput{Reading: reading, Confidence: 0.95, Notes: "fake provider"}
+	if in.Bank {
+		// Three meters in a row, a thousand units apart.
+		for i := 0; i < 3; i++ {
+			out.Meters = append(out.Meters, ocrModelBankMeter{
+				Reading:    reading + float64(1000*i),
+				Confidence: 0.95,
+				Serial:     "FAKE" + strconv.Itoa(i+1),
+				Box:        []float64{300, float64(50 + 320*i), 700, float64(310 + 320*i)},
+			})
+		}
+		out.Reading, out.Confidence = 0, 0
+	}
+	for _, d := range strconv.FormatFloat(reading, 'f', 0, 64) {
+		out.Digits = append(out.Digits, ocrModelDigit{Value: int(d - '0'), Confidence: 0.95})
+	}
+	raw, err := json.Marshal(out)
+	return string(raw), err
+}
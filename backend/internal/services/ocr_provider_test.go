@@ -0,0 +1,107 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestOpenAIOCRProvider(t *testing.T) {
+	t.Parallel()
+
+	var got openAIChatRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" || r.Header.Get("Authorization") != "Bearer sk-test" {
+			t.Errorf("request = %s %s auth=%q", r.Method, r.URL.Path, r.Header.Get("Authorization"))
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"content":"{\"reading\":36034,\"confidence\":0.9}"}}]}`))
+	}))
+	defer srv.Close()
+
+	p := NewOpenAIOCRProvider(srv.URL+"/v1", "sk-test", "gpt-4o-mini", srv.Client())
+	if p.Name() != "openai/gpt-4o-mini" {
+		t.Errorf("Name = %q", p.Name())
+	}
+	raw, err := p.ReadMeter(context.Background(), &OCRInput{Prompt: "read it", Image: []byte("Hello"), MIMEType: "image/png"})
+	if err != nil {
+		t.Fatalf("ReadMeter: %v", err)
+	}
+	if raw != `{"reading":36034,"confidence":0.9}` {
+		t.Errorf("raw = %q", raw)
+	}
+	if got.Model != "gpt-4o-mini" || len(got.Messages) != 1 || len(got.Messages[0].Content) != 2 {
+		t.Fatalf("request body = %+v", got)
+	}
+	if img := got.Messages[0].Content[1].ImageURL; img == nil || img.URL != "data:image/png;base64,SGVsbG8=" {
+		t.Errorf("image part = %+v", got.Messages[0].Content[1])
+	}
+	if !strings.HasPrefix(got.Messages[0].Content[0].Text, "read it") {
+		t.Errorf("prompt = %q", got.Messages[0].Content[0].Text)
+	}
+}
+
+func TestOpenAIOCRProvider_Examples(t *testing.T) {
+	t.Parallel()
+
+	var got openAIChatRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"content":"{}"}}]}`))
+	}))
+	defer srv.Close()
+
+	p := NewOpenAIOCRProvider(srv.URL, "sk-test", "gpt-4o-mini", srv.Client())
+	in := &OCRInput{Prompt: "read it", Image: []byte("Hello"), MIMEType: "image/png",
+		Examples: []OCRExample{{Image: []byte("Hi"), MIMEType: "image/jpeg", Reading: 36034}}}
+	if _, err := p.ReadMeter(context.Background(), in); err != nil {
+		t.Fatalf("ReadMeter: %v", err)
+	}
+	// prompt, example label, example, "photo to read", photo
+	parts := got.Messages[0].Content
+	if len(parts) != 5 || !strings.Contains(parts[1].Text, "36034") || parts[2].ImageURL == nil ||
+		parts[2].ImageURL.URL != "data:image/jpeg;base64,SGk=" || parts[4].ImageURL.URL != "data:image/png;base64,SGVsbG8=" {
+		t.Errorf("content = %+v, want the example ahead of the photo", parts)
+	}
+}
+
+func TestOpenAIOCRProvider_UpstreamError(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":"rate limited"}`, http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	p := NewOpenAIOCRProvider(srv.URL, "sk-test", "gpt-4o-mini", srv.Client())
+	if _, err := p.ReadMeter(context.Background(), &OCRInput{Image: []byte("x"), MIMEType: "image/jpeg"}); err == nil {
+		t.Fatal("expected an error on HTTP 429")
+	}
+}
+
+func TestFakeOCRProvider(t *testing.T) {
+	t.Parallel()
+
+	p := NewFakeOCRProvider()
+	in := &OCRInput{Image: []byte("meter photo"), PreviousReading: 36034}
+	first, err := p.ReadMeter(context.Background(), in)
+	if err != nil {
+		t.Fatalf("ReadMeter: %v", err)
+	}
+	again, _ := p.ReadMeter(context.Background(), in)
+	if first != again {
+		t.Errorf("same photo gave %q then %q", first, again)
+	}
+	out, err := parseOCROutput(first)
+	if err != nil {
+		t.Fatalf("fake output does not parse: %v", err)
+	}
+	if out.Reading < 36034+50 || out.Reading >= 36034+350 {
+		t.Errorf("reading = %v, want 50-349 above the previous reading", out.Reading)
+	}
+}
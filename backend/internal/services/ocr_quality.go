@@ -0,0 +1,94 @@
+package services
+
+import (
+	"image"
+
+	"wattrent/internal/middleware"
+	"wattrent/internal/models"
+)
+
+// qualityScoreDimension is the longest side photos are scored at, so the
+// sharpness score means the same for a 1 MP and a 12 MP shot.
+const qualityScoreDimension = 512
+
+// clippedLuma is the luminance from which a pixel counts as blown out.
+const clippedLuma = 250
+
+// qualityGate rejects photos not worth a model call. A zero threshold turns
+// its check off.
+type qualityGate struct {
+	minSharpness  float64
+	maxClipped    float64
+	minBrightness float64
+}
+
+// check returns the photo_quality error for the first threshold q fails, in
+// the order that tells the user what to fix: a dark photo also scores as
+// blurry, so darkness is reported first. A nil q (a format that was not
+// decoded) passes.
+func (g qualityGate) check(q *models.OCRPhotoQuality) error {
+	if q == nil {
+		return nil
+	}
+	key := ""
+	switch {
+	case g.minBrightness > 0 && q.Brightness < g.minBrightness:
+		key = "errors.ocr.photo_quality_dark"
+	case g.maxClipped > 0 && q.Clipped > g.maxClipped:
+		key = "errors.ocr.photo_quality_glare"
+	case g.minSharpness > 0 && q.Sharpness < g.minSharpness:
+		key = "errors.ocr.photo_quality_blurry"
+	default:
+		return nil
+	}
+	return &middleware.AppError{HTTPStatus: 422, Key: key}
+}
+
+// scorePhoto measures an upright photo at qualityScoreDimension:
+//   - Sharpness: variance of the 4-neighbour Laplacian of luminance. Edges
+//     (digit outlines) give large responses, a blurred photo has none.
+//   - Clipped: share of blown-out pixels, from glare on the meter glass or
+//     overexposure.
+//   - Brightness: mean luminance, 0 (black) to 1 (white).
+func scorePhoto(img *image.RGBA) *models.OCRPhotoQuality {
+	if max(img.Rect.Dx(), img.Rect.Dy()) > qualityScoreDimension {
+		img = downscale(img, qualityScoreDimension)
+	}
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	lum := make([]int, w*h)
+	sum, clipped := 0, 0
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			p := img.Pix[img.PixOffset(x, y):]
+			v := luma(p[0], p[1], p[2])
+			lum[y*w+x] = v
+			sum += v
+			if v >= clippedLuma {
+				clipped++
+			}
+		}
+	}
+
+	var lapSum, lapSq float64
+	n := 0
+	for y := 1; y < h-1; y++ {
+		for x := 1; x < w-1; x++ {
+			i := y*w + x
+			l := float64(lum[i-1] + lum[i+1] + lum[i-w] + lum[i+w] - 4*lum[i])
+			lapSum += l
+			lapSq += l * l
+			n++
+		}
+	}
+	sharpness := 0.0
+	if n > 0 {
+		mean := lapSum / float64(n)
+		sharpness = lapSq/float64(n) - mean*mean
+	}
+
+	return &models.OCRPhotoQuality{
+		Sharpness:  roundHundredth(sharpness),
+		Clipped:    roundHundredth(float64(clipped) / float64(w*h)),
+		Brightness: roundHundredth(float64(sum) / float64(w*h) / 255),
+	}
+}
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"encoding/base64"
+	"errors"
+	"image"
+	"image/color"
+	"testing"
+
+	"wattrent/internal/middleware"
+	"wattrent/internal/models"
+)
+
+// checkerboard is a w x h image of cell-sized squares alternating between
+// grey levels lo and hi: all edges, the sharpest photo there is.
+func checkerboard(w, h, cell int, lo, hi uint8) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := lo
+			if (x/cell+y/cell)%2 == 0 {
+				v = hi
+			}
+			img.Set(x, y, color.RGBA{R: v, G: v, B: v, A: 255})
+		}
+	}
+	return img
+}
+
+// flat is a w x h image of one grey level.
+func flat(w, h int, v uint8) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = v, v, v, 255
+	}
+	return img
+}
+
+func TestScorePhoto(t *testing.T) {
+	t.Parallel()
+
+	sharp := scorePhoto(checkerboard(64, 64, 4, 0, 255))
+	if sharp.Sharpness < 1000 || sharp.Clipped != 0.5 || sharp.Brightness != 0.5 {
+		t.Errorf("checkerboard = %+v, want sharp, half clipped, mid brightness", sharp)
+	}
+	grey := scorePhoto(flat(64, 64, 128))
+	if grey.Sharpness != 0 || grey.Clipped != 0 {
+		t.Errorf("flat grey = %+v, want no sharpness, nothing clipped", grey)
+	}
+	if black := scorePhoto(flat(64, 64, 0)); black.Brightness != 0 {
+		t.Errorf("black brightness = %v, want 0", black.Brightness)
+	}
+	if white := scorePhoto(flat(64, 64, 255)); white.Clipped != 1 || white.Brightness != 1 {
+		t.Errorf("white = %+v, want all clipped", white)
+	}
+
+	// Large photos are scored at qualityScoreDimension, so a bigger shot of
+	// the same scene scores alike.
+	big := scorePhoto(checkerboard(1024, 1024, 8, 0, 255))
+	if big.Sharpness < 1000 {
+		t.Errorf("large checkerboard sharpness = %v, want it scored after downscaling", big.Sharpness)
+	}
+}
+
+func TestQualityGate(t *testing.T) {
+	t.Parallel()
+
+	gate := qualityGate{minSharpness: 20, maxClipped: 0.2, minBrightness: 0.12}
+	tests := []struct {
+		name    string
+		quality *models.OCRPhotoQuality
+		wantKey string
+	}{
+		{name: "good", quality: &models.OCRPhotoQuality{Sharpness: 300, Clipped: 0.01, Brightness: 0.5}},
+		{name: "not decoded", quality: nil},
+		{name: "blurry", quality: &models.OCRPhotoQuality{Sharpness: 5, Clipped: 0.01, Brightness: 0.5}, wantKey: "errors.ocr.photo_quality_blurry"},
+		{name: "glare", quality: &models.OCRPhotoQuality{Sharpness: 300, Clipped: 0.4, Brightness: 0.8}, wantKey: "errors.ocr.photo_quality_glare"},
+		{name: "dark and blurry", quality: &models.OCRPhotoQuality{Sharpness: 1, Brightness: 0.03}, wantKey: "errors.ocr.photo_quality_dark"},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			err := gate.check(tc.quality)
+			if tc.wantKey == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			var appErr *middleware.AppError
+			if !errors.As(err, &appErr) || appErr.HTTPStatus != 422 || appErr.Key != tc.wantKey {
+				t.Errorf("err = %v, want 422 %s", err, tc.wantKey)
+			}
+		})
+	}
+
+	if err := (qualityGate{}).check(&models.OCRPhotoQuality{}); err != nil {
+		t.Errorf("zero thresholds: err = %v, want every check off", err)
+	}
+}
+
+func TestOCRService_QualityGate(t *testing.T) {
+	t.Parallel()
+
+	opts := OCROptions{MinSharpness: 20, MaxClipped: 0.2, MinBrightness: 0.12}
+	answer := `{"reading":36034,"confidence":0.9}`
+
+	t.Run("dark photo costs no model call", func(t *testing.T) {
+		t.Parallel()
+		p := &stubOCRProvider{name: "gemini/flash-lite", raw: answer}
+		svc := NewOCRService(nil, nil, []OCRProvider{p}, opts)
+		dark := base64.StdEncoding.EncodeToString(encodeTestJPEG(flat(64, 64, 10), nil))
+		_, err := svc.Process(t.Context(), "", &models.OCRRequest{ImageBase64: dark})
+		var appErr *middleware.AppError
+		if !errors.As(err, &appErr) || appErr.Key != "errors.ocr.photo_quality_dark" {
+			t.Errorf("err = %v, want errors.ocr.photo_quality_dark", err)
+		}
+		if p.calls != 0 {
+			t.Errorf("provider called %d times, want 0", p.calls)
+		}
+	})
+
+	t.Run("a good photo carries its scores", func(t *testing.T) {
+		t.Parallel()
+		p := &stubOCRProvider{name: "gemini/flash-lite", raw: answer}
+		svc := NewOCRService(nil, nil, []OCRProvider{p}, opts)
+		sharp := base64.StdEncoding.EncodeToString(encodeTestJPEG(checkerboard(64, 64, 8, 40, 200), nil))
+		resp, err := svc.Process(t.Context(), "", &models.OCRRequest{ImageBase64: sharp})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Quality == nil || resp.Quality.Sharpness < 20 {
+			t.Errorf("quality = %+v, want the photo's scores", resp.Quality)
+		}
+	})
+}
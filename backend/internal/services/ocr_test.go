@@ -1,6 +1,14 @@
 package services
 
-import "testing"
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"wattrent/internal/middleware"
+	"wattrent/internal/models"
+)
 
 func TestDecodeBase64Image(t *testing.T) {
 	t.Parallel()
@@ -13,9 +21,9 @@ func TestDecodeBase64Image(t *testing.T) {
 		wantData []byte
 	}{
 		{
-			name:     "raw base64 defaults to jpeg",
+			name:     "raw base64 claims no type",
 			input:    "SGVsbG8=", // "Hello"
-			wantMIME: "image/jpeg",
+			wantMIME: "",
 			wantData: []byte("Hello"),
 		},
 		{
@@ -70,7 +78,9 @@ func TestGuessMimeFromURL(t *testing.T) {
 		{input: "gs://bucket/foo.JPEG", want: "image/jpeg"},
 		{input: "gs://bucket/foo.png", want: "image/png"},
 		{input: "gs://bucket/foo.webp", want: "image/webp"},
-		{input: "gs://bucket/foo.heic", err: true},
+		{input: "gs://bucket/foo.heic", want: "image/heic"},
+		{input: "gs://bucket/foo.HEIF", want: "image/heic"},
+		{input: "gs://bucket/foo.gif", err: true},
 		{input: "gs://bucket/foo", err: true},
 	}
 
@@ -94,3 +104,279 @@ func TestGuessMimeFromURL(t *testing.T) {
 		})
 	}
 }
+
+func TestBuildOCRPrompt(t *testing.T) {
+	t.Parallel()
+
+	_, elec, ok := lookupMeterPrompt("")
+	if !ok {
+		t.Fatal("empty meter type should resolve to electricity")
+	}
+	if got := buildOCRPrompt(elec, 0); got != ocrPromptBase+ocrPromptDigits {
+		t.Error("first reading should use the bare electricity prompt without a hint")
+	}
+	if got := buildOCRPrompt(elec, 36034); !strings.Contains(got, "previous reading was 36034") || !strings.Contains(got, "kWh of it") {
+		t.Errorf("electricity hint missing or wrong unit: %q", got[len(ocrPromptBase):])
+	}
+
+	meterType, water, ok := lookupMeterPrompt(models.MeterTypeWater)
+	if !ok || meterType != models.MeterTypeWater || water.unit != "m3" {
+		t.Fatalf("water lookup = %q %+v %v", meterType, water.unit, ok)
+	}
+	got := buildOCRPrompt(water, 842.5)
+	if !strings.HasPrefix(got, ocrPromptWater) || !strings.Contains(got, "previous reading was 842.5") || !strings.Contains(got, "m3 of it") {
+		t.Errorf("water prompt = %q", got[len(ocrPromptWater):])
+	}
+
+	if _, _, ok := lookupMeterPrompt("steam"); ok {
+		t.Error("unknown meter type should not resolve")
+	}
+}
+
+// stubOCRProvider answers with a fixed raw text or error.
+type stubOCRProvider struct {
+	name     string
+	raw      string
+	err      error
+	calls    int
+	mime     string // MIME type of the last image read
+	prompt   string // last prompt
+	examples []OCRExample
+}
+
+func (p *stubOCRProvider) Name() string { return p.name }
+
+func (p *stubOCRProvider) ReadMeter(_ context.Context, in *OCRInput) (string, error) {
+	p.calls++
+	p.mime = in.MIMEType
+	p.prompt = in.Prompt
+	p.examples = in.Examples
+	return p.raw, p.err
+}
+
+func TestOCRService_ProviderFallback(t *testing.T) {
+	t.Parallel()
+
+	req := &models.OCRRequest{ImageBase64: testPhotoBase64}
+	quota := &middleware.AppError{HTTPStatus: 502, Key: "errors.ocr.upstream_failed"}
+
+	t.Run("falls back to the next provider", func(t *testing.T) {
+		t.Parallel()
+		down := &stubOCRProvider{name: "gemini/flash-lite", err: quota}
+		garbled := &stubOCRProvider{name: "vertex/flash-lite", raw: "not json"}
+		ok := &stubOCRProvider{name: "openai/gpt-4o-mini", raw: `{"reading":36034,"confidence":0.9}`}
+		unused := &stubOCRProvider{name: "fake"}
+		svc := NewOCRService(nil, nil, []OCRProvider{down, garbled, ok, unused})
+
+		resp, err := svc.Process(context.Background(), "u", req)
+		if err != nil {
+			t.Fatalf("Process: %v", err)
+		}
+		if resp.Model != "openai/gpt-4o-mini" || resp.Reading != 36034 {
+			t.Errorf("resp = %+v, want the openai answer", resp)
+		}
+		if unused.calls != 0 {
+			t.Error("providers after the one that answered should not be called")
+		}
+	})
+
+	t.Run("all providers fail", func(t *testing.T) {
+		t.Parallel()
+		svc := NewOCRService(nil, nil, []OCRProvider{
+			&stubOCRProvider{name: "a", raw: "not json"},
+			&stubOCRProvider{name: "b", err: quota},
+		})
+		_, err := svc.Process(context.Background(), "u", req)
+		var appErr *middleware.AppError
+		if !errors.As(err, &appErr) || appErr.Key != "errors.ocr.upstream_failed" {
+			t.Errorf("err = %v, want the last provider's error", err)
+		}
+	})
+
+	t.Run("no providers", func(t *testing.T) {
+		t.Parallel()
+		_, err := NewOCRService(nil, nil, nil).Process(context.Background(), "u", req)
+		var appErr *middleware.AppError
+		if !errors.As(err, &appErr) || appErr.HTTPStatus != 503 {
+			t.Errorf("err = %v, want 503", err)
+		}
+	})
+}
+
+func TestOCRService_Escalation(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		first         string
+		retry         string
+		retryErr      error
+		wantReading   float64
+		wantModel     string
+		wantEscalated bool
+	}{
+		{
+			name:        "confident and plausible: no retry",
+			first:       `{"reading":36200,"confidence":0.95}`,
+			wantReading: 36200, wantModel: "gemini/lite",
+		},
+		{
+			name:        "low confidence: stronger answer wins",
+			first:       `{"reading":36200,"confidence":0.5}`,
+			retry:       `{"reading":36280,"confidence":0.9}`,
+			wantReading: 36280, wantModel: "gemini/flash", wantEscalated: true,
+		},
+		{
+			name:        "below previous reading: plausible retry wins despite lower confidence",
+			first:       `{"reading":35034,"confidence":0.95}`,
+			retry:       `{"reading":36234,"confidence":0.85}`,
+			wantReading: 36234, wantModel: "gemini/flash", wantEscalated: true,
+		},
+		{
+			name:        "retry less confident: first answer kept",
+			first:       `{"reading":36200,"confidence":0.7}`,
+			retry:       `{"reading":36280,"confidence":0.6}`,
+			wantReading: 36200, wantModel: "gemini/lite", wantEscalated: true,
+		},
+		{
+			name:        "retry fails: first answer kept",
+			first:       `{"reading":36200,"confidence":0.5}`,
+			retryErr:    &middleware.AppError{HTTPStatus: 502, Key: "errors.ocr.upstream_failed"},
+			wantReading: 36200, wantModel: "gemini/lite", wantEscalated: true,
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			stronger := &stubOCRProvider{name: "gemini/flash", raw: tc.retry, err: tc.retryErr}
+			svc := NewOCRService(nil, nil, []OCRProvider{&stubOCRProvider{name: "gemini/lite", raw: tc.first}},
+				OCROptions{Escalation: []OCRProvider{stronger}})
+
+			resp, err := svc.Process(context.Background(), "u", &models.OCRRequest{ImageBase64: testPhotoBase64, PreviousReading: 36034})
+			if err != nil {
+				t.Fatalf("Process: %v", err)
+			}
+			if resp.Reading != tc.wantReading || resp.Model != tc.wantModel || resp.Escalated != tc.wantEscalated {
+				t.Errorf("resp = reading %v model %q escalated %v, want %v %q %v",
+					resp.Reading, resp.Model, resp.Escalated, tc.wantReading, tc.wantModel, tc.wantEscalated)
+			}
+			if !tc.wantEscalated && stronger.calls != 0 {
+				t.Error("stronger model should not be called")
+			}
+		})
+	}
+}
+
+func TestEscalationReason(t *testing.T) {
+	t.Parallel()
+
+	_, elec, _ := lookupMeterPrompt(models.MeterTypeElectricity)
+	tests := []struct {
+		name string
+		prev float64
+		out  ocrModelOutput
+		want models.OCREscalationReason
+	}{
+		{"fine", 36034, ocrModelOutput{Reading: 36300, Confidence: 0.9}, ""},
+		{"first reading, nothing to compare", 0, ocrModelOutput{Reading: 12, Confidence: 0.9}, ""},
+		{"low confidence", 36034, ocrModelOutput{Reading: 36300, Confidence: 0.6}, models.OCREscalationLowConfidence},
+		{"below previous", 36034, ocrModelOutput{Reading: 3603, Confidence: 0.9}, models.OCREscalationImplausible},
+		{"far above usual", 36034, ocrModelOutput{Reading: 360340, Confidence: 0.9}, models.OCREscalationImplausible},
+		{"unreadable", 0, ocrModelOutput{}, models.OCREscalationImplausible},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			if got := escalationReason(elec, tc.prev, &tc.out); got != tc.want {
+				t.Errorf("escalationReason = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestReadDigits(t *testing.T) {
+	t.Parallel()
+
+	digit := func(v int, c float64) ocrModelDigit {
+		return ocrModelDigit{Value: v, Confidence: c, Box: []float64{400, 100, 500, 150}}
+	}
+	tests := []struct {
+		name string
+		out  ocrModelOutput
+		want []int
+	}{
+		{"spells the reading", ocrModelOutput{Reading: 3604, Digits: []ocrModelDigit{digit(3, 0.9), digit(6, 0.9), digit(0, 0.4), digit(4, 0.9)}}, []int{3, 6, 0, 4}},
+		{"leading zeros", ocrModelOutput{Reading: 16, Digits: []ocrModelDigit{digit(0, 0.9), digit(0, 0.9), digit(1, 0.9), digit(6, 0.9)}}, []int{0, 0, 1, 6}},
+		{"fraction dropped", ocrModelOutput{Reading: 2531.6, Digits: []ocrModelDigit{digit(2, 0.9), digit(5, 0.9), digit(3, 0.9), digit(1, 0.9)}}, []int{2, 5, 3, 1}},
+		{"disagrees with the reading", ocrModelOutput{Reading: 3604, Digits: []ocrModelDigit{digit(3, 0.9), digit(6, 0.9), digit(0, 0.9)}}, nil},
+		{"not a digit", ocrModelOutput{Reading: 3, Digits: []ocrModelDigit{digit(13, 0.9)}}, nil},
+		{"no digits", ocrModelOutput{Reading: 3604}, nil},
+		{"unreadable", ocrModelOutput{Digits: []ocrModelDigit{digit(0, 0.9)}}, nil},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			got := readDigits(&tc.out)
+			if len(got) != len(tc.want) {
+				t.Fatalf("readDigits = %+v, want values %v", got, tc.want)
+			}
+			for i, d := range got {
+				if d.Value != tc.want[i] || d.Box == nil {
+					t.Errorf("digit %d = %+v, want %d with its box", i, d, tc.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestModelBox(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		in   []float64
+		want *models.OCRBox
+	}{
+		{"converts to fractions", []float64{250, 100, 500, 900}, &models.OCRBox{Left: 0.1, Top: 0.25, Right: 0.9, Bottom: 0.5}},
+		{"whole photo", []float64{0, 0, 1000, 1000}, &models.OCRBox{Right: 1, Bottom: 1}},
+		{"missing", nil, nil},
+		{"wrong length", []float64{0, 0, 10}, nil},
+		{"inverted", []float64{500, 100, 250, 900}, nil},
+		{"off the photo", []float64{0, 0, 1200, 500}, nil},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			got := modelBox(tc.in)
+			if (got == nil) != (tc.want == nil) || (got != nil && *got != *tc.want) {
+				t.Errorf("modelBox(%v) = %+v, want %+v", tc.in, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestOCRService_Digits(t *testing.T) {
+	t.Parallel()
+
+	p := &stubOCRProvider{name: "gemini/flash-lite", raw: `{"reading":16,"confidence":0.9,` +
+		`"digits":[{"value":0,"confidence":0.95,"box_2d":[400,300,500,350]},{"value":1,"confidence":0.95,"box_2d":[400,360,500,410]},{"value":6,"confidence":0.5,"box_2d":[400,420,500,470]}],` +
+		`"register_box":[390,290,510,480]}`}
+	svc := NewOCRService(nil, nil, []OCRProvider{p}, OCROptions{})
+	resp, err := svc.Process(t.Context(), "", &models.OCRRequest{ImageBase64: testPhotoBase64})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Digits) != 3 || resp.Digits[2].Value != 6 || resp.Digits[2].Confidence != 0.5 {
+		t.Errorf("digits = %+v, want 0 1 6 with the last one unsure", resp.Digits)
+	}
+	if b := resp.Digits[0].Box; b == nil || b.Left != 0.3 || b.Bottom != 0.5 {
+		t.Errorf("first digit box = %+v, want it in fractions of the photo", b)
+	}
+	if resp.RegisterBox == nil || resp.RegisterBox.Top != 0.39 {
+		t.Errorf("register box = %+v", resp.RegisterBox)
+	}
+}
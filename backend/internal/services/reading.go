@@ -0,0 +1,198 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"wattrent/internal/middleware"
+	"wattrent/internal/models"
+)
+
+// ReadingService operates on the reading log: /users/{uid}/readings/{readingId}.
+//
+// Readings are independent of bills so a tenant can log a mid-period value
+// without inventing a bill. BillService.Create can later use a logged reading
+// as the start or end of a period (see CreateBillRequest.EndReadingID).
+type ReadingService struct {
+	fs      *firestore.Client
+	storage photoDeleter
+}
+
+func NewReadingService(fs *firestore.Client, storage photoDeleter) *ReadingService {
+	return &ReadingService{fs: fs, storage: storage}
+}
+
+func readingsCol(fs *firestore.Client, uid string) *firestore.CollectionRef {
+	return fs.Collection("users").Doc(uid).Collection("readings")
+}
+
+// Create logs a reading. An empty meter type is stored as electricity so
+// List can filter on it.
+func (s *ReadingService) Create(ctx context.Context, uid string, req *models.CreateReadingRequest) (*models.Reading, error) {
+	now := time.Now().UTC()
+	meterType := req.MeterType
+	if meterType == "" {
+		meterType = models.MeterTypeElectricity
+	}
+	takenAt := now
+	if req.TakenAt != nil {
+		// A few minutes of clock skew between phone and server is normal.
+		if req.TakenAt.After(now.Add(5 * time.Minute)) {
+			return nil, &middleware.AppError{HTTPStatus: 400, Key: "errors.reading.in_future"}
+		}
+		takenAt = req.TakenAt.UTC()
+	}
+	if req.ImageURL != "" {
+		if _, err := userObject(uid, req.ImageURL); err != nil {
+			return nil, &middleware.AppError{HTTPStatus: 400, Key: "errors.reading.invalid_image_url", Cause: err}
+		}
+	}
+
+	reading := models.Reading{
+		MeterType: meterType,
+		Value:     req.Value,
+		TakenAt:   takenAt,
+		ImageURL:  req.ImageURL,
+		OCR:       req.OCR,
+		Note:      req.Note,
+		CreatedAt: now,
+	}
+	ref := readingsCol(s.fs, uid).NewDoc()
+	if _, err := ref.Set(ctx, reading); err != nil {
+		return nil, err
+	}
+	reading.ID = ref.ID
+	return &reading, nil
+}
+
+// List returns the user's readings, newest first. An empty meterType lists
+// every meter.
+func (s *ReadingService) List(ctx context.Context, uid string, meterType models.MeterType, limit int) ([]*models.Reading, error) {
+	if limit <= 0 || limit > 200 {
+		limit = 100
+	}
+
+	q := readingsCol(s.fs, uid).Query
+	if meterType != "" {
+		q = q.Where("meterType", "==", string(meterType))
+	}
+	iter := q.OrderBy("takenAt", firestore.Desc).Limit(limit).Documents(ctx)
+	defer iter.Stop()
+
+	readings := make([]*models.Reading, 0, limit)
+	for {
+		snap, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		r, err := docToReading(snap)
+		if err != nil {
+			return nil, err
+		}
+		readings = append(readings, r)
+	}
+	return readings, nil
+}
+
+// Delete removes a reading and its photo. Readings a bill was computed from
+// are kept: the bill's usage would no longer be traceable to anything.
+//
+// The photo is queued as a PhotoDeletion in the same transaction, like
+// BillService.purgeBill does, unless a bill shows the same photo.
+func (s *ReadingService) Delete(ctx context.Context, uid, readingID string) error {
+	ref := readingsCol(s.fs, uid).Doc(readingID)
+	billCols := []*firestore.CollectionRef{
+		s.fs.Collection("users").Doc(uid).Collection("bills"),
+		// Bills in the trash count: they can still be restored.
+		trashCol(s.fs, uid),
+	}
+
+	var queued *firestore.DocumentRef
+	var photo string
+	err := s.fs.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		queued, photo = nil, ""
+
+		snap, err := tx.Get(ref)
+		if err != nil {
+			if status.Code(err) == codes.NotFound {
+				return &middleware.AppError{HTTPStatus: 404, Key: "errors.reading.not_found"}
+			}
+			return err
+		}
+		r, err := docToReading(snap)
+		if err != nil {
+			return err
+		}
+
+		for _, bills := range billCols {
+			for _, field := range []string{"startReadingId", "endReadingId"} {
+				snaps, err := tx.Documents(bills.Where(field, "==", readingID).Limit(1)).GetAll()
+				if err != nil {
+					return err
+				}
+				if len(snaps) > 0 {
+					return &middleware.AppError{HTTPStatus: 409, Key: "errors.reading.used_by_bill"}
+				}
+			}
+		}
+
+		if r.ImageURL != "" {
+			if _, err := userObject(uid, r.ImageURL); err != nil {
+				// Logged before Create checked the path; never the user's to delete.
+				slog.Warn("reading delete: photo outside the user's folder left alone", "uid", uid, "reading", readingID, "path", r.ImageURL)
+				return tx.Delete(ref)
+			}
+			shared := false
+			for _, bills := range billCols {
+				snaps, err := tx.Documents(bills.Where("imageUrl", "==", r.ImageURL).Limit(1)).GetAll()
+				if err != nil {
+					return err
+				}
+				shared = shared || len(snaps) > 0
+			}
+			if !shared {
+				now := time.Now().UTC()
+				q := photoDeletionsCol(s.fs, uid).NewDoc()
+				if err := tx.Create(q, models.PhotoDeletion{
+					GCSPath:   r.ImageURL,
+					ReadingID: readingID,
+					CreatedAt: now,
+					UpdatedAt: now,
+				}); err != nil {
+					return err
+				}
+				queued, photo = q, r.ImageURL
+			}
+		}
+		return tx.Delete(ref)
+	})
+	if err != nil {
+		return err
+	}
+
+	if queued != nil {
+		if err := deleteQueuedPhoto(ctx, s.storage, uid, queued, photo); err != nil {
+			// Queued; BillService.retryPhotoDeletions picks it up.
+			slog.Warn("reading photo delete failed, queued for retry", "uid", uid, "reading", readingID, "path", photo, "err", err)
+		}
+	}
+	return nil
+}
+
+func docToReading(snap *firestore.DocumentSnapshot) (*models.Reading, error) {
+	var r models.Reading
+	if err := snap.DataTo(&r); err != nil {
+		return nil, err
+	}
+	r.ID = snap.Ref.ID
+	return &r, nil
+}
@@ -0,0 +1,179 @@
+package services
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"wattrent/internal/middleware"
+	"wattrent/internal/models"
+)
+
+// RoomService operates on /users/{uid}/rooms/{roomId}: the rooms a landlord
+// bills from the meters of a meter bank. A bank read maps its meters to them
+// (see mapBankRooms), and a bill made from one of those meters is that
+// room's.
+type RoomService struct {
+	fs *firestore.Client
+}
+
+func NewRoomService(fs *firestore.Client) *RoomService {
+	return &RoomService{fs: fs}
+}
+
+// maxRooms caps the rooms of one user: as many as a bank photo has meters.
+const maxRooms = maxBankMeters
+
+func roomsCol(fs *firestore.Client, uid string) *firestore.CollectionRef {
+	return fs.Collection("users").Doc(uid).Collection("rooms")
+}
+
+// List returns the user's rooms in label order.
+func (s *RoomService) List(ctx context.Context, uid string) ([]*models.Room, error) {
+	iter := roomsCol(s.fs, uid).OrderBy("label", firestore.Asc).Documents(ctx)
+	defer iter.Stop()
+	out := []*models.Room{}
+	for {
+		snap, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		r, err := docToRoom(snap)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, nil
+}
+
+// Create registers a room. Labels are unique per user.
+func (s *RoomService) Create(ctx context.Context, uid string, req *models.SaveRoomRequest) (*models.Room, error) {
+	return s.save(ctx, uid, roomsCol(s.fs, uid).NewDoc(), req, true)
+}
+
+// Update replaces a room. Bills made for it keep the label they were made
+// with.
+func (s *RoomService) Update(ctx context.Context, uid, roomID string, req *models.SaveRoomRequest) (*models.Room, error) {
+	return s.save(ctx, uid, roomsCol(s.fs, uid).Doc(roomID), req, false)
+}
+
+func (s *RoomService) save(ctx context.Context, uid string, ref *firestore.DocumentRef, req *models.SaveRoomRequest, create bool) (*models.Room, error) {
+	if uid == "" {
+		return nil, middleware.ErrUnauthorized
+	}
+	now := time.Now().UTC()
+	room := &models.Room{
+		Label:     strings.TrimSpace(req.Label),
+		Serial:    strings.TrimSpace(req.Serial),
+		Position:  req.Position,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := validateRoom(room); err != nil {
+		return nil, err
+	}
+
+	err := s.fs.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		snaps, err := tx.Documents(roomsCol(s.fs, uid)).GetAll()
+		if err != nil {
+			return err
+		}
+		rooms := make([]*models.Room, 0, len(snaps))
+		for _, snap := range snaps {
+			r, err := docToRoom(snap)
+			if err != nil {
+				return err
+			}
+			rooms = append(rooms, r)
+		}
+		i := slices.IndexFunc(rooms, func(r *models.Room) bool { return r.ID == ref.ID })
+		if !create {
+			if i < 0 {
+				return &middleware.AppError{HTTPStatus: 404, Key: "errors.room.not_found"}
+			}
+			room.CreatedAt = rooms[i].CreatedAt
+		}
+		if err := checkRoomFits(rooms, ref.ID, room.Label); err != nil {
+			return err
+		}
+		return tx.Set(ref, room)
+	})
+	if err != nil {
+		return nil, err
+	}
+	room.ID = ref.ID
+	return room, nil
+}
+
+// Delete forgets a room. Its bills stay, with the label they were made with.
+func (s *RoomService) Delete(ctx context.Context, uid, roomID string) error {
+	_, err := roomsCol(s.fs, uid).Doc(roomID).Delete(ctx)
+	if err != nil && status.Code(err) != codes.NotFound {
+		return err
+	}
+	return nil
+}
+
+// validateRoom checks the fields of a room: a label, and a serial number or
+// a position to find its meter by.
+func validateRoom(r *models.Room) error {
+	if r.Label == "" || utf8.RuneCountInString(r.Label) > maxMeterLabelLen ||
+		utf8.RuneCountInString(r.Serial) > maxMeterLabelLen || r.Position < 0 || r.Position > maxBankMeters ||
+		(r.Serial == "" && r.Position == 0) {
+		return &middleware.AppError{HTTPStatus: 400, Key: "errors.room.invalid"}
+	}
+	return nil
+}
+
+// checkRoomFits reports whether a room with label can be saved as roomID
+// next to the user's other rooms: labels are unique, and there are at most
+// maxRooms.
+func checkRoomFits(rooms []*models.Room, roomID, label string) error {
+	others := 0
+	for _, r := range rooms {
+		if r.ID == roomID {
+			continue
+		}
+		if r.Label == label {
+			return &middleware.AppError{HTTPStatus: 409, Key: "errors.room.label_taken"}
+		}
+		others++
+	}
+	if others >= maxRooms {
+		return &middleware.AppError{HTTPStatus: 409, Key: "errors.room.too_many"}
+	}
+	return nil
+}
+
+func docToRoom(snap *firestore.DocumentSnapshot) (*models.Room, error) {
+	var r models.Room
+	if err := snap.DataTo(&r); err != nil {
+		return nil, err
+	}
+	r.ID = snap.Ref.ID
+	return &r, nil
+}
+
+// txGetRoom reads the room a bank meter was mapped to, for a bill made from
+// it: a room deleted since the read takes no more bills.
+func txGetRoom(tx *firestore.Transaction, ref *firestore.DocumentRef) (*models.Room, error) {
+	snap, err := tx.Get(ref)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, &middleware.AppError{HTTPStatus: 400, Key: "errors.bill.room_not_found"}
+		}
+		return nil, err
+	}
+	return docToRoom(snap)
+}
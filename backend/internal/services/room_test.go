@@ -0,0 +1,90 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"wattrent/internal/middleware"
+	"wattrent/internal/models"
+)
+
+func TestValidateRoom(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		room    models.Room
+		wantErr bool
+	}{
+		{name: "by serial", room: models.Room{Label: "2F", Serial: "5208811"}},
+		{name: "by position", room: models.Room{Label: "3F", Position: 2}},
+		{name: "no label", room: models.Room{Position: 1}, wantErr: true},
+		{name: "neither serial nor position", room: models.Room{Label: "2F"}, wantErr: true},
+		{name: "negative position", room: models.Room{Label: "2F", Serial: "1", Position: -1}, wantErr: true},
+		{name: "position past the last meter", room: models.Room{Label: "2F", Position: maxBankMeters + 1}, wantErr: true},
+		{name: "label too long", room: models.Room{Label: strings.Repeat("房", maxMeterLabelLen+1), Position: 1}, wantErr: true},
+		{name: "serial too long", room: models.Room{Label: "2F", Serial: strings.Repeat("9", maxMeterLabelLen+1)}, wantErr: true},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			err := validateRoom(&tc.room)
+			if !tc.wantErr {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			var appErr *middleware.AppError
+			if !errors.As(err, &appErr) || appErr.HTTPStatus != 400 || appErr.Key != "errors.room.invalid" {
+				t.Errorf("err = %v, want 400 errors.room.invalid", err)
+			}
+		})
+	}
+}
+
+func TestCheckRoomFits(t *testing.T) {
+	t.Parallel()
+
+	rooms := []*models.Room{{ID: "a", Label: "2F"}, {ID: "b", Label: "3F"}}
+	full := make([]*models.Room, maxRooms)
+	for i := range full {
+		full[i] = &models.Room{ID: fmt.Sprint(i), Label: fmt.Sprintf("%dF", i)}
+	}
+	tests := []struct {
+		name    string
+		rooms   []*models.Room
+		id      string
+		label   string
+		wantKey string
+	}{
+		{name: "new label", rooms: rooms, id: "c", label: "4F"},
+		{name: "label taken", rooms: rooms, id: "c", label: "3F", wantKey: "errors.room.label_taken"},
+		{name: "own label kept", rooms: rooms, id: "b", label: "3F"},
+		{name: "renamed to another's", rooms: rooms, id: "b", label: "2F", wantKey: "errors.room.label_taken"},
+		{name: "too many", rooms: full, id: "new", label: "roof", wantKey: "errors.room.too_many"},
+		{name: "update when full", rooms: full, id: "0", label: "roof"},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			err := checkRoomFits(tc.rooms, tc.id, tc.label)
+			if tc.wantKey == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			var appErr *middleware.AppError
+			if !errors.As(err, &appErr) || appErr.HTTPStatus != 409 || appErr.Key != tc.wantKey {
+				t.Errorf("err = %v, want 409 %s", err, tc.wantKey)
+			}
+		})
+	}
+}
@@ -12,6 +12,7 @@ import (
 
 	"wattrent/internal/middleware"
 	"wattrent/internal/models"
+	"wattrent/internal/money"
 )
 
 // SettingsService operates on /users/{uid}/settings/current.
@@ -45,6 +46,7 @@ func (s *SettingsService) Get(ctx context.Context, uid string) (*models.UserSett
 	if err := snap.DataTo(&settings); err != nil {
 		return nil, err
 	}
+	migrateLegacySettings(&settings)
 	return &settings, nil
 }
 
@@ -53,6 +55,23 @@ func (s *SettingsService) Save(ctx context.Context, uid string, settings *models
 	if uid == "" {
 		return middleware.ErrUnauthorized
 	}
+	if err := validateBillingCycle(settings.BillingCycle, settings.BillingAnchorDay); err != nil {
+		return err
+	}
+	if err := validateTimezone(settings.Timezone); err != nil {
+		return err
+	}
+	if err := validateCurrency(settings.Currency); err != nil {
+		return err
+	}
+	if err := validateRegion(settings.Region); err != nil {
+		return err
+	}
+	if err := validateUtilityMeters(settings.PreviousReadings, settings.DefaultUtilityRates); err != nil {
+		return err
+	}
+	settings.Currency = settings.Currency.OrDefault()
+	settings.LegacyDefaultRent = settings.DefaultRent.Float64()
 	settings.UpdatedAt = time.Now().UTC()
 	_, err := s.settingsRef(uid).Set(ctx, settings)
 	return err
@@ -60,16 +79,60 @@ func (s *SettingsService) Save(ctx context.Context, uid string, settings *models
 
 // Patch performs a partial update; nil fields are left untouched.
 func (s *SettingsService) Patch(ctx context.Context, uid string, req *models.UpdateSettingsRequest) (*models.UserSettings, error) {
+	var (
+		cycle     models.BillingCycle
+		anchorDay int
+	)
+	if req.BillingCycle != nil {
+		cycle = *req.BillingCycle
+	}
+	if req.BillingAnchorDay != nil {
+		anchorDay = *req.BillingAnchorDay
+	}
+	if err := validateBillingCycle(cycle, anchorDay); err != nil {
+		return nil, err
+	}
+	if req.Timezone != nil {
+		if err := validateTimezone(*req.Timezone); err != nil {
+			return nil, err
+		}
+	}
+	if req.Currency != nil {
+		if err := validateCurrency(*req.Currency); err != nil {
+			return nil, err
+		}
+	}
+	if req.Region != nil {
+		if err := validateRegion(*req.Region); err != nil {
+			return nil, err
+		}
+	}
+	if err := validateUtilityMeters(req.PreviousReadings, req.DefaultUtilityRates); err != nil {
+		return nil, err
+	}
+
 	updates := make([]firestore.Update, 0, 8)
 	if req.DefaultElectricityRate != nil {
 		updates = append(updates, firestore.Update{Path: "defaultElectricityRate", Value: *req.DefaultElectricityRate})
 	}
 	if req.DefaultRent != nil {
-		updates = append(updates, firestore.Update{Path: "defaultRent", Value: *req.DefaultRent})
+		updates = append(updates,
+			firestore.Update{Path: "defaultRentMinor", Value: int64(*req.DefaultRent)},
+			firestore.Update{Path: "defaultRent", Value: req.DefaultRent.Float64()},
+		)
+	}
+	if req.Currency != nil {
+		updates = append(updates, firestore.Update{Path: "currency", Value: string(req.Currency.OrDefault())})
 	}
 	if req.PreviousMeterReading != nil {
 		updates = append(updates, firestore.Update{Path: "previousMeterReading", Value: *req.PreviousMeterReading})
 	}
+	for meterType, v := range req.PreviousReadings {
+		updates = append(updates, firestore.Update{FieldPath: firestore.FieldPath{"previousReadings", string(meterType)}, Value: v})
+	}
+	for meterType, v := range req.DefaultUtilityRates {
+		updates = append(updates, firestore.Update{FieldPath: firestore.FieldPath{"defaultUtilityRates", string(meterType)}, Value: v})
+	}
 	if req.LandlordName != nil {
 		updates = append(updates, firestore.Update{Path: "landlordName", Value: *req.LandlordName})
 	}
@@ -79,6 +142,18 @@ func (s *SettingsService) Patch(ctx context.Context, uid string, req *models.Upd
 	if req.MessageTemplate != nil {
 		updates = append(updates, firestore.Update{Path: "messageTemplate", Value: *req.MessageTemplate})
 	}
+	if req.BillingCycle != nil {
+		updates = append(updates, firestore.Update{Path: "billingCycle", Value: *req.BillingCycle})
+	}
+	if req.BillingAnchorDay != nil {
+		updates = append(updates, firestore.Update{Path: "billingAnchorDay", Value: *req.BillingAnchorDay})
+	}
+	if req.Timezone != nil {
+		updates = append(updates, firestore.Update{Path: "timezone", Value: *req.Timezone})
+	}
+	if req.Region != nil {
+		updates = append(updates, firestore.Update{Path: "region", Value: *req.Region})
+	}
 	if req.SetupCompleted != nil {
 		updates = append(updates, firestore.Update{Path: "setupCompleted", Value: *req.SetupCompleted})
 	}
@@ -91,6 +166,15 @@ func (s *SettingsService) Patch(ctx context.Context, uid string, req *models.Upd
 	if req.AutoBackup != nil {
 		updates = append(updates, firestore.Update{Path: "autoBackup", Value: *req.AutoBackup})
 	}
+	if req.OCRLearnFromCorrections != nil {
+		updates = append(updates, firestore.Update{Path: "ocrLearnFromCorrections", Value: *req.OCRLearnFromCorrections})
+		if !*req.OCRLearnFromCorrections {
+			// Turning learning off forgets what it kept.
+			if err := deleteOCRCorrections(ctx, s.fs, uid); err != nil {
+				return nil, err
+			}
+		}
+	}
 
 	if len(updates) == 0 {
 		return s.Get(ctx, uid)
@@ -120,10 +204,26 @@ func (s *SettingsService) applyPatchToStruct(dst *models.UserSettings, req *mode
 	}
 	if req.DefaultRent != nil {
 		dst.DefaultRent = *req.DefaultRent
+		dst.LegacyDefaultRent = req.DefaultRent.Float64()
+	}
+	if req.Currency != nil {
+		dst.Currency = req.Currency.OrDefault()
 	}
 	if req.PreviousMeterReading != nil {
 		dst.PreviousMeterReading = *req.PreviousMeterReading
 	}
+	for meterType, v := range req.PreviousReadings {
+		if dst.PreviousReadings == nil {
+			dst.PreviousReadings = make(map[models.MeterType]float64)
+		}
+		dst.PreviousReadings[meterType] = v
+	}
+	for meterType, v := range req.DefaultUtilityRates {
+		if dst.DefaultUtilityRates == nil {
+			dst.DefaultUtilityRates = make(map[models.MeterType]float64)
+		}
+		dst.DefaultUtilityRates[meterType] = v
+	}
 	if req.LandlordName != nil {
 		dst.LandlordName = *req.LandlordName
 	}
@@ -133,6 +233,18 @@ func (s *SettingsService) applyPatchToStruct(dst *models.UserSettings, req *mode
 	if req.MessageTemplate != nil {
 		dst.MessageTemplate = *req.MessageTemplate
 	}
+	if req.BillingCycle != nil {
+		dst.BillingCycle = *req.BillingCycle
+	}
+	if req.BillingAnchorDay != nil {
+		dst.BillingAnchorDay = *req.BillingAnchorDay
+	}
+	if req.Timezone != nil {
+		dst.Timezone = *req.Timezone
+	}
+	if req.Region != nil {
+		dst.Region = *req.Region
+	}
 	if req.SetupCompleted != nil {
 		dst.SetupCompleted = *req.SetupCompleted
 	}
@@ -145,6 +257,9 @@ func (s *SettingsService) applyPatchToStruct(dst *models.UserSettings, req *mode
 	if req.AutoBackup != nil {
 		dst.AutoBackup = *req.AutoBackup
 	}
+	if req.OCRLearnFromCorrections != nil {
+		dst.OCRLearnFromCorrections = *req.OCRLearnFromCorrections
+	}
 }
 
 // SetPreviousMeterReading syncs the "previous reading" inside settings after a
@@ -157,6 +272,79 @@ func (s *SettingsService) SetPreviousMeterReading(ctx context.Context, uid strin
 	return err
 }
 
+// validateBillingCycle rejects cycle settings resolvePeriod cannot honour.
+// An empty cycle and a zero anchor day both mean "use the default".
+func validateBillingCycle(cycle models.BillingCycle, anchorDay int) error {
+	if !validBillingCycle(cycle) {
+		return &middleware.AppError{HTTPStatus: 400, Key: "errors.settings.invalid_billing_cycle"}
+	}
+	if anchorDay < 0 || anchorDay > maxBillingAnchorDay {
+		return &middleware.AppError{HTTPStatus: 400, Key: "errors.settings.invalid_anchor_day"}
+	}
+	return nil
+}
+
+// validateTimezone accepts an IANA zone name or empty (= default zone). "Local"
+// is rejected because it would mean the server's zone, not the user's.
+func validateTimezone(name string) error {
+	if name == "Local" {
+		return &middleware.AppError{HTTPStatus: 400, Key: "errors.settings.invalid_timezone"}
+	}
+	if _, err := loadTimezone(name); err != nil {
+		return &middleware.AppError{HTTPStatus: 400, Key: "errors.settings.invalid_timezone", Cause: err}
+	}
+	return nil
+}
+
+// validateCurrency accepts a supported ISO 4217 code or empty (= default).
+func validateCurrency(c money.Currency) error {
+	if c != "" && !c.Valid() {
+		return &middleware.AppError{HTTPStatus: 400, Key: "errors.settings.invalid_currency"}
+	}
+	return nil
+}
+
+// validateRegion accepts a region the emission table has factors for, or
+// empty (= default region).
+func validateRegion(region string) error {
+	if region != "" && !validEmissionRegion(region) {
+		return &middleware.AppError{HTTPStatus: 400, Key: "errors.settings.invalid_region"}
+	}
+	return nil
+}
+
+// validateUtilityMeters checks the per-meter maps: only water and gas have
+// entries there (electricity has its own fields), readings can't be negative
+// and rates must be positive.
+func validateUtilityMeters(prevReadings, rates map[models.MeterType]float64) error {
+	for meterType, v := range prevReadings {
+		if !isUtilityMeter(meterType) || v < 0 {
+			return &middleware.AppError{HTTPStatus: 400, Key: "errors.settings.invalid_utility_meter"}
+		}
+	}
+	for meterType, v := range rates {
+		if !isUtilityMeter(meterType) || v <= 0 {
+			return &middleware.AppError{HTTPStatus: 400, Key: "errors.settings.invalid_utility_meter"}
+		}
+	}
+	return nil
+}
+
+func isUtilityMeter(t models.MeterType) bool {
+	return t == models.MeterTypeWater || t == models.MeterTypeGas
+}
+
+// migrateLegacySettings fills the money fields of a settings document written
+// before they existed from the float64 defaultRent. Presence of the minor
+// field is judged by value rather than by currency, because a PATCH of only
+// the currency on an old document sets one without the other.
+func migrateLegacySettings(settings *models.UserSettings) {
+	settings.Currency = settings.Currency.OrDefault()
+	if settings.DefaultRent == 0 && settings.LegacyDefaultRent != 0 {
+		settings.DefaultRent = money.FromFloat(settings.LegacyDefaultRent)
+	}
+}
+
 // Delete removes the settings (resetting them to defaults).
 func (s *SettingsService) Delete(ctx context.Context, uid string) error {
 	_, err := s.settingsRef(uid).Delete(ctx)
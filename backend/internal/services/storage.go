@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"regexp"
+	"strings"
 	"time"
 
 	"cloud.google.com/go/storage"
@@ -14,6 +16,8 @@ import (
 // StorageService handles meter photos: signed upload / signed download.
 //
 // Path convention: users/{uid}/bills/{billId}.{ext} (the extension comes from contentType).
+// Renditions derived from a photo (thumbnails and the like) are stored next to
+// it as {name}_{variant}.{ext}, so they can be found from the photo's path.
 //
 // Signing relies on the IAM Credentials API (signBlob); the running service
 // account must have roles/iam.serviceAccountTokenCreator on itself (Terraform
@@ -23,6 +27,17 @@ type StorageService struct {
 	bucketName string
 }
 
+// billIDPattern is the billId a client may sign an upload for. It becomes
+// the photo's object name and, through CreateFromPhoto, a bill's document
+// ID, so it has no "/" or "."; nor "_", which separates a photo's name from
+// its renditions' (see derivedPrefix): deleting b.jpg would take b_x.jpg too.
+var billIDPattern = regexp.MustCompile(`^[A-Za-z0-9-]{1,64}$`)
+
+// ValidBillID reports whether id can name a bill photo upload.
+func ValidBillID(id string) bool {
+	return billIDPattern.MatchString(id)
+}
+
 func NewStorageService(client *storage.Client, bucketName string) *StorageService {
 	return &StorageService{
 		client:     client,
@@ -33,7 +48,7 @@ func NewStorageService(client *storage.Client, bucketName string) *StorageServic
 // SignedUploadURL produces a PUT signed URL the frontend can PUT the image to directly.
 //
 // Constraints:
-//   - contentType is restricted to image/jpeg / image/png / image/webp
+//   - contentType is restricted to image/jpeg / image/png / image/webp / image/heic
 //   - Validity: 15 minutes
 //   - Only writes to users/{uid}/bills/{billId}.{ext} are allowed
 func (s *StorageService) SignedUploadURL(ctx context.Context, uid, billID, contentType string) (uploadURL, gcsPath string, expiresAt time.Time, err error) {
@@ -41,6 +56,9 @@ func (s *StorageService) SignedUploadURL(ctx context.Context, uid, billID, conte
 	if !ok {
 		return "", "", time.Time{}, fmt.Errorf("unsupported contentType: %s", contentType)
 	}
+	if !ValidBillID(billID) {
+		return "", "", time.Time{}, fmt.Errorf("invalid billId: %q", billID)
+	}
 
 	objectPath := fmt.Sprintf("users/%s/bills/%s.%s", uid, billID, ext)
 	expiresAt = time.Now().Add(15 * time.Minute)
@@ -80,11 +98,16 @@ func (s *StorageService) SignedDownloadURL(ctx context.Context, gcsPath string)
 	return url, expiresAt, nil
 }
 
-// DownloadObject downloads the bytes of an object from a gs:// path. Used
+// ErrObjectTooLarge is returned by DownloadObject for an object over its
+// size limit.
+var ErrObjectTooLarge = errors.New("object too large")
+
+// DownloadObject downloads the bytes of an object from a gs:// path, up to
+// maxBytes (ErrObjectTooLarge past that). Used
 // mainly so the OCR service can hand the raw image to the Gemini Developer
 // API (which cannot read gs:// directly). Also returns the contentType for
 // the MIME header.
-func (s *StorageService) DownloadObject(ctx context.Context, gcsPath string) (data []byte, contentType string, err error) {
+func (s *StorageService) DownloadObject(ctx context.Context, gcsPath string, maxBytes int64) (data []byte, contentType string, err error) {
 	bucket, object, err := parseGCSPath(gcsPath, s.bucketName)
 	if err != nil {
 		return nil, "", err
@@ -95,6 +118,9 @@ func (s *StorageService) DownloadObject(ctx context.Context, gcsPath string) (da
 	if err != nil {
 		return nil, "", fmt.Errorf("stat %s: %w", gcsPath, err)
 	}
+	if attrs.Size > maxBytes {
+		return nil, "", fmt.Errorf("%s is %d bytes: %w", gcsPath, attrs.Size, ErrObjectTooLarge)
+	}
 	contentType = attrs.ContentType
 	if contentType == "" {
 		contentType = "application/octet-stream"
@@ -106,13 +132,55 @@ func (s *StorageService) DownloadObject(ctx context.Context, gcsPath string) (da
 	}
 	defer r.Close()
 
-	data, err = io.ReadAll(r)
+	// The object may have been replaced since the stat; never read past the limit.
+	data, err = io.ReadAll(io.LimitReader(r, maxBytes+1))
 	if err != nil {
 		return nil, "", fmt.Errorf("read %s: %w", gcsPath, err)
 	}
+	if int64(len(data)) > maxBytes {
+		return nil, "", fmt.Errorf("%s: %w", gcsPath, ErrObjectTooLarge)
+	}
 	return data, contentType, nil
 }
 
+// DeletePhoto deletes uid's photo at a gs:// path in the meter bucket together
+// with its derived renditions. A path outside users/{uid}/ is refused: bill
+// photo paths come from the client. Objects that are already gone count as
+// deleted, so a retry after a partial failure converges.
+func (s *StorageService) DeletePhoto(ctx context.Context, uid, gcsPath string) error {
+	bucketName, _, err := parseGCSPath(gcsPath, s.bucketName)
+	if err != nil {
+		return err
+	}
+	object, err := userObject(uid, gcsPath)
+	if err != nil {
+		return err
+	}
+	bucket := s.client.Bucket(bucketName)
+
+	// Renditions first: if the photo went first and a rendition failed, the
+	// retry could no longer tell the renditions apart from nothing.
+	prefix := derivedPrefix(object)
+	it := bucket.Objects(ctx, &storage.Query{Prefix: prefix})
+	for {
+		attrs, err := it.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("list objects %s: %w", prefix, err)
+		}
+		if err := bucket.Object(attrs.Name).Delete(ctx); err != nil && !errors.Is(err, storage.ErrObjectNotExist) {
+			return fmt.Errorf("delete %s: %w", attrs.Name, err)
+		}
+	}
+
+	if err := bucket.Object(object).Delete(ctx); err != nil && !errors.Is(err, storage.ErrObjectNotExist) {
+		return fmt.Errorf("delete %s: %w", gcsPath, err)
+	}
+	return nil
+}
+
 // DeleteUserObjects deletes every object stored under a user's prefix
 // (users/{uid}/...). Called when a user deletes their account. It keeps going
 // on individual delete failures and returns the first error encountered, so a
@@ -157,11 +225,39 @@ func imageExt(contentType string) (string, bool) {
 		return "png", true
 	case "image/webp":
 		return "webp", true
+	case "image/heic", "image/heif":
+		return "heic", true
 	default:
 		return "", false
 	}
 }
 
+// derivedPrefix is the object-name prefix of a photo's renditions:
+// users/u/bills/b1.jpg -> users/u/bills/b1_ (which does not match b10.jpg).
+func derivedPrefix(object string) string {
+	base := object
+	if i := strings.LastIndexByte(object, '.'); i > strings.LastIndexByte(object, '/') {
+		base = object[:i]
+	}
+	return base + "_"
+}
+
+// userObject returns the object name of gcsPath when it is one of uid's
+// objects (users/{uid}/...). gs:// paths from clients go through it before
+// they are stored: anything stored may later be read, or deleted, on the
+// user's behalf.
+func userObject(uid, gcsPath string) (string, error) {
+	_, object, err := parseGCSPath(gcsPath, "")
+	if err != nil {
+		return "", err
+	}
+	prefix := "users/" + uid + "/"
+	if uid == "" || !strings.HasPrefix(object, prefix) || strings.HasSuffix(object, "/") {
+		return "", fmt.Errorf("%s is not in %s", gcsPath, prefix)
+	}
+	return object, nil
+}
+
 func parseGCSPath(gcsPath, expectedBucket string) (bucket, object string, err error) {
 	const prefix = "gs://"
 	if len(gcsPath) <= len(prefix) || gcsPath[:len(prefix)] != prefix {
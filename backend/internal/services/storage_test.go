@@ -16,7 +16,7 @@ func TestImageExt(t *testing.T) {
 		"image/jpg":          {want: "jpg", ok: true},
 		"image/png":          {want: "png", ok: true},
 		"image/webp":         {want: "webp", ok: true},
-		"image/heic":         {ok: false},
+		"image/heic":         {want: "heic", ok: true},
 		"application/pdf":    {ok: false},
 		"":                   {ok: false},
 		"image/jpeg; q=high": {ok: false}, // parameters not stripped on purpose
@@ -91,3 +91,83 @@ func TestParseGCSPath(t *testing.T) {
 		})
 	}
 }
+
+func TestValidBillID(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{id: "1760000000000-k3j9x0ab", want: true},
+		{id: "bill-99", want: true},
+		{id: strings.Repeat("a", 64), want: true},
+		{id: ""},
+		{id: strings.Repeat("a", 65)},
+		{id: "b_thumb"},
+		{id: "a/b"},
+		{id: "b.jpg"},
+		{id: "..."},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.id, func(t *testing.T) {
+			t.Parallel()
+			if got := ValidBillID(tc.id); got != tc.want {
+				t.Errorf("ValidBillID(%q) = %v, want %v", tc.id, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDerivedPrefix(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]string{
+		"users/u/bills/b1.jpg":      "users/u/bills/b1_",
+		"users/u/bills/b1":          "users/u/bills/b1_",
+		"users/u/bills.v2/b1":       "users/u/bills.v2/b1_", // dot in a directory is not an extension
+		"users/u/bills/b1.orig.png": "users/u/bills/b1.orig_",
+	}
+	for input, want := range cases {
+		if got := derivedPrefix(input); got != want {
+			t.Errorf("derivedPrefix(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestUserObject(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		uid     string
+		gcsPath string
+		want    string
+	}{
+		{name: "own photo", uid: "u1", gcsPath: "gs://wattrent/users/u1/bills/b1.jpg", want: "users/u1/bills/b1.jpg"},
+		{name: "another user's", uid: "u1", gcsPath: "gs://wattrent/users/u2/bills/b1.jpg"},
+		{name: "uid is a prefix of another", uid: "u1", gcsPath: "gs://wattrent/users/u10/bills/b1.jpg"},
+		{name: "outside users", uid: "u1", gcsPath: "gs://wattrent/public/logo.png"},
+		{name: "the user's folder itself", uid: "u1", gcsPath: "gs://wattrent/users/u1/"},
+		{name: "no uid", uid: "", gcsPath: "gs://wattrent/users//bills/b1.jpg"},
+		{name: "not gs", uid: "u1", gcsPath: "https://example.com/users/u1/b1.jpg"},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := userObject(tc.uid, tc.gcsPath)
+			if tc.want == "" {
+				if err == nil {
+					t.Errorf("userObject = %q, want an error", got)
+				}
+				return
+			}
+			if err != nil || got != tc.want {
+				t.Errorf("userObject = %q, %v; want %q", got, err, tc.want)
+			}
+		})
+	}
+}
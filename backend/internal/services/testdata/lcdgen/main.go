@@ -0,0 +1,275 @@
+// Command lcdgen renders the seven-segment corpus in ../lcd that
+// TestLCDOCRProvider_Corpus reads. The photos are synthetic but carry what
+// real ones do: ghost segments, a bezel and printed labels around the
+// display, noise, blur, uneven light, JPEG artifacts. Deterministic; run it
+// from backend/ after changing a scene:
+//
+//	go run ./internal/services/testdata/lcdgen internal/services/testdata/lcd
+//
+// Its segment table is a copy of the reader's, so every photo's {name}.txt
+// next to it, the segments drawn as text, is written and checked by hand: a
+// new scene needs one.
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// scene is one photo: a display showing text in the middle of a meter body.
+type scene struct {
+	name      string
+	text      string // digits, optional '.'
+	w, h      int
+	digitH    int
+	lcdBg     uint8
+	ink       uint8
+	ghost     uint8 // 0: none
+	inverted  bool
+	slant     float64
+	blur      int
+	noise     float64
+	clutter   bool
+	glareSpot bool
+	tint      bool
+}
+
+// segs are the lit segments of each digit, a-g as in ocr_lcd.go.
+var segs = map[byte]string{
+	'0': "abcdef", '1': "bc", '2': "abdeg", '3': "abcdg", '4': "bcfg",
+	'5': "acdfg", '6': "acdefg", '7': "abc", '8': "abcdefg", '9': "abcdfg",
+}
+
+func main() {
+	out := os.Args[1]
+	scenes := []scene{
+		{name: "36034_dark-on-grey", text: "036034", w: 480, h: 320, digitH: 48, lcdBg: 185, ink: 40, ghost: 172, noise: 6},
+		{name: "128457_whole-meter", text: "128457", w: 900, h: 1200, digitH: 70, lcdBg: 175, ink: 50, ghost: 165, noise: 8, clutter: true, blur: 1},
+		{name: "9081_backlit", text: "09081", w: 500, h: 300, digitH: 60, lcdBg: 30, ink: 210, inverted: true, noise: 10},
+		{name: "2531_tenths", text: "2531.6", w: 520, h: 320, digitH: 52, lcdBg: 190, ink: 35, ghost: 178, noise: 5},
+		{name: "70112_slanted", text: "070112", w: 560, h: 340, digitH: 56, lcdBg: 182, ink: 45, slant: 0.08, noise: 6, blur: 1},
+		{name: "4409_green-tint", text: "004409", w: 600, h: 400, digitH: 50, lcdBg: 170, ink: 55, ghost: 160, noise: 7, tint: true},
+		{name: "583920_small", text: "583920", w: 640, h: 480, digitH: 30, lcdBg: 188, ink: 42, noise: 5, clutter: true},
+		{name: "16_short-register", text: "00016", w: 420, h: 280, digitH: 44, lcdBg: 186, ink: 38, ghost: 175, noise: 4, glareSpot: true},
+	}
+	for i, sc := range scenes {
+		write(filepath.Join(out, sc.name+".jpg"), render(sc, rand.New(rand.NewSource(int64(i+1)))))
+	}
+	// A mechanical register: rotating wheels with printed digits, no segments.
+	write(filepath.Join(out, "none_mechanical.jpg"), mechanical(rand.New(rand.NewSource(99))))
+}
+
+func write(path string, img image.Image) {
+	f, err := os.Create(path)
+	if err != nil {
+		panic(err)
+	}
+	defer f.Close()
+	if err := jpeg.Encode(f, img, &jpeg.Options{Quality: 82}); err != nil {
+		panic(err)
+	}
+	fmt.Println(filepath.Base(path))
+}
+
+// render draws sc with digits dh tall and segments 12% of that thick.
+func render(sc scene, rng *rand.Rand) *image.RGBA {
+	g := make([]float64, sc.w*sc.h)
+	body := 205.0
+	if sc.inverted {
+		body = 120
+	}
+	for i := range g {
+		g[i] = body
+	}
+	fill := func(x0, y0, x1, y1 int, v float64) {
+		for y := max(y0, 0); y < min(y1, sc.h); y++ {
+			for x := max(x0, 0); x < min(x1, sc.w); x++ {
+				g[y*sc.w+x] = v
+			}
+		}
+	}
+	n := len(strings.ReplaceAll(sc.text, ".", ""))
+	dh := sc.digitH
+	dw := dh * 55 / 100
+	gap := dh * 18 / 100
+	regW := n*dw + (n-1)*gap
+	lx := (sc.w - regW) / 2
+	ly := sc.h/2 - dh/2
+	if sc.clutter {
+		ly = sc.h / 3
+	}
+	// LCD window with a dark bezel.
+	fill(lx-dh/2-6, ly-dh/3-6, lx+regW+dh/2+6, ly+dh+dh/3+6, 60)
+	fill(lx-dh/2, ly-dh/3, lx+regW+dh/2, ly+dh+dh/3, float64(sc.lcdBg))
+
+	t := max(dh*12/100, 2)
+	sgap := max(dh/40, 1)
+	drawDigit := func(x, y int, lit string, v float64) {
+		rects := map[byte][4]int{
+			'a': {x + sgap + t/2, y, x + dw - sgap - t/2, y + t},
+			'g': {x + sgap + t/2, y + dh/2 - t/2, x + dw - sgap - t/2, y + dh/2 + t - t/2},
+			'd': {x + sgap + t/2, y + dh - t, x + dw - sgap - t/2, y + dh},
+			'f': {x, y + sgap + t/2, x + t, y + dh/2 - sgap},
+			'b': {x + dw - t, y + sgap + t/2, x + dw, y + dh/2 - sgap},
+			'e': {x, y + dh/2 + sgap, x + t, y + dh - sgap - t/2},
+			'c': {x + dw - t, y + dh/2 + sgap, x + dw, y + dh - sgap - t/2},
+		}
+		for _, s := range lit {
+			r := rects[byte(s)]
+			for yy := r[1]; yy < r[3]; yy++ {
+				off := int(sc.slant * float64(y+dh-yy))
+				fill(r[0]+off, yy, r[2]+off, yy+1, v)
+			}
+		}
+	}
+	x := lx
+	for i := 0; i < len(sc.text); i++ {
+		c := sc.text[i]
+		if c == '.' {
+			s := max(t, 3)
+			fill(x-gap/2-s/2, ly+dh-s, x-gap/2+s-s/2, ly+dh, float64(sc.ink))
+			continue
+		}
+		if sc.ghost != 0 {
+			drawDigit(x, ly, "abcdefg", float64(sc.ghost))
+		}
+		drawDigit(x, ly, segs[c], float64(sc.ink))
+		x += dw + gap
+	}
+	if sc.clutter {
+		// Serial number and rating text: small dark glyph-like marks.
+		for row := 0; row < 4; row++ {
+			y := sc.h/2 + row*sc.h/10
+			cx := sc.w / 6
+			for k := 0; k < 14; k++ {
+				ch := 10 + rng.Intn(6)
+				cw := 6 + rng.Intn(4)
+				fill(cx, y, cx+cw, y+ch, 30)
+				fill(cx+2, y+2, cx+cw-2, y+ch-2, body)
+				cx += cw + 5
+			}
+		}
+		fill(sc.w/8, sc.h*7/8, sc.w*7/8, sc.h*7/8+6, 50) // a label edge
+	}
+	if sc.glareSpot {
+		cx, cy, r := sc.w*3/4, sc.h/4, sc.h/8
+		for y := cy - r; y < cy+r; y++ {
+			for x := cx - r; x < cx+r; x++ {
+				if (x-cx)*(x-cx)+(y-cy)*(y-cy) < r*r && x >= 0 && y >= 0 && x < sc.w && y < sc.h {
+					g[y*sc.w+x] = 250
+				}
+			}
+		}
+	}
+	for b := 0; b < sc.blur; b++ {
+		g = boxBlur(g, sc.w, sc.h)
+	}
+	// Uneven lighting: brighter top left.
+	img := image.NewRGBA(image.Rect(0, 0, sc.w, sc.h))
+	for y := 0; y < sc.h; y++ {
+		for x := 0; x < sc.w; x++ {
+			v := g[y*sc.w+x]*(0.9+0.12*(1-float64(x+y)/float64(sc.w+sc.h))) + rng.NormFloat64()*sc.noise
+			v = math.Max(0, math.Min(255, v))
+			c := color.RGBA{uint8(v), uint8(v), uint8(v), 255}
+			if sc.tint {
+				c = color.RGBA{uint8(v * 0.85), uint8(math.Min(255, v*1.05)), uint8(v * 0.8), 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func boxBlur(g []float64, w, h int) []float64 {
+	out := make([]float64, len(g))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			s, n := 0.0, 0
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					if xx, yy := x+dx, y+dy; xx >= 0 && yy >= 0 && xx < w && yy < h {
+						s += g[yy*w+xx]
+						n++
+					}
+				}
+			}
+			out[y*w+x] = s / float64(n)
+		}
+	}
+	return out
+}
+
+// mechanical draws a row of black wheel windows with white digits in a
+// printed (curved, diagonal) font, like an odometer register: "04817".
+func mechanical(rng *rand.Rand) *image.RGBA {
+	w, h := 480, 300
+	g := make([]float64, w*h)
+	for i := range g {
+		g[i] = 200
+	}
+	dot := func(x, y float64, v float64) {
+		for dy := -2; dy <= 2; dy++ {
+			for dx := -2; dx <= 2; dx++ {
+				xx, yy := int(x)+dx, int(y)+dy
+				if xx >= 0 && yy >= 0 && xx < w && yy < h && dx*dx+dy*dy <= 5 {
+					g[yy*w+xx] = v
+				}
+			}
+		}
+	}
+	line := func(x0, y0, x1, y1 float64, v float64) {
+		for s := 0.0; s <= 1; s += 0.005 {
+			dot(x0+(x1-x0)*s, y0+(y1-y0)*s, v)
+		}
+	}
+	arc := func(cx, cy, rx, ry, a0, a1 float64, v float64) {
+		for a := a0; a <= a1; a += 0.01 {
+			dot(cx+rx*math.Cos(a), cy+ry*math.Sin(a), v)
+		}
+	}
+	glyphs := map[byte]func(x, y, gw, gh float64){
+		'0': func(x, y, gw, gh float64) { arc(x+gw/2, y+gh/2, gw/2, gh/2, 0, 2*math.Pi, 235) },
+		'4': func(x, y, gw, gh float64) {
+			line(x+gw*0.7, y+gh, x+gw*0.7, y, 235)
+			line(x+gw*0.7, y, x, y+gh*0.68, 235)
+			line(x, y+gh*0.68, x+gw, y+gh*0.68, 235)
+		},
+		'8': func(x, y, gw, gh float64) {
+			arc(x+gw/2, y+gh*0.25, gw*0.4, gh*0.25, 0, 2*math.Pi, 235)
+			arc(x+gw/2, y+gh*0.74, gw/2, gh*0.26, 0, 2*math.Pi, 235)
+		},
+		'1': func(x, y, gw, gh float64) {
+			line(x+gw*0.2, y+gh*0.2, x+gw*0.55, y, 235)
+			line(x+gw*0.55, y, x+gw*0.55, y+gh, 235)
+			line(x+gw*0.2, y+gh, x+gw*0.9, y+gh, 235)
+		},
+		'7': func(x, y, gw, gh float64) {
+			line(x, y, x+gw, y, 235)
+			line(x+gw, y, x+gw*0.35, y+gh, 235)
+		},
+	}
+	for k, c := range []byte("04817") {
+		x0 := 90 + k*62
+		for y := 90; y < 210; y++ {
+			for x := x0; x < x0+54; x++ {
+				g[y*w+x] = 20
+			}
+		}
+		glyphs[c](float64(x0+12), 115, 30, 70)
+	}
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := math.Max(0, math.Min(255, g[y*w+x]+rng.NormFloat64()*6))
+			img.Set(x, y, color.RGBA{uint8(v), uint8(v), uint8(v), 255})
+		}
+	}
+	return img
+}
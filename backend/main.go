@@ -2,7 +2,7 @@
 //
 // Startup order:
 //  1. config.Load reads environment variables
-//  2. clients.New builds Firestore / Storage / Gemini (AI Studio or Vertex) / Firebase Auth
+//  2. clients.New builds Firestore / Storage / Gemini (AI Studio and/or Vertex) / Firebase Auth
 //  3. services receive their clients
 //  4. handlers receive their services
 //  5. router + middleware
@@ -18,6 +18,9 @@ import (
 	"os/signal"
 	"syscall"
 	"time"
+	// Embed the IANA zone database: user timezones are validated with
+	// time.LoadLocation and the distroless image should not be trusted to ship it.
+	_ "time/tzdata"
 
 	"github.com/getsentry/sentry-go"
 	sentrygin "github.com/getsentry/sentry-go/gin"
@@ -80,16 +83,37 @@ func main() {
 	}()
 
 	settingsSvc := services.NewSettingsService(cls.Firestore)
-	billSvc := services.NewBillService(cls.Firestore, settingsSvc)
 	storageSvc := services.NewStorageService(cls.Storage, cfg.MetersBucket)
-	ocrSvc := services.NewOCRService(cls.Gemini, storageSvc, cfg.GeminiModel)
+	meterSvc := services.NewMeterProfileService(cls.Firestore)
+	roomSvc := services.NewRoomService(cls.Firestore)
+	correctionSvc := services.NewOCRCorrectionService(cls.Firestore, storageSvc)
+	ocrSvc := services.NewOCRService(cls.Firestore, storageSvc, ocrProviders(cfg, cls), services.OCROptions{
+		Escalation:        ocrEscalationProviders(cfg, cls),
+		Cache:             services.NewFirestoreOCRCache(cls.Firestore),
+		CacheTTL:          cfg.OCRCacheTTL,
+		MaxImageDimension: cfg.OCRMaxImageDimension,
+		NormalizeContrast: cfg.OCRNormalizeContrast,
+		HEIC:              services.NewCommandHEICConverter(cfg.HEICConvertCommand),
+		MinSharpness:      cfg.OCRMinSharpness,
+		MaxClipped:        cfg.OCRMaxClipped,
+		MinBrightness:     cfg.OCRMinBrightness,
+		Profiles:          meterSvc,
+		Corrections:       correctionSvc,
+		FewShotExamples:   cfg.OCRFewShotExamples,
+		Prompts:           services.NewFirestoreOCRPrompts(cls.Firestore),
+		Rooms:             roomSvc,
+		ReportUIDs:        cfg.OCRReportUIDs,
+	})
+	billSvc := services.NewBillService(cls.Firestore, settingsSvc, storageSvc, ocrSvc)
+	readingSvc := services.NewReadingService(cls.Firestore, storageSvc)
+	forecastSvc := services.NewForecastService(settingsSvc, billSvc, readingSvc)
 	userSvc := services.NewUserService(cls.Firestore)
 	// deleteAuthUser is disabled under AUTH_BYPASS where the uid is a synthetic
 	// dev user that exists in no Firebase project.
 	accountSvc := services.NewAccountService(cls.Firestore, storageSvc, cls.Auth, !cfg.AuthBypass)
 	lineSvc := services.NewLINEAuthService(cls.Auth, cfg.LINEChannelID, cfg.LINEChannelSecret)
 
-	router := buildRouter(cfg, cls, settingsSvc, billSvc, storageSvc, ocrSvc, userSvc, accountSvc, lineSvc)
+	router := buildRouter(cfg, cls, settingsSvc, billSvc, readingSvc, forecastSvc, storageSvc, ocrSvc, meterSvc, roomSvc, correctionSvc, userSvc, accountSvc, lineSvc)
 
 	server := &http.Server{
 		Addr:              ":" + cfg.Port,
@@ -119,13 +143,67 @@ func main() {
 	slog.Info("server stopped")
 }
 
+// ocrProviders builds the OCR fallback chain in OCR_PROVIDERS order. A
+// provider whose client or key is missing is left out (fail-late: with none
+// left, the OCR endpoints return 503).
+func ocrProviders(cfg *config.Config, cls *clients.Clients) []services.OCRProvider {
+	var providers []services.OCRProvider
+	for _, name := range cfg.OCRProviders {
+		switch name {
+		case "gemini":
+			if cls.Gemini != nil {
+				providers = append(providers, services.NewGenAIOCRProvider(name, cls.Gemini, cfg.GeminiModel))
+			}
+		case "vertex":
+			if cls.Vertex != nil {
+				providers = append(providers, services.NewGenAIOCRProvider(name, cls.Vertex, cfg.GeminiModel))
+			}
+		case "openai":
+			if cfg.OpenAIAPIKey != "" {
+				providers = append(providers, services.NewOpenAIOCRProvider(cfg.OpenAIBaseURL, cfg.OpenAIAPIKey, cfg.OpenAIModel, nil))
+			} else {
+				slog.Warn("ocr provider openai skipped: OPENAI_API_KEY not set")
+			}
+		case "lcd":
+			providers = append(providers, services.NewLCDOCRProvider())
+		case "fake":
+			providers = append(providers, services.NewFakeOCRProvider())
+		}
+	}
+	return providers
+}
+
+// ocrEscalationProviders is the fallback chain of the stronger model: the
+// gemini / vertex providers in OCR_PROVIDERS order, with OCR_ESCALATION_MODEL.
+// Nil (escalation off) when no model is configured.
+func ocrEscalationProviders(cfg *config.Config, cls *clients.Clients) []services.OCRProvider {
+	if cfg.OCREscalationModel == "" {
+		return nil
+	}
+	var providers []services.OCRProvider
+	for _, name := range cfg.OCRProviders {
+		switch {
+		case name == "gemini" && cls.Gemini != nil:
+			providers = append(providers, services.NewGenAIOCRProvider(name, cls.Gemini, cfg.OCREscalationModel))
+		case name == "vertex" && cls.Vertex != nil:
+			providers = append(providers, services.NewGenAIOCRProvider(name, cls.Vertex, cfg.OCREscalationModel))
+		}
+	}
+	return providers
+}
+
 func buildRouter(
 	cfg *config.Config,
 	cls *clients.Clients,
 	settingsSvc *services.SettingsService,
 	billSvc *services.BillService,
+	readingSvc *services.ReadingService,
+	forecastSvc *services.ForecastService,
 	storageSvc *services.StorageService,
 	ocrSvc *services.OCRService,
+	meterSvc *services.MeterProfileService,
+	roomSvc *services.RoomService,
+	correctionSvc *services.OCRCorrectionService,
 	userSvc *services.UserService,
 	accountSvc *services.AccountService,
 	lineSvc *services.LINEAuthService,
@@ -155,8 +233,14 @@ func buildRouter(
 
 	// Handlers
 	billHandler := handlers.NewBillHandler(billSvc, storageSvc)
+	readingHandler := handlers.NewReadingHandler(readingSvc)
+	forecastHandler := handlers.NewForecastHandler(forecastSvc)
+	statsHandler := handlers.NewStatsHandler(billSvc)
 	settingsHandler := handlers.NewSettingsHandler(settingsSvc)
 	ocrHandler := handlers.NewOCRHandler(ocrSvc)
+	meterHandler := handlers.NewMeterHandler(meterSvc)
+	roomHandler := handlers.NewRoomHandler(roomSvc)
+	correctionHandler := handlers.NewOCRCorrectionHandler(correctionSvc)
 	uploadHandler := handlers.NewUploadHandler(storageSvc)
 	userHandler := handlers.NewUserHandler(userSvc)
 	accountHandler := handlers.NewAccountHandler(accountSvc)
@@ -195,6 +279,12 @@ func buildRouter(
 
 		// OCR (extra rate limit on top of the global one)
 		authed.POST("/ocr/process", ocrLimiter.Middleware(), ocrHandler.Process)
+		// Prompt variant comparison across users (OCR_REPORT_UIDS only)
+		authed.GET("/ocr/report", ocrHandler.Report)
+		// Corrected readings kept as examples (see settings.ocrLearnFromCorrections)
+		authed.GET("/ocr/corrections", correctionHandler.List)
+		authed.DELETE("/ocr/corrections", correctionHandler.DeleteAll)
+		authed.DELETE("/ocr/corrections/:attemptId", correctionHandler.Delete)
 
 		// Uploads
 		authed.POST("/uploads/sign", uploadHandler.Sign)
@@ -202,11 +292,29 @@ func buildRouter(
 		// Bills
 		bills := authed.Group("/bills")
 		bills.POST("", billHandler.Create)
+		// from-photo runs OCR, so it shares the OCR rate limit.
+		bills.POST("/from-photo", ocrLimiter.Middleware(), billHandler.CreateFromPhoto)
 		bills.GET("", billHandler.List)
 		bills.GET("/latest", billHandler.Latest)
+		bills.GET("/trash", billHandler.ListTrash)
+		bills.DELETE("/trash/:id", billHandler.DeleteFromTrash)
 		bills.GET("/:id", billHandler.Get)
 		bills.PUT("/:id/payment", billHandler.UpdatePayment)
 		bills.DELETE("/:id", billHandler.Delete)
+		bills.POST("/:id/restore", billHandler.Restore)
+		bills.POST("/:id/confirm", billHandler.Confirm)
+
+		// Reading log (mid-period readings, independent of bills)
+		readings := authed.Group("/readings")
+		readings.POST("", readingHandler.Create)
+		readings.GET("", readingHandler.List)
+		readings.DELETE("/:id", readingHandler.Delete)
+
+		// Open-period forecast
+		authed.GET("/forecast", forecastHandler.Get)
+
+		// Stats
+		authed.GET("/stats/emissions", statsHandler.Emissions)
 
 		// Settings (no longer takes :userId; uid comes from the token)
 		settings := authed.Group("/settings")
@@ -214,6 +322,19 @@ func buildRouter(
 		settings.PUT("", settingsHandler.Save)
 		settings.PATCH("", settingsHandler.Patch)
 		settings.DELETE("", settingsHandler.Delete)
+
+		// Meter profiles (learned by OCR, editable)
+		meters := authed.Group("/meters")
+		meters.GET("", meterHandler.List)
+		meters.PUT("/:meterType", meterHandler.Save)
+		meters.DELETE("/:meterType", meterHandler.Delete)
+
+		// Rooms billed from the meters of a meter bank
+		rooms := authed.Group("/rooms")
+		rooms.GET("", roomHandler.List)
+		rooms.POST("", roomHandler.Create)
+		rooms.PUT("/:id", roomHandler.Update)
+		rooms.DELETE("/:id", roomHandler.Delete)
 	}
 
 	return r
@@ -32,6 +32,9 @@ export interface Bill {
   imageUrl?: string;
   imageViewUrl?: string; // short-lived signed GET URL, populated only on detail fetch
   paidAt?: string;
+  // Room id (and its label then) when made from one meter of a bank photo
+  room?: string;
+  roomLabel?: string;
   ocr?: BillOcrResult;
   createdAt: string;
   updatedAt?: string;
@@ -55,6 +58,9 @@ export interface UserSettings {
   language?: string;
   notificationsEnabled?: boolean;
   autoBackup?: boolean;
+  // Keep photos of corrected OCR readings as examples for later reads of the
+  // same meter (see OCRCorrection). Off by default; turning it off deletes them.
+  ocrLearnFromCorrections?: boolean;
   updatedAt?: string;
 
   // legacy; still used by old screens, remove in the future
@@ -69,10 +75,110 @@ export interface ApiResponse<T> {
   message?: string;
 }
 
+// Per-meter profile from /meters; learned by the first confident OCR read
+// (source "ocr") and editable (source "user"). Zero/empty fields are unknown.
+export interface MeterProfile {
+  meterType: 'electricity' | 'water' | 'gas';
+  digitCount?: number;
+  display?: 'mechanical' | 'lcd';
+  decimalDrum: boolean;
+  model?: string;
+  serial?: string;
+  source: 'ocr' | 'user';
+  updatedAt: string;
+}
+
+// A corrected OCR reading kept as an example, from /ocr/corrections.
+export interface OCRCorrection {
+  attemptId: string;
+  meterType: 'electricity' | 'water' | 'gas';
+  imageUrl: string;
+  modelReading: number;
+  correctedReading: number;
+  promptVersion: string;
+  createdAt: string;
+  expiresAt: string;
+}
+
 // OCR result returned by /ocr/process.
 export interface OCRResult {
   reading: number;
   confidence: number;
   rawText?: string;
   model?: string;
+  // Prompt the reading came from: a built-in version or an experiment
+  // variant's, with mode suffixes such as "+bank-1".
+  promptVersion?: string;
+  // Register digits left to right (reading's integer part), when the model
+  // located them; a low-confidence digit is the one to highlight.
+  digits?: OCRDigit[];
+  registerBox?: OCRBox;
+  // Serial number read off the meter when the profile has one to check;
+  // "wrong_meter" means it is someone else's. Creating a bill from such an
+  // attempt needs confirmWrongMeter.
+  serial?: string;
+  warnings?: OCRWarning[];
+  // Every meter of a bank photo (request sent with bank: true); reading and
+  // confidence above are then 0. Pick one with ocrMeterPosition.
+  meters?: OCRBankMeter[];
+}
+
+// A room registered at /rooms. Bank reads map a meter to it by serial number
+// or by position (1-based, reading order: top row first, left to right).
+export interface Room {
+  id: string;
+  label: string;
+  serial?: string;
+  position?: number;
+  createdAt: string;
+  updatedAt: string;
+}
+
+export interface OCRBankMeter {
+  position: number;
+  serial?: string;
+  reading: number;
+  confidence: number;
+  notes?: string;
+  box?: OCRBox;
+  room?: string; // Room id
+  roomLabel?: string;
+}
+
+export type OCRWarning = 'wrong_meter';
+
+// Prompt variants of one meter type compared across users, from /ocr/report.
+export interface OCRReport {
+  meterType: 'electricity' | 'water' | 'gas';
+  since: string;
+  truncated?: boolean;
+  variants: OCRVariantStats[];
+}
+
+// Rates are over resolved attempts (the ones a bill settled).
+export interface OCRVariantStats {
+  promptVersion: string;
+  attempts: number;
+  failed: number;
+  resolved: number;
+  accepted: number;
+  corrected: number;
+  acceptanceRate: number;
+  correctionRate: number;
+  avgConfidence: number;
+  avgLatencyMs: number;
+}
+
+// Fractions (0-1) of the photo as sent, origin top left.
+export interface OCRBox {
+  left: number;
+  top: number;
+  right: number;
+  bottom: number;
+}
+
+export interface OCRDigit {
+  value: number;
+  confidence: number;
+  box?: OCRBox;
 }